@@ -4,7 +4,6 @@ go 1.22.12
 
 require (
 	github.com/dgraph-io/badger/v4 v4.6.0
-	github.com/google/uuid v1.6.0
 	github.com/zeebo/assert v1.3.1
 )
 
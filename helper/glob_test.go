@@ -0,0 +1,39 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestStringMatch(t *testing.T) {
+	cases := []struct {
+		pattern, str string
+		nocase, want bool
+	}{
+		{"*", "anything", false, true},
+		{"*", "", false, false},
+		{"h?llo", "hello", false, true},
+		{"h?llo", "hllo", false, false},
+		{"h*llo", "heeeello", false, true},
+		{"h[ae]llo", "hallo", false, true},
+		{"h[ae]llo", "hillo", false, false},
+		{"h[^e]llo", "hallo", false, true},
+		{"h[^e]llo", "hello", false, false},
+		{"h[a-b]llo", "hbllo", false, true},
+		{"h[b-a]llo", "hbllo", false, true},
+		{"h\\*llo", "h*llo", false, true},
+		{"h\\*llo", "hello", false, false},
+		{"HELLO", "hello", true, true},
+		{"HELLO", "hello", false, false},
+		{"a*b*c", "axxbyyc", false, true},
+		{"a*b*c", "axxbyy", false, false},
+		{"ab[c", "abc", false, true},
+		{"ab[", "abc", false, false},
+		{"a**", "a", false, true},
+		{"*a*a*a*a*a*a*a*a*a*a*b", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", false, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, StringMatch([]byte(c.pattern), []byte(c.str), c.nocase))
+	}
+}
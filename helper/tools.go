@@ -21,11 +21,16 @@ func BytesToUint64(b []byte) uint64 {
 	return binary.BigEndian.Uint64(b)
 }
 
+// ProtectGoroutine 在新的 goroutine 中执行 goFunc，recover 必须在同一个 goroutine 中调用才能接住 panic
 func ProtectGoroutine(goFunc func()) {
-	if err := recover(); err != nil {
-		fmt.Println("func:ProtectGoroutine Error:", err)
-	}
-	go goFunc()
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				fmt.Println("func:ProtectGoroutine Error:", err)
+			}
+		}()
+		goFunc()
+	}()
 }
 
 func InterfaceToBytes(data interface{}) ([]byte, error) {
@@ -0,0 +1,274 @@
+package resp
+
+import (
+	"PumbaaDB/store"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	errBitArg           = errors.New("ERR The bit argument must be 1 or 0.")
+	errBitfieldType     = errors.New("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+	errBitfieldOverflow = errors.New("ERR Invalid OVERFLOW type specified")
+	errBitfieldRO       = errors.New("ERR BITFIELD_RO only supports the GET subcommand")
+)
+
+// parseBitOffset 解析 bit 偏移量，BITFIELD 中以 # 开头的偏移量表示第几个 bits 位宽的整数
+func parseBitOffset(arg []byte, bits int) (int64, error) {
+	str := string(arg)
+	multiplier := int64(1)
+	if bits > 0 && strings.HasPrefix(str, "#") {
+		str = str[1:]
+		multiplier = int64(bits)
+	}
+	offset, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || offset < 0 || offset > store.MaxBitOffset/multiplier {
+		return 0, store.ErrBitOffset
+	}
+	return offset * multiplier, nil
+}
+
+// parseBitRangeUnit 解析 BITCOUNT、BITPOS 末尾的 BYTE | BIT 参数
+func parseBitRangeUnit(arg []byte) (bool, error) {
+	switch strings.ToUpper(string(arg)) {
+	case "BYTE":
+		return false, nil
+	case "BIT":
+		return true, nil
+	}
+	return false, errSyntax
+}
+
+// handleSetBit 实现 SETBIT key offset value
+func handleSetBit(c *Client, args [][]byte, s *store.BadgerStore) {
+	offset, err := parseBitOffset(args[1], 0)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	var on bool
+	switch string(args[2]) {
+	case "0":
+	case "1":
+		on = true
+	default:
+		c.WriteError(store.ErrBitValue)
+		return
+	}
+	old, err := s.SetBit(args[0], offset, on)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(int64(old))
+}
+
+// handleGetBit 实现 GETBIT key offset
+func handleGetBit(c *Client, args [][]byte, store *store.BadgerStore) {
+	offset, err := parseBitOffset(args[1], 0)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	bit, err := store.GetBit(args[0], offset)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(int64(bit))
+}
+
+// handleBitCount 实现 BITCOUNT key [start end [BYTE | BIT]]
+func handleBitCount(c *Client, args [][]byte, s *store.BadgerStore) {
+	var r *store.BitRange
+	switch len(args) {
+	case 1:
+	case 3, 4:
+		r = &store.BitRange{}
+		var err error
+		if r.Start, err = strconv.ParseInt(string(args[1]), 10, 64); err != nil {
+			c.WriteError(errNotInteger)
+			return
+		}
+		if r.End, err = strconv.ParseInt(string(args[2]), 10, 64); err != nil {
+			c.WriteError(errNotInteger)
+			return
+		}
+		if len(args) == 4 {
+			if r.BitUnit, err = parseBitRangeUnit(args[3]); err != nil {
+				c.WriteError(err)
+				return
+			}
+		}
+	default:
+		c.WriteError(errSyntax)
+		return
+	}
+	count, err := s.BitCount(args[0], r)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(count)
+}
+
+// handleBitPos 实现 BITPOS key bit [start [end [BYTE | BIT]]]
+func handleBitPos(c *Client, args [][]byte, s *store.BadgerStore) {
+	if len(args) > 5 {
+		c.WriteError(errSyntax)
+		return
+	}
+	bit, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	if bit != 0 && bit != 1 {
+		c.WriteError(errBitArg)
+		return
+	}
+	r := store.BitRange{Start: 0, End: -1}
+	if len(args) > 2 {
+		if r.Start, err = strconv.ParseInt(string(args[2]), 10, 64); err != nil {
+			c.WriteError(errNotInteger)
+			return
+		}
+	}
+	if len(args) > 3 {
+		if r.End, err = strconv.ParseInt(string(args[3]), 10, 64); err != nil {
+			c.WriteError(errNotInteger)
+			return
+		}
+	}
+	if len(args) > 4 {
+		if r.BitUnit, err = parseBitRangeUnit(args[4]); err != nil {
+			c.WriteError(err)
+			return
+		}
+	}
+	pos, err := s.BitPos(args[0], int(bit), r, len(args) > 3)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(pos)
+}
+
+// handleBitOp 实现 BITOP <AND | OR | XOR | NOT> destkey key [key ...]
+func handleBitOp(c *Client, args [][]byte, s *store.BadgerStore) {
+	op := strings.ToUpper(string(args[0]))
+	switch op {
+	case store.BitOpAnd, store.BitOpOr, store.BitOpXor, store.BitOpNot:
+	default:
+		c.WriteError(errSyntax)
+		return
+	}
+	length, err := s.BitOp(op, args[1], args[2:])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(length)
+}
+
+// handleBitField 实现 BITFIELD key [GET encoding offset | [OVERFLOW <WRAP | SAT | FAIL>]
+// <SET encoding offset value | INCRBY encoding offset increment> ...]
+func handleBitField(c *Client, args [][]byte, store *store.BadgerStore) {
+	bitField(c, args, store, false)
+}
+
+// handleBitFieldRO 实现 BITFIELD_RO key [GET encoding offset ...]
+func handleBitFieldRO(c *Client, args [][]byte, store *store.BadgerStore) {
+	bitField(c, args, store, true)
+}
+
+func bitField(c *Client, args [][]byte, s *store.BadgerStore, readonly bool) {
+	var ops []store.BitfieldOp
+	overflow := store.BitfieldWrap
+	for i := 1; i < len(args); i++ {
+		sub := strings.ToUpper(string(args[i]))
+		if sub == "OVERFLOW" && !readonly && i+1 < len(args) {
+			switch strings.ToUpper(string(args[i+1])) {
+			case "WRAP":
+				overflow = store.BitfieldWrap
+			case "SAT":
+				overflow = store.BitfieldSat
+			case "FAIL":
+				overflow = store.BitfieldFail
+			default:
+				c.WriteError(errBitfieldOverflow)
+				return
+			}
+			i++
+			continue
+		}
+
+		op := store.BitfieldOp{Overflow: overflow}
+		argc := 3
+		switch sub {
+		case "GET":
+			op.Op, argc = store.BitfieldGet, 2
+		case "SET":
+			op.Op = store.BitfieldSet
+		case "INCRBY":
+			op.Op = store.BitfieldIncrBy
+		default:
+			c.WriteError(errSyntax)
+			return
+		}
+		if i+argc >= len(args) {
+			c.WriteError(errSyntax)
+			return
+		}
+		if readonly && op.Op != store.BitfieldGet {
+			c.WriteError(errBitfieldRO)
+			return
+		}
+		var err error
+		if op.Signed, op.Bits, err = parseBitfieldType(args[i+1]); err != nil {
+			c.WriteError(err)
+			return
+		}
+		if op.Offset, err = parseBitOffset(args[i+2], op.Bits); err != nil {
+			c.WriteError(err)
+			return
+		}
+		if op.Op != store.BitfieldGet {
+			if op.Value, err = strconv.ParseInt(string(args[i+3]), 10, 64); err != nil {
+				c.WriteError(errNotInteger)
+				return
+			}
+		}
+		ops = append(ops, op)
+		i += argc
+	}
+
+	results, err := s.BitField(args[0], ops)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteArrayHeader(len(results))
+	for _, result := range results {
+		if result == nil {
+			c.WriteNullBulk()
+			continue
+		}
+		c.WriteInt64(*result)
+	}
+}
+
+// parseBitfieldType 解析 i1-i64、u1-u63 形式的位宽
+func parseBitfieldType(arg []byte) (signed bool, bits int, err error) {
+	str := string(arg)
+	if len(str) < 2 || (str[0] != 'i' && str[0] != 'I' && str[0] != 'u' && str[0] != 'U') {
+		return false, 0, errBitfieldType
+	}
+	signed = str[0] == 'i' || str[0] == 'I'
+	n, err := strconv.Atoi(str[1:])
+	if err != nil || n < 1 || (signed && n > 64) || (!signed && n > 63) {
+		return false, 0, errBitfieldType
+	}
+	return signed, n, nil
+}
@@ -0,0 +1,155 @@
+package resp
+
+import (
+	"container/list"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// blockingKeys 记录阻塞在各个列表 key 上的客户端，同一个 key 上的客户端按阻塞的先后顺序排队。
+// 推入元素时只唤醒队首的客户端，队首的客户端离开队列（取到元素、超时或断开）时再唤醒新的队首，
+// 没取到元素的客户端继续留在原来的位置，保证先阻塞的客户端先被服务
+type blockingKeys struct {
+	mu     sync.Mutex
+	queues map[string]*list.List
+}
+
+// waiter 是一个阻塞中的客户端，ready 在它排在队首的某个 key 上有新元素时收到通知
+type waiter struct {
+	keys  []string
+	elems []*list.Element
+	ready chan struct{}
+}
+
+// blocking 是整个服务端共用的阻塞队列
+var blocking = newBlockingKeys()
+
+func newBlockingKeys() *blockingKeys {
+	return &blockingKeys{queues: make(map[string]*list.List)}
+}
+
+// add 把客户端排到 keys 的队尾
+func (b *blockingKeys) add(keys [][]byte) *waiter {
+	w := &waiter{ready: make(chan struct{}, 1)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range keys {
+		queue, ok := b.queues[string(key)]
+		if !ok {
+			queue = list.New()
+			b.queues[string(key)] = queue
+		}
+		w.keys = append(w.keys, string(key))
+		w.elems = append(w.elems, queue.PushBack(w))
+	}
+	return w
+}
+
+// remove 把客户端移出队列，它原本排在队首的 key 会唤醒新的队首，
+// 这样它没有用掉的通知和取完后剩下的元素都能交给后面的客户端
+func (b *blockingKeys) remove(w *waiter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, key := range w.keys {
+		queue := b.queues[key]
+		head := queue.Front() == w.elems[i]
+		queue.Remove(w.elems[i])
+		if queue.Len() == 0 {
+			delete(b.queues, key)
+		} else if head {
+			b.notify(queue)
+		}
+	}
+}
+
+// signal 在 key 上有新元素时唤醒排在队首的客户端
+func (b *blockingKeys) signal(key []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if queue, ok := b.queues[string(key)]; ok {
+		b.notify(queue)
+	}
+}
+
+func (b *blockingKeys) notify(queue *list.List) {
+	select {
+	case queue.Front().Value.(*waiter).ready <- struct{}{}:
+	default:
+	}
+}
+
+// block 先执行一次 serve，serve 写出回复后返回 true，所有 key 都为空时返回 false。
+// 都为空时把客户端挂到 keys 上，每次被唤醒重新执行 serve，超时回复 null array，连接断开时不回复。
+// timeout 为 0 表示一直等待。在 EXEC 中执行时不等待，keys 都为空时直接回复空值：
+// nullBulk 为 true 时回复 null bulk（BLMOVE），否则回复 null array（BLPOP、BRPOP、BLMPOP），与 Redis 一致
+func block(c *Client, keys [][]byte, timeout time.Duration, nullBulk bool, serve func() bool) {
+	if serve() {
+		return
+	}
+	if c.inExec {
+		if nullBulk {
+			c.WriteNullBulk()
+		} else {
+			c.WriteNullArray()
+		}
+		return
+	}
+	w := blocking.add(keys)
+	defer blocking.remove(w)
+	// 检查之后、排队之前推入的元素不会发出通知，排队后再检查一次
+	if serve() {
+		return
+	}
+	// 阻塞前先把流水线中前面命令的回复发出去
+	if err := c.Flush(); err != nil {
+		return
+	}
+	// 等待期间释放 dispatch 持有的读锁，不挡住其他连接的 EXEC，返回前重新加锁
+	execLock.RUnlock()
+	defer execLock.RLock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		select {
+		case <-w.ready:
+			if serveLocked(serve) {
+				return
+			}
+		case <-expired:
+			c.WriteNullArray()
+			return
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// serveLocked 在持有 execLock 读锁时执行 serve
+func serveLocked(serve func() bool) bool {
+	execLock.RLock()
+	defer execLock.RUnlock()
+	return serve()
+}
+
+// parseTimeout 解析阻塞命令以秒为单位的超时时间，可以是小数，精度为毫秒
+func parseTimeout(arg []byte) (time.Duration, error) {
+	secs, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(secs) {
+		return 0, errTimeoutNotFloat
+	}
+	if secs < 0 {
+		return 0, errTimeoutNegative
+	}
+	millis := secs * 1000
+	if millis >= float64(math.MaxInt64/int64(time.Millisecond)) {
+		return 0, errTimeoutOutOfRange
+	}
+	return time.Duration(millis) * time.Millisecond, nil
+}
@@ -0,0 +1,123 @@
+package resp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"PumbaaDB/store"
+
+	"github.com/zeebo/assert"
+)
+
+func TestParseTimeout(t *testing.T) {
+	timeout, err := parseTimeout([]byte("0"))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), timeout)
+	timeout, err = parseTimeout([]byte("1.5"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1500*time.Millisecond, timeout)
+	timeout, err = parseTimeout([]byte(".01"))
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Millisecond, timeout)
+
+	_, err = parseTimeout([]byte("-1"))
+	assert.Equal(t, errTimeoutNegative, err)
+	_, err = parseTimeout([]byte("abc"))
+	assert.Equal(t, errTimeoutNotFloat, err)
+	_, err = parseTimeout([]byte("nan"))
+	assert.Equal(t, errTimeoutNotFloat, err)
+	_, err = parseTimeout([]byte("1e300"))
+	assert.Equal(t, errTimeoutOutOfRange, err)
+}
+
+func TestBlockingKeysFIFO(t *testing.T) {
+	b := newBlockingKeys()
+	w1 := b.add(toArgs("a", "b"))
+	w2 := b.add(toArgs("a"))
+
+	// 只唤醒队首
+	b.signal([]byte("a"))
+	assert.Equal(t, 1, len(w1.ready))
+	assert.Equal(t, 0, len(w2.ready))
+
+	// 队首离开后唤醒新的队首
+	<-w1.ready
+	b.remove(w1)
+	assert.Equal(t, 1, len(w2.ready))
+	b.remove(w2)
+	assert.Equal(t, 0, len(b.queues))
+	b.signal([]byte("a"))
+}
+
+// blockedClient 在单独的 goroutine 中执行命令，返回对应的客户端和读取回复的一端
+func blockedClient(t *testing.T, s *store.BadgerStore, args ...string) (*Client, net.Conn) {
+	server, client := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	c := newClient(server)
+	go func() {
+		dispatch(c, toArgs(args...), s)
+		c.Flush()
+	}()
+	return c, client
+}
+
+// waitBlocked 等待 key 上排队的客户端数量变为 n
+func waitBlocked(key string, n int) {
+	for {
+		blocking.mu.Lock()
+		queued := 0
+		if queue, ok := blocking.queues[key]; ok {
+			queued = queue.Len()
+		}
+		blocking.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func readReply(t *testing.T, conn net.Conn) string {
+	buf := make([]byte, 256)
+	n, err := conn.Read(buf)
+	assert.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestBlockingPop(t *testing.T) {
+	s, err := store.NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer s.Close()
+
+	// 两个客户端先后阻塞在 q 上，推入的元素按阻塞顺序分给它们
+	_, conn1 := blockedClient(t, s, "BRPOP", "q", "0")
+	waitBlocked("q", 1)
+	_, conn2 := blockedClient(t, s, "BRPOP", "other", "q", "0")
+	waitBlocked("q", 2)
+	_, pusher := blockedClient(t, s, "RPUSH", "q", "x", "y")
+	assert.Equal(t, ":2\r\n", readReply(t, pusher))
+	assert.Equal(t, "*2\r\n$1\r\nq\r\n$1\r\ny\r\n", readReply(t, conn1))
+	assert.Equal(t, "*2\r\n$1\r\nq\r\n$1\r\nx\r\n", readReply(t, conn2))
+	waitBlocked("q", 0)
+	waitBlocked("other", 0)
+
+	_, conn3 := blockedClient(t, s, "BLPOP", "q", "0.05")
+	assert.Equal(t, "*-1\r\n", readReply(t, conn3))
+
+	// BLMOVE 推入的元素会唤醒阻塞在目标列表上的客户端
+	_, conn4 := blockedClient(t, s, "BLPOP", "dst", "0")
+	waitBlocked("dst", 1)
+	_, conn5 := blockedClient(t, s, "BLMOVE", "src", "dst", "LEFT", "RIGHT", "0")
+	waitBlocked("src", 1)
+	_, pusher = blockedClient(t, s, "LPUSH", "src", "v")
+	assert.Equal(t, ":1\r\n", readReply(t, pusher))
+	assert.Equal(t, "$1\r\nv\r\n", readReply(t, conn5))
+	assert.Equal(t, "*2\r\n$3\r\ndst\r\n$1\r\nv\r\n", readReply(t, conn4))
+
+	// 连接断开后离开队列
+	c6, _ := blockedClient(t, s, "BLMPOP", "0", "1", "gone", "LEFT")
+	waitBlocked("gone", 1)
+	close(c6.closed)
+	waitBlocked("gone", 0)
+}
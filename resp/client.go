@@ -0,0 +1,106 @@
+package resp
+
+import (
+	"PumbaaDB/store"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// ServerName 和 ServerVersion 通过 HELLO 返回给客户端
+	ServerName    = "redis"
+	ServerVersion = "7.4.0"
+)
+
+var nextClientID atomic.Int64
+
+// Client 保存单个连接的状态，回复通过内嵌的 ReplyWriter 写入
+type Client struct {
+	*ReplyWriter
+	conn net.Conn
+	ID   int64
+	Name string
+	// closed 在连接断开后关闭，用于唤醒阻塞中的命令
+	closed chan struct{}
+	// multi 在 MULTI 之后、EXEC 或 DISCARD 之前不为 nil
+	multi *multiState
+	// inExec 表示正在执行 EXEC 中的命令，阻塞命令此时不等待
+	inExec bool
+}
+
+func newClient(conn net.Conn) *Client {
+	return &Client{
+		ReplyWriter: NewReplyWriter(conn),
+		conn:        conn,
+		ID:          nextClientID.Add(1),
+		closed:      make(chan struct{}),
+	}
+}
+
+// handleHello 实现 HELLO [protover [AUTH username password] [SETNAME clientname]]
+func handleHello(c *Client, args [][]byte, store *store.BadgerStore) {
+	proto := c.Proto()
+	if len(args) > 0 {
+		ver, err := strconv.ParseInt(string(args[0]), 10, 64)
+		if err != nil {
+			c.WriteError(errProtoVersion)
+			return
+		}
+		if ver != ProtoRESP2 && ver != ProtoRESP3 {
+			c.WriteError(errNoProto)
+			return
+		}
+		proto = int(ver)
+	}
+
+	name := c.Name
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "AUTH":
+			if i+2 >= len(args) {
+				c.WriteError(errSyntax)
+				return
+			}
+			// 服务端没有配置密码，只有 default 用户且无需密码
+			if string(args[i+1]) != "default" {
+				c.WriteError(errWrongPass)
+				return
+			}
+			i += 2
+		case "SETNAME":
+			if i+1 >= len(args) {
+				c.WriteError(errSyntax)
+				return
+			}
+			if strings.ContainsAny(string(args[i+1]), " \n") {
+				c.WriteError(errClientName)
+				return
+			}
+			name = string(args[i+1])
+			i++
+		default:
+			c.WriteError(errSyntax)
+			return
+		}
+	}
+
+	c.SetProto(proto)
+	c.Name = name
+	c.WriteMapHeader(7)
+	c.WriteBulkString("server")
+	c.WriteBulkString(ServerName)
+	c.WriteBulkString("version")
+	c.WriteBulkString(ServerVersion)
+	c.WriteBulkString("proto")
+	c.WriteInt64(int64(proto))
+	c.WriteBulkString("id")
+	c.WriteInt64(c.ID)
+	c.WriteBulkString("mode")
+	c.WriteBulkString("standalone")
+	c.WriteBulkString("role")
+	c.WriteBulkString("master")
+	c.WriteBulkString("modules")
+	c.WriteArrayHeader(0)
+}
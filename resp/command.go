@@ -0,0 +1,722 @@
+package resp
+
+import (
+	"PumbaaDB/store"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 命令标志，取值与 Redis COMMAND 的输出一致
+const (
+	flagWrite       = "write"
+	flagReadonly    = "readonly"
+	flagDenyOOM     = "denyoom"
+	flagAdmin       = "admin"
+	flagNoScript    = "noscript"
+	flagLoading     = "loading"
+	flagStale       = "stale"
+	flagFast        = "fast"
+	flagBlocking    = "blocking"
+	flagMovableKeys = "movablekeys"
+)
+
+type commandHandler func(c *Client, args [][]byte, store *store.BadgerStore)
+
+// Command 描述一个命令：分发入口、参数个数、标志和 key 的位置。
+// key 位置沿用 Redis 的 first/last/step 约定，下标从命令名算起，LastKey 为负数表示从末尾倒数。
+type Command struct {
+	Name    string
+	Handler commandHandler
+	// Arity 为正数表示参数个数（含命令名）必须相等，为负数表示至少 -Arity 个
+	Arity    int
+	Flags    []string
+	FirstKey int
+	LastKey  int
+	Step     int
+	// NumKeysIndex 非 0 时表示 key 个数由该位置的参数给出，key 紧随其后（如 SINTERCARD numkeys key ...）
+	NumKeysIndex int
+	// Categories 是命令所属的数据类型等 ACL 分类，read/write/fast/slow/blocking 由标志自动推导
+	Categories  []string
+	Group       string
+	Summary     string
+	Since       string
+	Subcommands []*Command
+}
+
+var commands = map[string]*Command{}
+
+func init() {
+	for _, cmd := range commandTable {
+		commands[cmd.Name] = cmd
+	}
+}
+
+// commandTable 是服务端支持的全部命令
+var commandTable = []*Command{
+	// connection
+	{Name: "hello", Handler: handleHello, Arity: -1,
+		Flags: []string{flagNoScript, flagLoading, flagStale, flagFast}, Categories: []string{"connection"},
+		Group: "connection", Since: "6.0.0", Summary: "Handshakes with the Redis server."},
+
+	// server
+	{Name: "command", Handler: handleCommand, Arity: -1,
+		Flags: []string{flagLoading, flagStale}, Categories: []string{"connection"},
+		Group: "server", Since: "2.8.13", Summary: "Returns detailed information about all commands.",
+		Subcommands: []*Command{
+			{Name: "command|count", Handler: handleCommandCount, Arity: 2,
+				Flags: []string{flagLoading, flagStale}, Categories: []string{"connection"},
+				Group: "server", Since: "2.8.13", Summary: "Returns a count of commands."},
+			{Name: "command|docs", Handler: handleCommandDocs, Arity: -2,
+				Flags: []string{flagLoading, flagStale}, Categories: []string{"connection"},
+				Group: "server", Since: "7.0.0", Summary: "Returns documentary information about one, multiple or all commands."},
+			{Name: "command|getkeys", Handler: handleCommandGetKeys, Arity: -3,
+				Flags: []string{flagLoading, flagStale}, Categories: []string{"connection"},
+				Group: "server", Since: "2.8.13", Summary: "Extracts the key names from an arbitrary command."},
+			{Name: "command|info", Handler: handleCommandInfo, Arity: -2,
+				Flags: []string{flagLoading, flagStale}, Categories: []string{"connection"},
+				Group: "server", Since: "2.8.13", Summary: "Returns information about one, multiple or all commands."},
+		}},
+
+	// string
+	{Name: "append", Handler: handleAppend, Arity: 3,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "2.0.0", Summary: "Appends a string to the value of a key. Creates the key if it doesn't exist."},
+	{Name: "decr", Handler: handleDecr, Arity: 2,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "1.0.0", Summary: "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist."},
+	{Name: "decrby", Handler: handleDecrBy, Arity: 3,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "1.0.0", Summary: "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist."},
+	{Name: "get", Handler: handleGet, Arity: 2,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "1.0.0", Summary: "Returns the string value of a key."},
+	{Name: "getdel", Handler: handleGetDel, Arity: 2,
+		Flags: []string{flagWrite, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "6.2.0", Summary: "Returns the string value of a key after deleting the key."},
+	{Name: "getex", Handler: handleGetEx, Arity: -2,
+		Flags: []string{flagWrite, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "6.2.0", Summary: "Returns the string value of a key after setting its expiration time."},
+	{Name: "getrange", Handler: handleGetRange, Arity: 4,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "2.4.0", Summary: "Returns a substring of the string stored at a key."},
+	{Name: "getset", Handler: handleGetSet, Arity: 3,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "1.0.0", Summary: "Returns the previous string value of a key after setting it to a new value."},
+	{Name: "incr", Handler: handleIncr, Arity: 2,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "1.0.0", Summary: "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist."},
+	{Name: "incrby", Handler: handleIncrBy, Arity: 3,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "1.0.0", Summary: "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist."},
+	{Name: "incrbyfloat", Handler: handleIncrByFloat, Arity: 3,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "2.6.0", Summary: "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist."},
+	{Name: "mget", Handler: handleMGet, Arity: -2,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "1.0.0", Summary: "Atomically returns the string values of one or more keys."},
+	{Name: "mset", Handler: handleMSet, Arity: -3,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: -1, Step: 2, Categories: []string{"string"},
+		Group: "string", Since: "1.0.1", Summary: "Atomically creates or modifies the string values of one or more keys."},
+	{Name: "msetnx", Handler: handleMSetNX, Arity: -3,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: -1, Step: 2, Categories: []string{"string"},
+		Group: "string", Since: "1.0.1", Summary: "Atomically modifies the string values of one or more keys only when all keys don't exist."},
+	{Name: "psetex", Handler: handlePSetEX, Arity: 4,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "2.6.0", Summary: "Sets both string value and expiration time in milliseconds of a key. The key is created if it doesn't exist."},
+	{Name: "set", Handler: HandleSet, Arity: -3,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "1.0.0", Summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist."},
+	{Name: "setex", Handler: handleSetEX, Arity: 4,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "2.0.0", Summary: "Sets the string value and expiration time of a key. Creates the key if it doesn't exist."},
+	{Name: "setnx", Handler: handleSetNX, Arity: 3,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "1.0.0", Summary: "Set the string value of a key only when the key doesn't exist."},
+	{Name: "setrange", Handler: handleSetRange, Arity: 4,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "2.2.0", Summary: "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist."},
+	{Name: "strlen", Handler: handleStrLen, Arity: 2,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"string"},
+		Group: "string", Since: "2.2.0", Summary: "Returns the length of a string value."},
+
+	// bitmap
+	{Name: "bitcount", Handler: handleBitCount, Arity: -2,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"bitmap"},
+		Group: "bitmap", Since: "2.6.0", Summary: "Counts the number of set bits (population counting) in a string."},
+	{Name: "bitfield", Handler: handleBitField, Arity: -2,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"bitmap"},
+		Group: "bitmap", Since: "3.2.0", Summary: "Performs arbitrary bitfield integer operations on strings."},
+	{Name: "bitfield_ro", Handler: handleBitFieldRO, Arity: -2,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"bitmap"},
+		Group: "bitmap", Since: "6.0.0", Summary: "Performs arbitrary read-only bitfield integer operations on strings."},
+	{Name: "bitop", Handler: handleBitOp, Arity: -4,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 2, LastKey: -1, Step: 1, Categories: []string{"bitmap"},
+		Group: "bitmap", Since: "2.6.0", Summary: "Performs bitwise operations on multiple strings, and stores the result."},
+	{Name: "bitpos", Handler: handleBitPos, Arity: -3,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"bitmap"},
+		Group: "bitmap", Since: "2.8.7", Summary: "Finds the first set (1) or clear (0) bit in a string."},
+	{Name: "getbit", Handler: handleGetBit, Arity: 3,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"bitmap"},
+		Group: "bitmap", Since: "2.2.0", Summary: "Returns a bit value by offset."},
+	{Name: "setbit", Handler: handleSetBit, Arity: 4,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"bitmap"},
+		Group: "bitmap", Since: "2.2.0", Summary: "Sets or clears the bit at offset of the string value. Creates the key if it doesn't exist."},
+
+	// hyperloglog
+	{Name: "pfadd", Handler: handlePFAdd, Arity: -2,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hyperloglog"},
+		Group: "hyperloglog", Since: "2.8.9", Summary: "Adds elements to a HyperLogLog key. Creates the key if it doesn't exist."},
+	{Name: "pfcount", Handler: handlePFCount, Arity: -2,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"hyperloglog"},
+		Group: "hyperloglog", Since: "2.8.9", Summary: "Returns the approximated cardinality of the set(s) observed by the HyperLogLog key(s)."},
+	{Name: "pfmerge", Handler: handlePFMerge, Arity: -2,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"hyperloglog"},
+		Group: "hyperloglog", Since: "2.8.9", Summary: "Merges one or more HyperLogLog values into a single key."},
+
+	// hash
+	{Name: "hdel", Handler: handleHDel, Arity: -3,
+		Flags: []string{flagWrite, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "2.0.0", Summary: "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain."},
+	{Name: "hexists", Handler: handleHExists, Arity: 3,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "2.0.0", Summary: "Determines whether a field exists in a hash."},
+	{Name: "hexpire", Handler: handleHExpire, Arity: -6,
+		Flags: []string{flagWrite, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "7.4.0", Summary: "Set expiry for hash field using relative time to expire (seconds)"},
+	{Name: "hexpireat", Handler: handleHExpireAt, Arity: -6,
+		Flags: []string{flagWrite, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "7.4.0", Summary: "Set expiry for hash field using an absolute Unix timestamp (seconds)"},
+	{Name: "hexpiretime", Handler: handleHExpireTime, Arity: -5,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "7.4.0", Summary: "Returns the expiration time of a hash field as a Unix timestamp, in seconds."},
+	{Name: "hget", Handler: handleHGet, Arity: 3,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "2.0.0", Summary: "Returns the value of a field in a hash."},
+	{Name: "hgetall", Handler: handleHGetAll, Arity: 2,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "2.0.0", Summary: "Returns all fields and values in a hash."},
+	{Name: "hincrby", Handler: handleHIncrBy, Arity: 4,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "2.0.0", Summary: "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist."},
+	{Name: "hincrbyfloat", Handler: handleHIncrByFloat, Arity: 4,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "2.6.0", Summary: "Increments the floating point value of a field by a number. Uses 0 as initial value if the field doesn't exist."},
+	{Name: "hkeys", Handler: handleHKeys, Arity: 2,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "2.0.0", Summary: "Returns all fields in a hash."},
+	{Name: "hlen", Handler: handleHLen, Arity: 2,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "2.0.0", Summary: "Returns the number of fields in a hash."},
+	{Name: "hmget", Handler: handleHMGet, Arity: -3,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "2.0.0", Summary: "Returns the values of all fields in a hash."},
+	{Name: "hmset", Handler: handleHMSet, Arity: -4,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "2.0.0", Summary: "Sets the values of multiple fields."},
+	{Name: "hpersist", Handler: handleHPersist, Arity: -5,
+		Flags: []string{flagWrite, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "7.4.0", Summary: "Removes the expiration time for each specified field"},
+	{Name: "hpexpire", Handler: handleHPExpire, Arity: -6,
+		Flags: []string{flagWrite, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "7.4.0", Summary: "Set expiry for hash field using relative time to expire (milliseconds)"},
+	{Name: "hpexpireat", Handler: handleHPExpireAt, Arity: -6,
+		Flags: []string{flagWrite, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "7.4.0", Summary: "Set expiry for hash field using an absolute Unix timestamp (milliseconds)"},
+	{Name: "hpexpiretime", Handler: handleHPExpireTime, Arity: -5,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "7.4.0", Summary: "Returns the expiration time of a hash field as a Unix timestamp, in msec."},
+	{Name: "hpttl", Handler: handleHPTTL, Arity: -5,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "7.4.0", Summary: "Returns the TTL in milliseconds of a hash field."},
+	{Name: "hrandfield", Handler: handleHRandField, Arity: -2,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "6.2.0", Summary: "Returns one or more random fields from a hash."},
+	{Name: "hscan", Handler: handleHScan, Arity: -3,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "2.8.0", Summary: "Iterates over fields and values of a hash."},
+	{Name: "hset", Handler: handleHSet, Arity: -4,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "2.0.0", Summary: "Creates or modifies the value of a field in a hash."},
+	{Name: "hsetnx", Handler: handleHSetNX, Arity: 4,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "2.0.0", Summary: "Sets the value of a field in a hash only when the field doesn't exist."},
+	{Name: "hstrlen", Handler: handleHStrLen, Arity: 3,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "3.2.0", Summary: "Returns the length of the value of a field."},
+	{Name: "httl", Handler: handleHTTL, Arity: -5,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "7.4.0", Summary: "Returns the TTL in seconds of a hash field."},
+	{Name: "hvals", Handler: handleHVals, Arity: 2,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"hash"},
+		Group: "hash", Since: "2.0.0", Summary: "Returns all values in a hash."},
+
+	// list
+	{Name: "blmove", Handler: handleBLMove, Arity: 6,
+		Flags: []string{flagWrite, flagDenyOOM, flagBlocking}, FirstKey: 1, LastKey: 2, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "6.2.0", Summary: "Pops an element from a list, pushes it to another list and returns it. Blocks until an element is available otherwise. Deletes the list if the last element was moved."},
+	{Name: "blmpop", Handler: handleBLMPop, Arity: -5,
+		Flags: []string{flagWrite, flagBlocking}, NumKeysIndex: 2, Categories: []string{"list"},
+		Group: "list", Since: "7.0.0", Summary: "Pops the first element from one of multiple lists. Blocks until an element is available otherwise. Deletes the list if the last element was popped."},
+	{Name: "blpop", Handler: handleBLPop, Arity: -3,
+		Flags: []string{flagWrite, flagBlocking}, FirstKey: 1, LastKey: -2, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "2.0.0", Summary: "Removes and returns the first element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped."},
+	{Name: "brpop", Handler: handleBRPop, Arity: -3,
+		Flags: []string{flagWrite, flagBlocking}, FirstKey: 1, LastKey: -2, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "2.0.0", Summary: "Removes and returns the last element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped."},
+	{Name: "lindex", Handler: handleLIndex, Arity: 3,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "1.0.0", Summary: "Returns an element from a list by its index."},
+	{Name: "linsert", Handler: handleLInsert, Arity: 5,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "2.2.0", Summary: "Inserts an element before or after another element in a list."},
+	{Name: "llen", Handler: handleLLen, Arity: 2,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "1.0.0", Summary: "Returns the length of a list."},
+	{Name: "lmove", Handler: handleLMove, Arity: 5,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: 2, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "6.2.0", Summary: "Returns an element after popping it from one list and pushing it to another. Deletes the list if the last element was moved."},
+	{Name: "lmpop", Handler: handleLMPop, Arity: -4,
+		Flags: []string{flagWrite}, NumKeysIndex: 1, Categories: []string{"list"},
+		Group: "list", Since: "7.0.0", Summary: "Returns multiple elements from a list after removing them. Deletes the list if the last element was popped."},
+	{Name: "lpop", Handler: handleLPop, Arity: -2,
+		Flags: []string{flagWrite, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "1.0.0", Summary: "Returns the first elements in a list after removing it. Deletes the list if the last element was popped."},
+	{Name: "lpos", Handler: handleLPos, Arity: -3,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "6.0.6", Summary: "Returns the index of matching elements in a list."},
+	{Name: "lpush", Handler: handleLPush, Arity: -3,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "1.0.0", Summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist."},
+	{Name: "lpushx", Handler: handleLPushX, Arity: -3,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "2.2.0", Summary: "Prepends one or more elements to a list only when the list exists."},
+	{Name: "lrange", Handler: handleLRange, Arity: 4,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "1.0.0", Summary: "Returns a range of elements from a list."},
+	{Name: "lrem", Handler: handleLRem, Arity: 4,
+		Flags: []string{flagWrite}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "1.0.0", Summary: "Removes elements from a list. Deletes the list if the last element was removed."},
+	{Name: "lset", Handler: handleLSet, Arity: 4,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "1.0.0", Summary: "Sets the value of an element in a list by its index."},
+	{Name: "ltrim", Handler: handleLTrim, Arity: 4,
+		Flags: []string{flagWrite}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "1.0.0", Summary: "Removes elements from both ends a list. Deletes the list if all elements were trimmed."},
+	{Name: "rpop", Handler: handleRPop, Arity: -2,
+		Flags: []string{flagWrite, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "1.0.0", Summary: "Returns and removes the last elements of a list. Deletes the list if the last element was popped."},
+	{Name: "rpoplpush", Handler: handleRPopLPush, Arity: 3,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: 2, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "1.2.0", Summary: "Returns the last element of a list after removing and pushing it to another list. Deletes the list if the last element was popped."},
+	{Name: "rpush", Handler: handleRPush, Arity: -3,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "1.0.0", Summary: "Appends one or more elements to a list. Creates the key if it doesn't exist."},
+	{Name: "rpushx", Handler: handleRPushX, Arity: -3,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"list"},
+		Group: "list", Since: "2.2.0", Summary: "Appends an element to a list only when the list exists."},
+
+	// set
+	{Name: "sadd", Handler: handleSAdd, Arity: -3,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "1.0.0", Summary: "Adds one or more members to a set. Creates the key if it doesn't exist."},
+	{Name: "scard", Handler: handleSCard, Arity: 2,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "1.0.0", Summary: "Returns the number of members in a set."},
+	{Name: "sdiff", Handler: handleSDiff, Arity: -2,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "1.0.0", Summary: "Returns the difference of multiple sets."},
+	{Name: "sdiffstore", Handler: handleSDiffStore, Arity: -3,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "1.0.0", Summary: "Stores the difference of multiple sets in a key."},
+	{Name: "sinter", Handler: handleSInter, Arity: -2,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "1.0.0", Summary: "Returns the intersect of multiple sets."},
+	{Name: "sintercard", Handler: handleSInterCard, Arity: -3,
+		Flags: []string{flagReadonly}, NumKeysIndex: 1, Categories: []string{"set"},
+		Group: "set", Since: "7.0.0", Summary: "Returns the number of members of the intersect of multiple sets."},
+	{Name: "sinterstore", Handler: handleSInterStore, Arity: -3,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "1.0.0", Summary: "Stores the intersect of multiple sets in a key."},
+	{Name: "sismember", Handler: handleSIsMember, Arity: 3,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "1.0.0", Summary: "Determines whether a member belongs to a set."},
+	{Name: "smembers", Handler: handleSMembers, Arity: 2,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "1.0.0", Summary: "Returns all members of a set."},
+	{Name: "smismember", Handler: handleSMIsMember, Arity: -3,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "6.2.0", Summary: "Determines whether multiple members belong to a set."},
+	{Name: "smove", Handler: handleSMove, Arity: 4,
+		Flags: []string{flagWrite, flagFast}, FirstKey: 1, LastKey: 2, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "1.0.0", Summary: "Moves a member from one set to another."},
+	{Name: "spop", Handler: handleSPop, Arity: -2,
+		Flags: []string{flagWrite, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "1.0.0", Summary: "Returns one or more random members from a set after removing them. Deletes the set if the last member was popped."},
+	{Name: "srandmember", Handler: handleSRandMember, Arity: -2,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "1.0.0", Summary: "Get one or multiple random members from a set"},
+	{Name: "srem", Handler: handleSRem, Arity: -3,
+		Flags: []string{flagWrite, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "1.0.0", Summary: "Removes one or more members from a set. Deletes the set if the last member was removed."},
+	{Name: "sscan", Handler: handleSScan, Arity: -3,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "2.8.0", Summary: "Iterates over members of a set."},
+	{Name: "sunion", Handler: handleSUnion, Arity: -2,
+		Flags: []string{flagReadonly}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "1.0.0", Summary: "Returns the union of multiple sets."},
+	{Name: "sunionstore", Handler: handleSUnionStore, Arity: -3,
+		Flags: []string{flagWrite, flagDenyOOM}, FirstKey: 1, LastKey: -1, Step: 1, Categories: []string{"set"},
+		Group: "set", Since: "1.0.0", Summary: "Stores the union of multiple sets in a key."},
+
+	// sorted set
+	{Name: "zadd", Handler: handleZAdd, Arity: -4,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"sortedset"},
+		Group: "sorted-set", Since: "1.2.0", Summary: "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist."},
+	{Name: "zcard", Handler: handleZCard, Arity: 2,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"sortedset"},
+		Group: "sorted-set", Since: "1.2.0", Summary: "Returns the number of members in a sorted set."},
+	{Name: "zincrby", Handler: handleZIncrBy, Arity: 4,
+		Flags: []string{flagWrite, flagDenyOOM, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"sortedset"},
+		Group: "sorted-set", Since: "1.2.0", Summary: "Increments the score of a member in a sorted set."},
+	{Name: "zmscore", Handler: handleZMScore, Arity: -3,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"sortedset"},
+		Group: "sorted-set", Since: "6.2.0", Summary: "Returns the score of one or more members in a sorted set."},
+	{Name: "zrem", Handler: handleZRem, Arity: -3,
+		Flags: []string{flagWrite, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"sortedset"},
+		Group: "sorted-set", Since: "1.2.0", Summary: "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed."},
+	{Name: "zscore", Handler: handleZScore, Arity: 3,
+		Flags: []string{flagReadonly, flagFast}, FirstKey: 1, LastKey: 1, Step: 1, Categories: []string{"sortedset"},
+		Group: "sorted-set", Since: "1.2.0", Summary: "Returns the score of a member in a sorted set."},
+
+	// transactions
+	{Name: "discard", Handler: handleDiscard, Arity: 1,
+		Flags: []string{flagNoScript, flagLoading, flagStale, flagFast}, Categories: []string{"transaction"},
+		Group: "transactions", Since: "2.0.0", Summary: "Discards a transaction."},
+	{Name: "exec", Handler: handleExec, Arity: 1,
+		Flags: []string{flagNoScript, flagLoading, flagStale}, Categories: []string{"transaction"},
+		Group: "transactions", Since: "1.2.0", Summary: "Executes all commands in a transaction."},
+	{Name: "multi", Handler: handleMulti, Arity: 1,
+		Flags: []string{flagNoScript, flagLoading, flagStale, flagFast}, Categories: []string{"transaction"},
+		Group: "transactions", Since: "1.2.0", Summary: "Starts a transaction."},
+}
+
+// lookupCommand 不区分大小写地查找命令，带子命令的命令会继续按第二个参数查找子命令
+func lookupCommand(args [][]byte) (*Command, error) {
+	name := strings.ToLower(string(args[0]))
+	cmd, ok := commands[name]
+	if !ok {
+		return nil, unknownCommandError(args)
+	}
+	if len(cmd.Subcommands) == 0 || len(args) < 2 {
+		return cmd, nil
+	}
+	subName := name + "|" + strings.ToLower(string(args[1]))
+	for _, sub := range cmd.Subcommands {
+		if sub.Name == subName {
+			return sub, nil
+		}
+	}
+	return nil, fmt.Errorf("ERR unknown subcommand '%s'. Try %s HELP.", args[1], strings.ToUpper(name))
+}
+
+// dispatch 查找命令、检查参数个数并调用处理函数，MULTI 之后的命令先排队，EXEC 时再执行
+func dispatch(c *Client, args [][]byte, store *store.BadgerStore) {
+	cmd, err := lookupCommand(args)
+	if err == nil && !cmd.arityOK(len(args)) {
+		err = errWrongArgs(cmd.Name)
+	}
+	if err != nil {
+		if c.multi != nil {
+			c.multi.dirty = true
+		}
+		c.WriteError(err)
+		return
+	}
+	if c.multi != nil && cmd.queuesInMulti() {
+		c.multi.commands = append(c.multi.commands, queuedCommand{cmd: cmd, args: args[1:]})
+		c.WriteSimpleString("QUEUED")
+		return
+	}
+	// EXEC 在处理函数中自己持有写锁
+	if cmd.Name != "exec" {
+		execLock.RLock()
+		defer execLock.RUnlock()
+	}
+	cmd.Handler(c, args[1:], store)
+}
+
+func unknownCommandError(args [][]byte) error {
+	var sb strings.Builder
+	for _, arg := range args[1:] {
+		if sb.Len() >= 128 {
+			break
+		}
+		fmt.Fprintf(&sb, "'%s' ", arg)
+	}
+	return fmt.Errorf("ERR unknown command '%s', with args beginning with: %s", args[0], sb.String())
+}
+
+func (cmd *Command) arityOK(argc int) bool {
+	if cmd.Arity >= 0 {
+		return argc == cmd.Arity
+	}
+	return argc >= -cmd.Arity
+}
+
+func (cmd *Command) hasFlag(flag string) bool {
+	for _, f := range cmd.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// keyPositions 返回命令参数中 key 的下标（下标从命令名算起）
+func (cmd *Command) keyPositions(args [][]byte) ([]int, error) {
+	var positions []int
+	if cmd.NumKeysIndex > 0 {
+		if cmd.NumKeysIndex >= len(args) {
+			return nil, nil
+		}
+		numKeys, err := strconv.Atoi(string(args[cmd.NumKeysIndex]))
+		if err != nil || numKeys <= 0 || cmd.NumKeysIndex+numKeys >= len(args) {
+			return nil, fmt.Errorf("ERR Invalid arguments specified for command")
+		}
+		for i := 1; i <= numKeys; i++ {
+			positions = append(positions, cmd.NumKeysIndex+i)
+		}
+		return positions, nil
+	}
+	if cmd.FirstKey == 0 {
+		return nil, nil
+	}
+	last := cmd.LastKey
+	if last < 0 {
+		last = len(args) + last
+	}
+	for i := cmd.FirstKey; i <= last && i < len(args); i += cmd.Step {
+		positions = append(positions, i)
+	}
+	return positions, nil
+}
+
+// flagList 返回 COMMAND 中展示的标志，key 位置依赖参数的命令额外带上 movablekeys
+func (cmd *Command) flagList() Set {
+	flags := make(Set, 0, len(cmd.Flags)+1)
+	for _, f := range cmd.Flags {
+		flags = append(flags, f)
+	}
+	if cmd.NumKeysIndex > 0 {
+		flags = append(flags, flagMovableKeys)
+	}
+	return flags
+}
+
+// aclCategories 返回命令的 ACL 分类，读写、快慢和阻塞分类由标志推导
+func (cmd *Command) aclCategories() Set {
+	var categories Set
+	if cmd.hasFlag(flagWrite) {
+		categories = append(categories, "@write")
+	}
+	if cmd.hasFlag(flagReadonly) {
+		categories = append(categories, "@read")
+	}
+	for _, category := range cmd.Categories {
+		categories = append(categories, "@"+category)
+	}
+	if cmd.hasFlag(flagAdmin) {
+		categories = append(categories, "@admin", "@dangerous")
+	}
+	if cmd.hasFlag(flagFast) {
+		categories = append(categories, "@fast")
+	} else {
+		categories = append(categories, "@slow")
+	}
+	if cmd.hasFlag(flagBlocking) {
+		categories = append(categories, "@blocking")
+	}
+	return categories
+}
+
+// keySpecs 按 Redis 7 的 key specs 格式描述 key 的位置
+func (cmd *Command) keySpecs() []interface{} {
+	if cmd.FirstKey == 0 && cmd.NumKeysIndex == 0 {
+		return []interface{}{}
+	}
+	specFlags := []interface{}{"RO", "ACCESS"}
+	if cmd.hasFlag(flagWrite) {
+		specFlags = []interface{}{"RW", "UPDATE"}
+	}
+
+	var beginSearch, findKeys Map
+	if cmd.NumKeysIndex > 0 {
+		beginSearch = Map{
+			{Key: "type", Value: "index"},
+			{Key: "spec", Value: Map{{Key: "index", Value: cmd.NumKeysIndex}}},
+		}
+		findKeys = Map{
+			{Key: "type", Value: "keynum"},
+			{Key: "spec", Value: Map{
+				{Key: "keynumidx", Value: 0},
+				{Key: "firstkey", Value: 1},
+				{Key: "keystep", Value: 1},
+			}},
+		}
+	} else {
+		lastKey := cmd.LastKey
+		if lastKey >= 0 {
+			lastKey -= cmd.FirstKey
+		}
+		beginSearch = Map{
+			{Key: "type", Value: "index"},
+			{Key: "spec", Value: Map{{Key: "index", Value: cmd.FirstKey}}},
+		}
+		findKeys = Map{
+			{Key: "type", Value: "range"},
+			{Key: "spec", Value: Map{
+				{Key: "lastkey", Value: lastKey},
+				{Key: "keystep", Value: cmd.Step},
+				{Key: "limit", Value: 0},
+			}},
+		}
+	}
+	return []interface{}{Map{
+		{Key: "flags", Value: Set(specFlags)},
+		{Key: "begin_search", Value: beginSearch},
+		{Key: "find_keys", Value: findKeys},
+	}}
+}
+
+// info 返回 COMMAND / COMMAND INFO 中单个命令的描述
+func (cmd *Command) info() []interface{} {
+	subcommands := make([]interface{}, 0, len(cmd.Subcommands))
+	for _, sub := range cmd.Subcommands {
+		subcommands = append(subcommands, sub.info())
+	}
+	firstKey, lastKey, step := cmd.FirstKey, cmd.LastKey, cmd.Step
+	if cmd.NumKeysIndex > 0 {
+		// 与 Redis 一致，key 个数不固定的命令在旧式的 first/last/step 中填 0
+		firstKey, lastKey, step = 0, 0, 0
+	}
+	return []interface{}{
+		[]byte(cmd.Name),
+		cmd.Arity,
+		cmd.flagList(),
+		firstKey,
+		lastKey,
+		step,
+		cmd.aclCategories(),
+		[]interface{}{},
+		cmd.keySpecs(),
+		subcommands,
+	}
+}
+
+// docs 返回 COMMAND DOCS 中单个命令的文档
+func (cmd *Command) docs() Map {
+	doc := Map{
+		{Key: "summary", Value: []byte(cmd.Summary)},
+		{Key: "since", Value: []byte(cmd.Since)},
+		{Key: "group", Value: []byte(cmd.Group)},
+	}
+	if len(cmd.Subcommands) > 0 {
+		subDocs := make(Map, 0, len(cmd.Subcommands))
+		for _, sub := range cmd.Subcommands {
+			subDocs = append(subDocs, MapItem{Key: []byte(sub.Name), Value: sub.docs()})
+		}
+		doc = append(doc, MapItem{Key: "subcommands", Value: subDocs})
+	}
+	return doc
+}
+
+// sortedCommands 按名称排序返回所有命令，保证 COMMAND 的输出稳定
+func sortedCommands() []*Command {
+	list := make([]*Command, 0, len(commands))
+	for _, cmd := range commands {
+		list = append(list, cmd)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// handleCommand 实现不带子命令的 COMMAND，返回所有命令的描述
+func handleCommand(c *Client, args [][]byte, store *store.BadgerStore) {
+	reply := make([]interface{}, 0, len(commands))
+	for _, cmd := range sortedCommands() {
+		reply = append(reply, cmd.info())
+	}
+	c.WriteValue(reply)
+}
+
+// handleCommandCount 实现 COMMAND COUNT
+func handleCommandCount(c *Client, args [][]byte, store *store.BadgerStore) {
+	c.WriteInt64(int64(len(commands)))
+}
+
+// handleCommandInfo 实现 COMMAND INFO [command-name ...]，不存在的命令返回空值
+func handleCommandInfo(c *Client, args [][]byte, store *store.BadgerStore) {
+	names := args[1:]
+	if len(names) == 0 {
+		handleCommand(c, nil, store)
+		return
+	}
+	reply := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		if cmd, ok := commands[strings.ToLower(string(name))]; ok {
+			reply = append(reply, cmd.info())
+		} else {
+			reply = append(reply, Null)
+		}
+	}
+	c.WriteValue(reply)
+}
+
+// handleCommandDocs 实现 COMMAND DOCS [command-name ...]，不存在的命令直接忽略
+func handleCommandDocs(c *Client, args [][]byte, store *store.BadgerStore) {
+	var list []*Command
+	if names := args[1:]; len(names) > 0 {
+		for _, name := range names {
+			if cmd, ok := commands[strings.ToLower(string(name))]; ok {
+				list = append(list, cmd)
+			}
+		}
+	} else {
+		list = sortedCommands()
+	}
+	reply := make(Map, 0, len(list))
+	for _, cmd := range list {
+		reply = append(reply, MapItem{Key: []byte(cmd.Name), Value: cmd.docs()})
+	}
+	c.WriteValue(reply)
+}
+
+// handleCommandGetKeys 实现 COMMAND GETKEYS command [arg ...]
+func handleCommandGetKeys(c *Client, args [][]byte, store *store.BadgerStore) {
+	cmdArgs := args[1:]
+	cmd, ok := commands[strings.ToLower(string(cmdArgs[0]))]
+	if !ok {
+		c.WriteError(fmt.Errorf("ERR Invalid command specified"))
+		return
+	}
+	if !cmd.arityOK(len(cmdArgs)) {
+		c.WriteError(fmt.Errorf("ERR Invalid number of arguments specified for command"))
+		return
+	}
+	positions, err := cmd.keyPositions(cmdArgs)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if len(positions) == 0 {
+		c.WriteError(fmt.Errorf("ERR The command has no key arguments"))
+		return
+	}
+	keys := make([]interface{}, 0, len(positions))
+	for _, pos := range positions {
+		keys = append(keys, cmdArgs[pos])
+	}
+	c.WriteValue(keys)
+}
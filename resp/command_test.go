@@ -0,0 +1,60 @@
+package resp
+
+import (
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func toArgs(args ...string) [][]byte {
+	result := make([][]byte, len(args))
+	for i, arg := range args {
+		result[i] = []byte(arg)
+	}
+	return result
+}
+
+func TestLookupCommand(t *testing.T) {
+	cmd, err := lookupCommand(toArgs("LpUsH", "k", "v"))
+	assert.NoError(t, err)
+	assert.Equal(t, "lpush", cmd.Name)
+	assert.True(t, cmd.arityOK(3))
+	assert.False(t, cmd.arityOK(2))
+
+	cmd, err = lookupCommand(toArgs("command", "GetKeys"))
+	assert.NoError(t, err)
+	assert.Equal(t, "command|getkeys", cmd.Name)
+
+	_, err = lookupCommand(toArgs("nosuchcmd", "a"))
+	assert.Equal(t, "ERR unknown command 'nosuchcmd', with args beginning with: 'a' ", err.Error())
+
+	_, err = lookupCommand(toArgs("command", "nope"))
+	assert.Equal(t, "ERR unknown subcommand 'nope'. Try COMMAND HELP.", err.Error())
+}
+
+func TestCommandKeyPositions(t *testing.T) {
+	positions, err := commands["set"].keyPositions(toArgs("set", "k", "v"))
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []int{1}, positions)
+
+	multi := &Command{Name: "mset", Arity: -3, FirstKey: 1, LastKey: -1, Step: 2}
+	positions, err = multi.keyPositions(toArgs("mset", "a", "1", "b", "2"))
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []int{1, 3}, positions)
+
+	numKeys := &Command{Name: "sintercard", Arity: -3, NumKeysIndex: 1}
+	positions, err = numKeys.keyPositions(toArgs("sintercard", "2", "a", "b", "LIMIT", "1"))
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []int{2, 3}, positions)
+	_, err = numKeys.keyPositions(toArgs("sintercard", "3", "a", "b"))
+	assert.Error(t, err)
+}
+
+func TestCommandInfo(t *testing.T) {
+	info := commands["lpush"].info()
+	assert.Equal(t, 10, len(info))
+	assert.Equal(t, "lpush", string(info[0].([]byte)))
+	assert.Equal(t, -3, info[1])
+	assert.DeepEqual(t, Set{"write", "denyoom", "fast"}, info[2])
+	assert.DeepEqual(t, Set{"@write", "@list", "@fast"}, info[6])
+}
@@ -0,0 +1,39 @@
+package resp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 回复给客户端的通用错误，文本与 Redis 保持一致
+var (
+	errSyntax        = errors.New("ERR syntax error")
+	errNotInteger    = errors.New("ERR value is not an integer or out of range")
+	errProtoVersion  = errors.New("ERR Protocol version is not an integer or out of range")
+	errNoProto       = errors.New("NOPROTO unsupported protocol version")
+	errWrongPass     = errors.New("WRONGPASS invalid username-password pair or user is disabled.")
+	errClientName    = errors.New("ERR Client names cannot contain spaces, newlines or special characters.")
+	errInvalidCursor = errors.New("ERR invalid cursor")
+	errNotPositive   = errors.New("ERR value is out of range, must be positive")
+	errNumKeys       = errors.New("ERR numkeys should be greater than 0")
+	// errValueOutOfRange 用于 HRANDFIELD、SRANDMEMBER 等取值范围为 [-LONG_MAX, LONG_MAX] 的参数
+	errValueOutOfRange = errors.New("ERR value is out of range, must be between -9223372036854775807 and 9223372036854775807")
+
+	errTimeoutNotFloat   = errors.New("ERR timeout is not a float or out of range")
+	errTimeoutNegative   = errors.New("ERR timeout is negative")
+	errTimeoutOutOfRange = errors.New("ERR timeout is out of range")
+
+	errMultiNested         = errors.New("ERR MULTI calls can not be nested")
+	errExecWithoutMulti    = errors.New("ERR EXEC without MULTI")
+	errDiscardWithoutMulti = errors.New("ERR DISCARD without MULTI")
+	errExecAbort           = errors.New("EXECABORT Transaction discarded because of previous errors.")
+
+	errZAddNXXX   = errors.New("ERR XX and NX options at the same time are not compatible")
+	errZAddGTLTNX = errors.New("ERR GT, LT, and/or NX options at the same time are not compatible")
+	errZAddIncr   = errors.New("ERR INCR option supports a single increment-element pair")
+)
+
+// errWrongArgs 返回参数个数错误，arity 之外的参数个数校验（如 MSET 要求成对出现）也使用它
+func errWrongArgs(cmdName string) error {
+	return fmt.Errorf("ERR wrong number of arguments for '%s' command", cmdName)
+}
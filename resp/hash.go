@@ -0,0 +1,422 @@
+package resp
+
+import (
+	"PumbaaDB/store"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errFieldsMissing     = errors.New("ERR Mandatory argument FIELDS is missing or not at the right position")
+	errNumFields         = errors.New("ERR Parameter `numFields` should be greater than 0")
+	errNumFieldsMismatch = errors.New("ERR The `numfields` parameter must match the number of arguments")
+	errNegativeExpire    = errors.New("ERR invalid expire time, must be >= 0")
+)
+
+// handleHSet 实现 HSET key field value [field value ...]，回复新增的字段数量
+func handleHSet(c *Client, args [][]byte, store *store.BadgerStore) {
+	if len(args)%2 != 1 {
+		c.WriteError(errWrongArgs("hset"))
+		return
+	}
+	added, err := store.HSet(args[0], args[1:])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(added)
+}
+
+// handleHMSet 实现 HMSET key field value [field value ...]，与 HSET 相同但回复 OK
+func handleHMSet(c *Client, args [][]byte, store *store.BadgerStore) {
+	if len(args)%2 != 1 {
+		c.WriteError(errWrongArgs("hmset"))
+		return
+	}
+	if _, err := store.HSet(args[0], args[1:]); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteOK()
+}
+
+// handleHSetNX 实现 HSETNX key field value
+func handleHSetNX(c *Client, args [][]byte, store *store.BadgerStore) {
+	written, err := store.HSetNX(args[0], args[1], args[2])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if written {
+		c.WriteInt64(1)
+	} else {
+		c.WriteInt64(0)
+	}
+}
+
+// handleHGet 实现 HGET key field
+func handleHGet(c *Client, args [][]byte, store *store.BadgerStore) {
+	value, err := store.HGet(args[0], args[1])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteBulk(value)
+}
+
+// handleHMGet 实现 HMGET key field [field ...]
+func handleHMGet(c *Client, args [][]byte, store *store.BadgerStore) {
+	values, err := store.HMGet(args[0], args[1:])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteBulkArray(values)
+}
+
+// handleHDel 实现 HDEL key field [field ...]，回复实际删除的字段数量
+func handleHDel(c *Client, args [][]byte, store *store.BadgerStore) {
+	deleted, err := store.HDel(args[0], args[1:]...)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(deleted)
+}
+
+// handleHLen 实现 HLEN key
+func handleHLen(c *Client, args [][]byte, store *store.BadgerStore) {
+	count, err := store.HLen(args[0])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(count)
+}
+
+// handleHGetAll 实现 HGETALL，RESP3 连接回复 map，RESP2 连接回复 field/value 交替的数组
+func handleHGetAll(c *Client, args [][]byte, store *store.BadgerStore) {
+	fields, err := store.HGetAll(args[0])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteMap(fields)
+}
+
+// handleHIncrBy 实现 HINCRBY key field increment
+func handleHIncrBy(c *Client, args [][]byte, store *store.BadgerStore) {
+	delta, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	result, err := store.HIncrBy(args[0], args[1], delta)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(result)
+}
+
+// handleHIncrByFloat 实现 HINCRBYFLOAT key field increment
+func handleHIncrByFloat(c *Client, args [][]byte, store *store.BadgerStore) {
+	result, err := store.HIncrByFloat(args[0], args[1], args[2])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteBulk(result)
+}
+
+// handleHExists 实现 HEXISTS key field
+func handleHExists(c *Client, args [][]byte, store *store.BadgerStore) {
+	exists, err := store.HExists(args[0], args[1])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if exists {
+		c.WriteInt64(1)
+	} else {
+		c.WriteInt64(0)
+	}
+}
+
+// handleHStrLen 实现 HSTRLEN key field
+func handleHStrLen(c *Client, args [][]byte, store *store.BadgerStore) {
+	length, err := store.HStrLen(args[0], args[1])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(length)
+}
+
+// handleHKeys 实现 HKEYS key
+func handleHKeys(c *Client, args [][]byte, store *store.BadgerStore) {
+	fields, err := store.HKeys(args[0])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteBulkArray(fields)
+}
+
+// handleHVals 实现 HVALS key
+func handleHVals(c *Client, args [][]byte, store *store.BadgerStore) {
+	values, err := store.HVals(args[0])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteBulkArray(values)
+}
+
+// handleHRandField 实现 HRANDFIELD key [count [WITHVALUES]]，
+// 带 WITHVALUES 时 RESP3 连接回复 [field, value] 对组成的数组，RESP2 连接回复交替的数组
+func handleHRandField(c *Client, args [][]byte, store *store.BadgerStore) {
+	if len(args) == 1 {
+		fields, _, err := store.HRandField(args[0], 1, false)
+		if err != nil {
+			c.WriteError(err)
+			return
+		}
+		if len(fields) == 0 {
+			c.WriteNullBulk()
+			return
+		}
+		c.WriteBulk(fields[0])
+		return
+	}
+	if len(args) > 3 || (len(args) == 3 && !strings.EqualFold(string(args[2]), "WITHVALUES")) {
+		c.WriteError(errSyntax)
+		return
+	}
+	count, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	if count == math.MinInt64 {
+		c.WriteError(errValueOutOfRange)
+		return
+	}
+	withValues := len(args) == 3
+	fields, values, err := store.HRandField(args[0], count, withValues)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if !withValues {
+		c.WriteBulkArray(fields)
+		return
+	}
+	if c.Proto() == ProtoRESP3 {
+		c.WriteArrayHeader(len(fields))
+		for i := range fields {
+			c.WriteBulkArray([][]byte{fields[i], values[i]})
+		}
+		return
+	}
+	c.WriteArrayHeader(len(fields) * 2)
+	for i := range fields {
+		c.WriteBulk(fields[i])
+		c.WriteBulk(values[i])
+	}
+}
+
+// handleHScan 实现 HSCAN key cursor [MATCH pattern] [COUNT count] [NOVALUES]
+func handleHScan(c *Client, args [][]byte, store *store.BadgerStore) {
+	cursor, err := strconv.ParseUint(string(args[1]), 10, 64)
+	if err != nil {
+		c.WriteError(errInvalidCursor)
+		return
+	}
+	opts, err := parseScanOptions(args[2:], true)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	next, items, err := store.HScan(args[0], cursor, opts.match, opts.count, !opts.noValues)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	writeScanReply(c, next, items)
+}
+
+// hashMaxExpireAt 是字段过期时间的上限，与 Redis 的 HFE_MAX_ABS_TIME_MSEC 一致
+const hashMaxExpireAt = 1<<48 - 1
+
+// parseHashFields 解析从 args[at] 开始的 FIELDS numfields field [field ...]
+func parseHashFields(args [][]byte, at int) ([][]byte, error) {
+	if at >= len(args) || !strings.EqualFold(string(args[at]), "FIELDS") {
+		return nil, errFieldsMissing
+	}
+	if at+1 >= len(args) {
+		return nil, errNumFields
+	}
+	n, err := strconv.ParseInt(string(args[at+1]), 10, 64)
+	if err != nil || n < 1 {
+		return nil, errNumFields
+	}
+	if n != int64(len(args)-at-2) {
+		return nil, errNumFieldsMismatch
+	}
+	return args[at+2:], nil
+}
+
+// parseHashExpireAt 把 HEXPIRE 系列命令的时间参数换算为毫秒时间戳，与 SET 不同，0 是合法的过期时间
+func parseHashExpireAt(unit string, arg []byte, cmdName string) (int64, error) {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	if n < 0 {
+		return 0, errNegativeExpire
+	}
+	invalid := fmt.Errorf("ERR invalid expire time in '%s' command", cmdName)
+	if unit == expireEX || unit == expireEXAT {
+		if n > hashMaxExpireAt/1000 {
+			return 0, invalid
+		}
+		n *= 1000
+	}
+	if n > hashMaxExpireAt {
+		return 0, invalid
+	}
+	if unit == expireEX || unit == expirePX {
+		n += time.Now().UnixMilli()
+		if n > hashMaxExpireAt {
+			return 0, invalid
+		}
+	}
+	return n, nil
+}
+
+// handleHExpire 实现 HEXPIRE key seconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+func handleHExpire(c *Client, args [][]byte, store *store.BadgerStore) {
+	hashExpire(c, args, store, expireEX, "hexpire")
+}
+
+// handleHPExpire 实现 HPEXPIRE key milliseconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+func handleHPExpire(c *Client, args [][]byte, store *store.BadgerStore) {
+	hashExpire(c, args, store, expirePX, "hpexpire")
+}
+
+// handleHExpireAt 实现 HEXPIREAT key unix-time-seconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+func handleHExpireAt(c *Client, args [][]byte, store *store.BadgerStore) {
+	hashExpire(c, args, store, expireEXAT, "hexpireat")
+}
+
+// handleHPExpireAt 实现 HPEXPIREAT key unix-time-milliseconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+func handleHPExpireAt(c *Client, args [][]byte, store *store.BadgerStore) {
+	hashExpire(c, args, store, expirePXAT, "hpexpireat")
+}
+
+func hashExpire(c *Client, args [][]byte, s *store.BadgerStore, unit, cmdName string) {
+	expireAt, err := parseHashExpireAt(unit, args[1], cmdName)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	cond, at := store.ExpireAlways, 2
+	switch strings.ToUpper(string(args[2])) {
+	case "NX":
+		cond, at = store.ExpireNX, 3
+	case "XX":
+		cond, at = store.ExpireXX, 3
+	case "GT":
+		cond, at = store.ExpireGT, 3
+	case "LT":
+		cond, at = store.ExpireLT, 3
+	}
+	fields, err := parseHashFields(args, at)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	results, err := s.HExpire(args[0], expireAt, cond, fields)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	writeInt64Array(c, results)
+}
+
+// handleHPersist 实现 HPERSIST key FIELDS numfields field [field ...]
+func handleHPersist(c *Client, args [][]byte, store *store.BadgerStore) {
+	fields, err := parseHashFields(args, 1)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	results, err := store.HPersist(args[0], fields)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	writeInt64Array(c, results)
+}
+
+// handleHTTL 实现 HTTL key FIELDS numfields field [field ...]
+func handleHTTL(c *Client, args [][]byte, store *store.BadgerStore) {
+	hashTTL(c, args, store, true, true)
+}
+
+// handleHPTTL 实现 HPTTL key FIELDS numfields field [field ...]
+func handleHPTTL(c *Client, args [][]byte, store *store.BadgerStore) {
+	hashTTL(c, args, store, true, false)
+}
+
+// handleHExpireTime 实现 HEXPIRETIME key FIELDS numfields field [field ...]
+func handleHExpireTime(c *Client, args [][]byte, store *store.BadgerStore) {
+	hashTTL(c, args, store, false, true)
+}
+
+// handleHPExpireTime 实现 HPEXPIRETIME key FIELDS numfields field [field ...]
+func handleHPExpireTime(c *Client, args [][]byte, store *store.BadgerStore) {
+	hashTTL(c, args, store, false, false)
+}
+
+// hashTTL 回复字段的剩余时间（relative）或过期时间戳，seconds 为 true 时以秒为单位并向上取整
+func hashTTL(c *Client, args [][]byte, s *store.BadgerStore, relative, seconds bool) {
+	fields, err := parseHashFields(args, 1)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	results, err := s.HExpireTime(args[0], fields)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	var base int64
+	if relative {
+		base = time.Now().UnixMilli()
+	}
+	for i, expireAt := range results {
+		if expireAt < 0 {
+			continue
+		}
+		n := max(expireAt-base, 0)
+		if seconds {
+			n = (n + 999) / 1000
+		}
+		results[i] = n
+	}
+	writeInt64Array(c, results)
+}
+
+// writeInt64Array 回复由整数组成的数组
+func writeInt64Array(c *Client, items []int64) {
+	c.WriteArrayHeader(len(items))
+	for _, item := range items {
+		c.WriteInt64(item)
+	}
+}
@@ -0,0 +1,38 @@
+package resp
+
+import (
+	"PumbaaDB/store"
+)
+
+// handlePFAdd 实现 PFADD key [element [element ...]]
+func handlePFAdd(c *Client, args [][]byte, store *store.BadgerStore) {
+	updated, err := store.PFAdd(args[0], args[1:])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if updated {
+		c.WriteInt64(1)
+	} else {
+		c.WriteInt64(0)
+	}
+}
+
+// handlePFCount 实现 PFCOUNT key [key ...]
+func handlePFCount(c *Client, args [][]byte, store *store.BadgerStore) {
+	card, err := store.PFCount(args)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(card)
+}
+
+// handlePFMerge 实现 PFMERGE destkey [sourcekey [sourcekey ...]]
+func handlePFMerge(c *Client, args [][]byte, store *store.BadgerStore) {
+	if err := store.PFMerge(args[0], args[1:]); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteOK()
+}
@@ -2,49 +2,467 @@ package resp
 
 import (
 	"PumbaaDB/store"
-	"fmt"
-	"net"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
 )
 
-func handleLPush(conn net.Conn, args [][]byte, store *store.BadgerStore) {
-	if len(args) < 2 {
-		conn.Write(Encode(fmt.Errorf("ERR wrong number of arguments")))
-		return
-	}
+var (
+	errLPosRank   = errors.New("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+	errLPosCount  = errors.New("ERR COUNT can't be negative")
+	errLPosMaxLen = errors.New("ERR MAXLEN can't be negative")
+	errCount      = errors.New("ERR count should be greater than 0")
+)
+
+func handleLPush(c *Client, args [][]byte, store *store.BadgerStore) {
 	key := args[0]
 	values := args[1:]
 	length, err := store.LPush(key, values...)
 	if err != nil {
-		conn.Write(Encode(err))
+		c.WriteError(err)
 	} else {
-		conn.Write(Encode(length))
+		blocking.signal(key)
+		c.WriteInt64(length)
 	}
 }
 
-func handleRPop(conn net.Conn, args [][]byte, store *store.BadgerStore) {
-	if len(args) != 1 {
-		conn.Write(Encode(fmt.Errorf("ERR wrong number of arguments")))
-		return
+// handleRPush 实现 RPUSH key element [element ...]，回复推入后的列表长度
+func handleRPush(c *Client, args [][]byte, store *store.BadgerStore) {
+	length, err := store.RPush(args[0], args[1:]...)
+	if err != nil {
+		c.WriteError(err)
+	} else {
+		if length > 0 {
+			blocking.signal(args[0])
+		}
+		c.WriteInt64(length)
+	}
+}
+
+// handleLPushX 实现 LPUSHX key element [element ...]，列表不存在时回复 0
+func handleLPushX(c *Client, args [][]byte, store *store.BadgerStore) {
+	length, err := store.LPushX(args[0], args[1:]...)
+	if err != nil {
+		c.WriteError(err)
+	} else {
+		if length > 0 {
+			blocking.signal(args[0])
+		}
+		c.WriteInt64(length)
 	}
-	value, err := store.RPop(args[0])
+}
+
+// handleRPushX 实现 RPUSHX key element [element ...]，列表不存在时回复 0
+func handleRPushX(c *Client, args [][]byte, store *store.BadgerStore) {
+	length, err := store.RPushX(args[0], args[1:]...)
 	if err != nil {
-		conn.Write(Encode(err))
-	} else if value == nil {
-		conn.Write(Encode(nil))
+		c.WriteError(err)
 	} else {
-		conn.Write(Encode(value))
+		if length > 0 {
+			blocking.signal(args[0])
+		}
+		c.WriteInt64(length)
 	}
 }
 
-func handleLLen(conn net.Conn, args [][]byte, store *store.BadgerStore) {
-	if len(args) != 1 {
-		conn.Write(Encode(fmt.Errorf("ERR wrong number of arguments")))
+// handleLPop 实现 LPOP key [count]
+func handleLPop(c *Client, args [][]byte, store *store.BadgerStore) {
+	listPop(c, args, store, true, "lpop")
+}
+
+// handleRPop 实现 RPOP key [count]
+func handleRPop(c *Client, args [][]byte, store *store.BadgerStore) {
+	listPop(c, args, store, false, "rpop")
+}
+
+// listPop 不带 count 时回复单个元素，带 count 时回复数组，列表不存在时回复空数组（null array）
+func listPop(c *Client, args [][]byte, s *store.BadgerStore, left bool, cmdName string) {
+	if len(args) > 2 {
+		c.WriteError(errWrongArgs(cmdName))
 		return
 	}
+	if len(args) == 1 {
+		pop := s.RPop
+		if left {
+			pop = s.LPop
+		}
+		value, err := pop(args[0])
+		if err != nil {
+			c.WriteError(err)
+		} else {
+			c.WriteBulk(value)
+		}
+		return
+	}
+
+	count, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	if count < 0 {
+		c.WriteError(errNotPositive)
+		return
+	}
+	popCount := s.RPopCount
+	if left {
+		popCount = s.LPopCount
+	}
+	values, err := popCount(args[0], uint64(count))
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if values == nil {
+		c.WriteNullArray()
+		return
+	}
+	c.WriteBulkArray(values)
+}
+
+func handleLLen(c *Client, args [][]byte, store *store.BadgerStore) {
 	length, err := store.LLen(args[0])
 	if err != nil {
-		conn.Write(Encode(err))
+		c.WriteError(err)
 	} else {
-		conn.Write(Encode(length))
+		c.WriteInt64(int64(length))
+	}
+}
+
+// handleLRange 实现 LRANGE key start stop
+func handleLRange(c *Client, args [][]byte, store *store.BadgerStore) {
+	start, err1 := strconv.ParseInt(string(args[1]), 10, 64)
+	stop, err2 := strconv.ParseInt(string(args[2]), 10, 64)
+	if err1 != nil || err2 != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	values, err := store.LRange(args[0], start, stop)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteBulkArray(values)
+}
+
+// handleLIndex 实现 LINDEX key index
+func handleLIndex(c *Client, args [][]byte, store *store.BadgerStore) {
+	index, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	value, err := store.LIndex(args[0], index)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteBulk(value)
+}
+
+// handleLSet 实现 LSET key index element
+func handleLSet(c *Client, args [][]byte, store *store.BadgerStore) {
+	index, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
 	}
-}
\ No newline at end of file
+	if err := store.LSet(args[0], index, args[2]); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteOK()
+}
+
+// handleLTrim 实现 LTRIM key start stop
+func handleLTrim(c *Client, args [][]byte, store *store.BadgerStore) {
+	start, err1 := strconv.ParseInt(string(args[1]), 10, 64)
+	stop, err2 := strconv.ParseInt(string(args[2]), 10, 64)
+	if err1 != nil || err2 != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	if err := store.LTrim(args[0], start, stop); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteOK()
+}
+
+// handleLInsert 实现 LINSERT key <BEFORE | AFTER> pivot element
+func handleLInsert(c *Client, args [][]byte, store *store.BadgerStore) {
+	var before bool
+	switch strings.ToUpper(string(args[1])) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+	default:
+		c.WriteError(errSyntax)
+		return
+	}
+	length, err := store.LInsert(args[0], before, args[2], args[3])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(length)
+}
+
+// handleLRem 实现 LREM key count element
+func handleLRem(c *Client, args [][]byte, store *store.BadgerStore) {
+	count, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	removed, err := store.LRem(args[0], count, args[2])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(removed)
+}
+
+// handleLPos 实现 LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]，
+// 不带 COUNT 时回复单个下标或空值，带 COUNT 时回复数组
+func handleLPos(c *Client, args [][]byte, store *store.BadgerStore) {
+	rank, count, maxLen := int64(1), int64(1), int64(0)
+	withCount := false
+	for i := 2; i < len(args); i += 2 {
+		option := strings.ToUpper(string(args[i]))
+		if i+1 >= len(args) || (option != "RANK" && option != "COUNT" && option != "MAXLEN") {
+			c.WriteError(errSyntax)
+			return
+		}
+		n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		if err != nil {
+			c.WriteError(errNotInteger)
+			return
+		}
+		switch option {
+		case "RANK":
+			if n == 0 {
+				c.WriteError(errLPosRank)
+				return
+			}
+			if n == math.MinInt64 {
+				c.WriteError(errValueOutOfRange)
+				return
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				c.WriteError(errLPosCount)
+				return
+			}
+			count, withCount = n, true
+		case "MAXLEN":
+			if n < 0 {
+				c.WriteError(errLPosMaxLen)
+				return
+			}
+			maxLen = n
+		}
+	}
+	positions, err := store.LPos(args[0], args[1], rank, count, maxLen)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if withCount {
+		c.WriteArrayHeader(len(positions))
+		for _, pos := range positions {
+			c.WriteInt64(pos)
+		}
+		return
+	}
+	if len(positions) == 0 {
+		c.WriteNullBulk()
+		return
+	}
+	c.WriteInt64(positions[0])
+}
+
+// parseListSide 解析 LEFT 或 RIGHT，LEFT 返回 true
+func parseListSide(arg []byte) (left bool, ok bool) {
+	switch strings.ToUpper(string(arg)) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	}
+	return false, false
+}
+
+// parseLMPop 解析 LMPOP 和 BLMPOP 共用的 numkeys key [key ...] <LEFT | RIGHT> [COUNT count]
+func parseLMPop(args [][]byte) (keys [][]byte, left bool, count uint64, err error) {
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil || numKeys <= 0 {
+		return nil, false, 0, errNumKeys
+	}
+	if numKeys > int64(len(args)-2) {
+		return nil, false, 0, errSyntax
+	}
+	keys = args[1 : numKeys+1]
+	rest := args[numKeys+1:]
+	left, ok := parseListSide(rest[0])
+	if !ok {
+		return nil, false, 0, errSyntax
+	}
+	count = 1
+	switch {
+	case len(rest) == 1:
+	case len(rest) == 3 && strings.ToUpper(string(rest[1])) == "COUNT":
+		n, err := strconv.ParseInt(string(rest[2]), 10, 64)
+		if err != nil || n <= 0 {
+			return nil, false, 0, errCount
+		}
+		count = uint64(n)
+	default:
+		return nil, false, 0, errSyntax
+	}
+	return keys, left, count, nil
+}
+
+// handleLMove 实现 LMOVE source destination <LEFT | RIGHT> <LEFT | RIGHT>
+func handleLMove(c *Client, args [][]byte, store *store.BadgerStore) {
+	fromLeft, ok := parseListSide(args[2])
+	toLeft, ok2 := parseListSide(args[3])
+	if !ok || !ok2 {
+		c.WriteError(errSyntax)
+		return
+	}
+	listMove(c, args[0], args[1], store, fromLeft, toLeft)
+}
+
+// handleRPopLPush 实现 RPOPLPUSH source destination，等价于 LMOVE source destination RIGHT LEFT
+func handleRPopLPush(c *Client, args [][]byte, store *store.BadgerStore) {
+	listMove(c, args[0], args[1], store, false, true)
+}
+
+// listMove 回复被移动的元素，source 不存在时回复空值
+func listMove(c *Client, source, destination []byte, s *store.BadgerStore, fromLeft, toLeft bool) {
+	value, err := s.LMove(source, destination, fromLeft, toLeft)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if value != nil {
+		blocking.signal(destination)
+	}
+	c.WriteBulk(value)
+}
+
+// handleLMPop 实现 LMPOP numkeys key [key ...] <LEFT | RIGHT> [COUNT count]，
+// 回复 key 和弹出的元素数组，所有列表都为空时回复空数组（null array）
+func handleLMPop(c *Client, args [][]byte, store *store.BadgerStore) {
+	keys, left, count, err := parseLMPop(args)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	key, values, err := store.LMPop(keys, left, count)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if values == nil {
+		c.WriteNullArray()
+		return
+	}
+	c.WriteArrayHeader(2)
+	c.WriteBulk(key)
+	c.WriteBulkArray(values)
+}
+
+// handleBLPop 实现 BLPOP key [key ...] timeout
+func handleBLPop(c *Client, args [][]byte, store *store.BadgerStore) {
+	blockingPop(c, args, store, true)
+}
+
+// handleBRPop 实现 BRPOP key [key ...] timeout
+func handleBRPop(c *Client, args [][]byte, store *store.BadgerStore) {
+	blockingPop(c, args, store, false)
+}
+
+// blockingPop 从第一个非空列表弹出一个元素，回复 key 和元素组成的数组
+func blockingPop(c *Client, args [][]byte, s *store.BadgerStore, left bool) {
+	timeout, err := parseTimeout(args[len(args)-1])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	keys := args[:len(args)-1]
+	block(c, keys, timeout, false, func() bool {
+		key, values, err := s.LMPop(keys, left, 1)
+		if err != nil {
+			c.WriteError(err)
+			return true
+		}
+		if values == nil {
+			return false
+		}
+		c.WriteArrayHeader(2)
+		c.WriteBulk(key)
+		c.WriteBulk(values[0])
+		return true
+	})
+}
+
+// handleBLMove 实现 BLMOVE source destination <LEFT | RIGHT> <LEFT | RIGHT> timeout
+func handleBLMove(c *Client, args [][]byte, store *store.BadgerStore) {
+	fromLeft, ok := parseListSide(args[2])
+	toLeft, ok2 := parseListSide(args[3])
+	if !ok || !ok2 {
+		c.WriteError(errSyntax)
+		return
+	}
+	timeout, err := parseTimeout(args[4])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	block(c, args[:1], timeout, true, func() bool {
+		value, err := store.LMove(args[0], args[1], fromLeft, toLeft)
+		if err != nil {
+			c.WriteError(err)
+			return true
+		}
+		if value == nil {
+			return false
+		}
+		blocking.signal(args[1])
+		c.WriteBulk(value)
+		return true
+	})
+}
+
+// handleBLMPop 实现 BLMPOP timeout numkeys key [key ...] <LEFT | RIGHT> [COUNT count]，
+// 回复 key 和弹出的元素数组
+func handleBLMPop(c *Client, args [][]byte, store *store.BadgerStore) {
+	keys, left, count, err := parseLMPop(args[1:])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	timeout, err := parseTimeout(args[0])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	block(c, keys, timeout, false, func() bool {
+		key, values, err := store.LMPop(keys, left, count)
+		if err != nil {
+			c.WriteError(err)
+			return true
+		}
+		if values == nil {
+			return false
+		}
+		c.WriteArrayHeader(2)
+		c.WriteBulk(key)
+		c.WriteBulkArray(values)
+		return true
+	})
+}
@@ -0,0 +1,79 @@
+package resp
+
+import (
+	"PumbaaDB/store"
+	"sync"
+)
+
+// execLock 保证 EXEC 中的命令连续执行：普通命令执行期间持有读锁，EXEC 持有写锁，
+// 其他连接的命令不会插到事务的命令之间。阻塞命令等待期间会释放读锁。
+// 多个连接同时 EXEC 时按取得写锁的先后执行
+var execLock sync.RWMutex
+
+// multiState 保存 MULTI 之后排队的命令
+type multiState struct {
+	commands []queuedCommand
+	// dirty 表示排队时有命令出错（命令不存在或参数个数不对），EXEC 时放弃整个事务
+	dirty bool
+}
+
+type queuedCommand struct {
+	cmd  *Command
+	args [][]byte
+}
+
+// queuesInMulti 判断命令在 MULTI 之后是否排队，MULTI、EXEC、DISCARD 本身立即执行
+func (cmd *Command) queuesInMulti() bool {
+	switch cmd.Name {
+	case "multi", "exec", "discard":
+		return false
+	}
+	return true
+}
+
+// handleMulti 实现 MULTI
+func handleMulti(c *Client, args [][]byte, store *store.BadgerStore) {
+	if c.multi != nil {
+		c.WriteError(errMultiNested)
+		return
+	}
+	c.multi = &multiState{}
+	c.WriteOK()
+}
+
+// handleExec 实现 EXEC，按顺序执行排队的命令，回复由每条命令的回复组成的数组。
+// 阻塞命令在 EXEC 中不等待，列表为空时直接回复 null
+func handleExec(c *Client, args [][]byte, store *store.BadgerStore) {
+	if c.multi == nil {
+		c.WriteError(errExecWithoutMulti)
+		return
+	}
+	multi := c.multi
+	c.multi = nil
+	if multi.dirty {
+		c.WriteError(errExecAbort)
+		return
+	}
+
+	// dispatch 不为 EXEC 加读锁，这里直接取写锁，不存在读锁升级为写锁的间隙
+	execLock.Lock()
+	c.inExec = true
+	defer func() {
+		c.inExec = false
+		execLock.Unlock()
+	}()
+	c.WriteArrayHeader(len(multi.commands))
+	for _, queued := range multi.commands {
+		queued.cmd.Handler(c, queued.args, store)
+	}
+}
+
+// handleDiscard 实现 DISCARD
+func handleDiscard(c *Client, args [][]byte, store *store.BadgerStore) {
+	if c.multi == nil {
+		c.WriteError(errDiscardWithoutMulti)
+		return
+	}
+	c.multi = nil
+	c.WriteOK()
+}
@@ -0,0 +1,91 @@
+package resp
+
+import (
+	"net"
+	"testing"
+
+	"PumbaaDB/store"
+
+	"github.com/zeebo/assert"
+)
+
+// transactionClient 在单独的 goroutine 中按顺序执行 commands，每条命令的回复单独发出
+func transactionClient(t *testing.T, s *store.BadgerStore, commands ...[]string) net.Conn {
+	server, client := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	c := newClient(server)
+	go func() {
+		for _, args := range commands {
+			dispatch(c, toArgs(args...), s)
+			if err := c.Flush(); err != nil {
+				return
+			}
+		}
+	}()
+	return client
+}
+
+func TestMultiExec(t *testing.T) {
+	s, err := store.NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer s.Close()
+
+	// 阻塞命令在事务中不等待，列表为空时 BLPOP 回复 null array，BLMOVE 回复 null bulk，推入元素后取到元素
+	conn := transactionClient(t, s,
+		[]string{"MULTI"},
+		[]string{"SET", "k", "v"},
+		[]string{"BLPOP", "q", "0"},
+		[]string{"MULTI"},
+		[]string{"BLMOVE", "q", "dst", "LEFT", "LEFT", "0"},
+		[]string{"RPUSH", "q", "a"},
+		[]string{"BRPOP", "q", "0"},
+		[]string{"EXEC"},
+	)
+	assert.Equal(t, "+OK\r\n", readReply(t, conn))
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, "+QUEUED\r\n", readReply(t, conn))
+	}
+	assert.Equal(t, "-ERR MULTI calls can not be nested\r\n", readReply(t, conn))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "+QUEUED\r\n", readReply(t, conn))
+	}
+	assert.Equal(t, "*5\r\n+OK\r\n*-1\r\n$-1\r\n:1\r\n*2\r\n$1\r\nq\r\n$1\r\na\r\n", readReply(t, conn))
+	assert.Equal(t, 0, len(blocking.queues))
+}
+
+func TestMultiErrors(t *testing.T) {
+	s, err := store.NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer s.Close()
+
+	conn := transactionClient(t, s,
+		[]string{"EXEC"},
+		[]string{"DISCARD"},
+		[]string{"SET", "k", "v"},
+		// 排队时出错的事务在 EXEC 时整个放弃
+		[]string{"MULTI"},
+		[]string{"NOSUCH"},
+		[]string{"SET", "k"},
+		[]string{"SET", "k", "w"},
+		[]string{"EXEC"},
+		[]string{"GET", "k"},
+		// DISCARD 丢弃排队的命令
+		[]string{"MULTI"},
+		[]string{"SET", "k", "w"},
+		[]string{"DISCARD"},
+		[]string{"GET", "k"},
+	)
+	assert.Equal(t, "-ERR EXEC without MULTI\r\n", readReply(t, conn))
+	assert.Equal(t, "-ERR DISCARD without MULTI\r\n", readReply(t, conn))
+	assert.Equal(t, "+OK\r\n", readReply(t, conn))
+	assert.Equal(t, "+OK\r\n", readReply(t, conn))
+	assert.Equal(t, "-ERR unknown command 'NOSUCH', with args beginning with: \r\n", readReply(t, conn))
+	assert.Equal(t, "-ERR wrong number of arguments for 'set' command\r\n", readReply(t, conn))
+	assert.Equal(t, "+QUEUED\r\n", readReply(t, conn))
+	assert.Equal(t, "-EXECABORT Transaction discarded because of previous errors.\r\n", readReply(t, conn))
+	assert.Equal(t, "$1\r\nv\r\n", readReply(t, conn))
+	assert.Equal(t, "+OK\r\n", readReply(t, conn))
+	assert.Equal(t, "+QUEUED\r\n", readReply(t, conn))
+	assert.Equal(t, "+OK\r\n", readReply(t, conn))
+	assert.Equal(t, "$1\r\nv\r\n", readReply(t, conn))
+}
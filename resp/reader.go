@@ -0,0 +1,205 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+)
+
+const (
+	// readerBufSize 是每个连接的读缓冲大小
+	readerBufSize = 16 * 1024
+	// maxInlineSize 是 inline 命令和协议头行的最大长度，与 Redis 的 PROTO_INLINE_MAX_SIZE 一致
+	maxInlineSize = 64 * 1024
+	// maxMultiBulkLen 是一条命令允许的最大参数个数
+	maxMultiBulkLen = 1024 * 1024
+	// maxBulkLen 是单个参数的最大长度，与 Redis 默认的 proto-max-bulk-len 一致
+	maxBulkLen = 512 * 1024 * 1024
+)
+
+// ProtocolError 表示客户端发送了不符合 RESP 协议的数据，连接在回复错误后应当关闭
+type ProtocolError struct {
+	msg string
+}
+
+func (e *ProtocolError) Error() string {
+	return "ERR Protocol error: " + e.msg
+}
+
+func protocolError(msg string) error {
+	return &ProtocolError{msg: msg}
+}
+
+// Reader 是按连接维护状态的 RESP2 增量解码器。
+// 未解析完的数据保留在内部缓冲中，跨 TCP 分片的命令和一次到达的多条流水线命令都能被正确切分。
+type Reader struct {
+	rd *bufio.Reader
+}
+
+// NewReader 为一个连接创建解码器
+func NewReader(r io.Reader) *Reader {
+	return &Reader{rd: bufio.NewReaderSize(r, readerBufSize)}
+}
+
+// Buffered 返回已读入缓冲但尚未解析的字节数，大于 0 说明还有流水线命令待处理
+func (r *Reader) Buffered() int {
+	return r.rd.Buffered()
+}
+
+// CommandBuffered 判断读缓冲中是否已经有一条完整的命令，读取它不需要再等待连接上的数据。
+// 格式错误的数据也返回 true，由 ReadCommand 报告协议错误
+func (r *Reader) CommandBuffered() bool {
+	buf, _ := r.rd.Peek(r.rd.Buffered())
+	if len(buf) == 0 {
+		return false
+	}
+	line, rest, ok := cutLine(buf)
+	if !ok || buf[0] != '*' {
+		return ok
+	}
+	count, err := strconv.ParseInt(string(line[1:]), 10, 64)
+	if err != nil {
+		return true
+	}
+	for i := int64(0); i < count; i++ {
+		if line, rest, ok = cutLine(rest); !ok {
+			return false
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return true
+		}
+		length, err := strconv.ParseInt(string(line[1:]), 10, 64)
+		if err != nil || length < 0 {
+			return true
+		}
+		if int64(len(rest)) < length+2 {
+			return false
+		}
+		rest = rest[length+2:]
+	}
+	return true
+}
+
+// cutLine 从 buf 中切出第一行（不含行尾），没有完整的一行时 ok 为 false
+func cutLine(buf []byte) (line, rest []byte, ok bool) {
+	line, rest, ok = bytes.Cut(buf, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r")), rest, ok
+}
+
+// ReadCommand 读取并返回一条完整的命令，数据不足时会阻塞直到读满。
+// 空命令（空行或 *0）返回长度为 0 的参数列表。
+func (r *Reader) ReadCommand() ([][]byte, error) {
+	prefix, err := r.rd.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if prefix[0] != '*' {
+		return r.readInline()
+	}
+
+	line, err := r.readLine("too big mbulk count string")
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.ParseInt(string(line[1:]), 10, 64)
+	if err != nil || count > maxMultiBulkLen {
+		return nil, protocolError("invalid multibulk length")
+	}
+	if count <= 0 {
+		return [][]byte{}, nil
+	}
+
+	// 参数个数来自客户端，不按它一次性分配过大的切片
+	args := make([][]byte, 0, min(count, 1024))
+	for i := int64(0); i < count; i++ {
+		arg, err := r.readBulk()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// readBulk 读取一个 $<len>\r\n<data>\r\n 格式的参数
+func (r *Reader) readBulk() ([]byte, error) {
+	line, err := r.readLine("too big bulk count string")
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '$' {
+		got := ""
+		if len(line) > 0 {
+			got = string(line[0])
+		}
+		return nil, protocolError("expected '$', got '" + got + "'")
+	}
+	length, err := strconv.ParseInt(string(line[1:]), 10, 64)
+	if err != nil || length < 0 || length > maxBulkLen {
+		return nil, protocolError("invalid bulk length")
+	}
+
+	buf := make([]byte, length+2)
+	if _, err := io.ReadFull(r.rd, buf); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if buf[length] != '\r' || buf[length+1] != '\n' {
+		return nil, protocolError("invalid bulk terminator")
+	}
+	return buf[:length:length], nil
+}
+
+// readInline 解析 telnet 风格的 inline 命令，参数以空白分隔
+func (r *Reader) readInline() ([][]byte, error) {
+	line, err := r.readLine("too big inline request")
+	if err != nil {
+		return nil, err
+	}
+	fields := bytes.Fields(line)
+	args := make([][]byte, len(fields))
+	for i, field := range fields {
+		args[i] = append([]byte(nil), field...)
+	}
+	return args, nil
+}
+
+// readLine 读取一行并去掉行尾的 \r\n（inline 命令允许只有 \n），
+// 返回的切片在下一次读取前有效
+func (r *Reader) readLine(tooBigMsg string) ([]byte, error) {
+	line, err := r.rd.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		// 行比读缓冲长，只有 inline 命令会出现这种情况，逐段拼接
+		buf := append([]byte(nil), line...)
+		for errors.Is(err, bufio.ErrBufferFull) {
+			if len(buf) > maxInlineSize {
+				return nil, protocolError(tooBigMsg)
+			}
+			line, err = r.rd.ReadSlice('\n')
+			buf = append(buf, line...)
+		}
+		line = buf
+	}
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if len(line) > maxInlineSize {
+		return nil, protocolError(tooBigMsg)
+	}
+
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line, nil
+}
+
+// unexpectedEOF 把命令中途遇到的 io.EOF 转换为 io.ErrUnexpectedEOF，
+// 以便和命令之间的正常断开区分开
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/zeebo/assert"
+)
+
+func TestReaderPipelineAndPartialReads(t *testing.T) {
+	input := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n" +
+		"*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n" +
+		"PING\r\n"
+	// OneByteReader 模拟命令被拆分到多个 TCP 分片中
+	reader := NewReader(iotest.OneByteReader(strings.NewReader(input)))
+
+	args, err := reader.ReadCommand()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, [][]byte{[]byte("SET"), []byte("key"), []byte("value")}, args)
+
+	args, err = reader.ReadCommand()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, [][]byte{[]byte("GET"), []byte("key")}, args)
+
+	args, err = reader.ReadCommand()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, [][]byte{[]byte("PING")}, args)
+
+	_, err = reader.ReadCommand()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReaderCommandBuffered(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  bool
+	}{
+		{"", false},
+		{"PING", false},
+		{"PING\r\n", true},
+		{"*2\r\n$3\r\nGET", false},
+		{"*2\r\n$3\r\nGET\r\n$3\r\nke", false},
+		{"*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", true},
+		{"*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n*1\r\n", true},
+		{"*0\r\n", true},
+		// 格式错误的数据交给 ReadCommand 报告
+		{"*x\r\n", true},
+		{"*1\r\n:1\r\n", true},
+	} {
+		reader := NewReader(strings.NewReader(tc.input))
+		// 先把数据读入缓冲
+		_, _ = reader.rd.Peek(len(tc.input))
+		assert.Equal(t, tc.want, reader.CommandBuffered())
+	}
+}
+
+func TestReaderLargeBulk(t *testing.T) {
+	value := bytes.Repeat([]byte("x"), 3*1024*1024)
+	var buf bytes.Buffer
+	buf.WriteString("*3\r\n$3\r\nSET\r\n$3\r\nbig\r\n")
+	buf.WriteString("$3145728\r\n")
+	buf.Write(value)
+	buf.WriteString("\r\n")
+
+	args, err := NewReader(&buf).ReadCommand()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(args))
+	assert.True(t, bytes.Equal(value, args[2]))
+}
+
+func TestReaderProtocolErrors(t *testing.T) {
+	cases := map[string]string{
+		"*x\r\n":                "ERR Protocol error: invalid multibulk length",
+		"*1\r\n$-3\r\n":         "ERR Protocol error: invalid bulk length",
+		"*1\r\n$abc\r\n":        "ERR Protocol error: invalid bulk length",
+		"*1\r\n+OK\r\n":         "ERR Protocol error: expected '$', got '+'",
+		"*1\r\n$3\r\nGETXX\r\n": "ERR Protocol error: invalid bulk terminator",
+		"*1\r\n$1000000000\r\n": "ERR Protocol error: invalid bulk length",
+		"*99999999999999\r\n":   "ERR Protocol error: invalid multibulk length",
+	}
+	for input, want := range cases {
+		_, err := NewReader(strings.NewReader(input)).ReadCommand()
+		var protoErr *ProtocolError
+		assert.True(t, errors.As(err, &protoErr))
+		assert.Equal(t, want, err.Error())
+	}
+
+	_, err := NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n")).ReadCommand()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
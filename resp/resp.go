@@ -2,95 +2,73 @@ package resp
 
 import (
 	"PumbaaDB/store"
-	"bytes"
-	"fmt"
-	"io"
+	"errors"
+	"log"
 	"net"
-	"strconv"
+	"runtime/debug"
 )
 
-func Parse(reader io.Reader) ([][]byte, error) {
-    buf := make([]byte, 1024)
-    n, err := reader.Read(buf)
-    if err != nil {
-        return nil, err
-    }
-    buf = buf[:n]
-
-    if buf[0] != '*' {
-        return nil, fmt.Errorf("invalid RESP format")
-    }
-
-    end := bytes.IndexByte(buf, '\r')
-    if end == -1 {
-        return nil, fmt.Errorf("invalid RESP format")
-    }
-
-    count, _ := strconv.Atoi(string(buf[1:end]))
-    args := make([][]byte, 0, count)
-    pos := end + 2
-
-    for i := 0; i < count; i++ {
-        if buf[pos] != '$' {
-            return nil, fmt.Errorf("invalid RESP format")
-        }
-        pos++
-        end = bytes.IndexByte(buf[pos:], '\r')
-        length, _ := strconv.Atoi(string(buf[pos : pos+end]))
-        pos += end + 2
-        args = append(args, buf[pos:pos+length])
-        pos += length + 2
-    }
-    return args, nil
+// request 是读取 goroutine 解析出的一条命令
+type request struct {
+	args [][]byte
+	// more 表示读缓冲中已经有下一条完整的流水线命令
+	more bool
+	err  error
 }
 
-func Encode(value interface{}) []byte {
-    switch v := value.(type) {
-    case string:
-        return []byte(fmt.Sprintf("+%s\r\n", v))
-    case []byte:
-        if v == nil {
-            return []byte("$-1\r\n")
-        }
-        return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(v), v))
-    case int:
-        return []byte(fmt.Sprintf(":%d\r\n", v))
-    case error:
-        return []byte(fmt.Sprintf("-%s\r\n", v.Error()))
-    case []interface{}:
-        buf := &bytes.Buffer{}
-        buf.WriteString(fmt.Sprintf("*%d\r\n", len(v)))
-        for _, item := range v {
-            buf.Write(Encode(item))
-        }
-        return buf.Bytes()
-    default:
-        return []byte("-ERR unknown type\r\n")
-    }
+func HandleConnection(conn net.Conn, store *store.BadgerStore) {
+	defer conn.Close()
+	// 处理命令时的 panic 只断开当前连接，不影响服务器和其他客户端
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("HandleConnection: panic: %v\n%s", err, debug.Stack())
+		}
+	}()
+	c := newClient(conn)
+	reader := NewReader(conn)
+	// 读取放在单独的 goroutine 中，客户端阻塞在 BLPOP 等命令上时也能及时发现连接断开
+	requests := make(chan request)
+	done := make(chan struct{})
+	defer close(done)
+	go c.readRequests(reader, requests, done)
+	for {
+		req := <-requests
+		if req.err != nil {
+			var protoErr *ProtocolError
+			if errors.As(req.err, &protoErr) {
+				c.WriteError(req.err)
+				c.Flush()
+			}
+			return
+		}
+		if len(req.args) > 0 {
+			dispatch(c, req.args, store)
+		}
+		// 同一批到达的流水线命令全部处理完后再统一写回，只到达一部分的命令不会拖住前面命令的回复
+		if !req.more {
+			if err := c.Flush(); err != nil {
+				return
+			}
+		}
+	}
 }
 
-func HandleConnection(conn net.Conn, store *store.BadgerStore) {
-    defer conn.Close()
-    for {
-        args, err := Parse(conn)
-        if err != nil {
-            return
-        }
-        if len(args) == 0 {
-            continue
-        }
-        cmd := string(args[0])
-        switch cmd {
-        case "SET":
-            HandleSet(conn, args[1:], store)
-        case "GET":
-            // handleGet(conn, args[1:], store)
-        case "HSET":
-            // handleHSet(conn, args[1:], store)
-        case "HGET":
-            // handleHGet(conn, args[1:], store)
-        default:
-            conn.Write(Encode(fmt.Errorf("ERR unknown command '%s'", cmd)))
-        }
-    }
+// readRequests 不断读取命令交给连接的主循环，读取出错时先关闭 c.closed 再把错误交给主循环
+func (c *Client) readRequests(reader *Reader, requests chan<- request, done <-chan struct{}) {
+	for {
+		args, err := reader.ReadCommand()
+		if err != nil {
+			close(c.closed)
+			select {
+			case requests <- request{err: err}:
+			case <-done:
+			}
+			return
+		}
+		select {
+		case requests <- request{args: args, more: reader.CommandBuffered()}:
+		case <-done:
+			return
+		}
+	}
 }
@@ -0,0 +1,67 @@
+package resp
+
+import (
+	"math"
+	"strconv"
+)
+
+// 协议版本，由 HELLO 命令按连接切换
+const (
+	ProtoRESP2 = 2
+	ProtoRESP3 = 3
+)
+
+// 以下类型是 RESP3 新增的回复类型。RESP2 连接没有对应的原生类型，
+// 编码时会降级为 RESP2 中约定俗成的表示，例如 Map 降级为 key/value 交替的平铺数组。
+
+// MapItem 是 Map 中的一个键值对
+type MapItem struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Map 是有序的键值对列表，RESP3 编码为 %，RESP2 编码为平铺数组
+type Map []MapItem
+
+// Set 在 RESP3 编码为 ~，RESP2 编码为数组
+type Set []interface{}
+
+// Push 是服务端主动推送的消息，RESP3 编码为 >，RESP2 编码为数组
+type Push []interface{}
+
+// Double 在 RESP3 编码为 ,，RESP2 编码为字符串
+type Double float64
+
+// Bool 在 RESP3 编码为 #t/#f，RESP2 编码为整数 1/0
+type Bool bool
+
+// BigNumber 是十进制表示的大整数，RESP3 编码为 (，RESP2 编码为字符串
+type BigNumber string
+
+// Verbatim 是带格式的字符串（如 txt、mkd），RESP3 编码为 =，RESP2 编码为普通字符串
+type Verbatim struct {
+	Format string
+	Text   string
+}
+
+type nullReply struct{}
+
+// Null 是空值，RESP3 编码为 _，RESP2 编码为空字符串 $-1
+var Null = nullReply{}
+
+// formatDouble 按 Redis 的习惯格式化浮点数，无穷大写作 inf/-inf。
+// 与 Redis 一致，常见范围内的数写成不带指数的最短形式（1000000 而不是 1e+06），过大或过小的数才使用指数形式
+func formatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e17) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,61 @@
+package resp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+// encodeProto 按协议版本编码 resp3.go 中的回复类型
+func encodeProto(value interface{}, proto int) string {
+	return writeWith(proto, func(w *ReplyWriter) { w.WriteValue(value) })
+}
+
+func TestEncodeProto(t *testing.T) {
+	m := Map{{Key: []byte("name"), Value: []byte("Alice")}}
+	assert.Equal(t, "*2\r\n$4\r\nname\r\n$5\r\nAlice\r\n", encodeProto(m, ProtoRESP2))
+	assert.Equal(t, "%1\r\n$4\r\nname\r\n$5\r\nAlice\r\n", encodeProto(m, ProtoRESP3))
+
+	assert.Equal(t, "$-1\r\n", encodeProto(Null, ProtoRESP2))
+	assert.Equal(t, "_\r\n", encodeProto([]byte(nil), ProtoRESP3))
+
+	assert.Equal(t, "$3\r\n1.5\r\n", encodeProto(Double(1.5), ProtoRESP2))
+	assert.Equal(t, ",1.5\r\n", encodeProto(Double(1.5), ProtoRESP3))
+	assert.Equal(t, ",-inf\r\n", encodeProto(Double(math.Inf(-1)), ProtoRESP3))
+
+	assert.Equal(t, ":1\r\n", encodeProto(Bool(true), ProtoRESP2))
+	assert.Equal(t, "#f\r\n", encodeProto(Bool(false), ProtoRESP3))
+
+	assert.Equal(t, "~1\r\n:1\r\n", encodeProto(Set{1}, ProtoRESP3))
+	assert.Equal(t, ">1\r\n+ok\r\n", encodeProto(Push{"ok"}, ProtoRESP3))
+	assert.Equal(t, "(12345678901234567890\r\n", encodeProto(BigNumber("12345678901234567890"), ProtoRESP3))
+	assert.Equal(t, "=7\r\ntxt:abc\r\n", encodeProto(Verbatim{Format: "txt", Text: "abc"}, ProtoRESP3))
+	assert.Equal(t, "$3\r\nabc\r\n", encodeProto(Verbatim{Format: "txt", Text: "abc"}, ProtoRESP2))
+}
+
+func TestFormatDouble(t *testing.T) {
+	for _, tc := range []struct {
+		f    float64
+		want string
+	}{
+		{0, "0"},
+		{1.5, "1.5"},
+		{-2, "-2"},
+		{0.1, "0.1"},
+		{1e6, "1000000"},
+		{1234567, "1234567"},
+		{-1234567.25, "-1234567.25"},
+		{1e16, "10000000000000000"},
+		{1e17, "1e+17"},
+		{1.5e20, "1.5e+20"},
+		{0.000001, "0.000001"},
+		{1.5e-7, "1.5e-07"},
+		{math.Inf(1), "inf"},
+		{math.Inf(-1), "-inf"},
+	} {
+		assert.Equal(t, tc.want, formatDouble(tc.f))
+	}
+	assert.Equal(t, "$7\r\n1234567\r\n", encodeProto(Double(1234567), ProtoRESP2))
+	assert.Equal(t, ",1000000\r\n", encodeProto(Double(1e6), ProtoRESP3))
+}
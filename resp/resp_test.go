@@ -0,0 +1,53 @@
+package resp
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"PumbaaDB/store"
+
+	"github.com/zeebo/assert"
+)
+
+// 命令处理中的 panic 只关闭当前连接，HandleConnection 正常返回
+func TestHandleConnectionRecovers(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// store 为 nil，SET 访问它时会 panic
+		HandleConnection(server, nil)
+	}()
+
+	_, err := client.Write([]byte("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n"))
+	assert.NoError(t, err)
+	_, err = client.Read(make([]byte, 16))
+	assert.Equal(t, io.EOF, err)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("HandleConnection did not return")
+	}
+}
+
+// 流水线中最后一条命令只到达一部分时，前面命令的回复不用等它到齐
+func TestHandleConnectionFlushesBeforePartialCommand(t *testing.T) {
+	s, err := store.NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer s.Close()
+	server, client := net.Pipe()
+	defer client.Close()
+	go HandleConnection(server, s)
+
+	_, err = client.Write([]byte("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n*2\r\n$3\r\nGET"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+	assert.Equal(t, "+OK\r\n", readReply(t, client))
+
+	_, err = client.Write([]byte("\r\n$1\r\nk\r\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "$1\r\nv\r\n", readReply(t, client))
+}
@@ -0,0 +1,51 @@
+package resp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// scanOptions 是 SCAN 系列命令在游标之后的可选参数
+type scanOptions struct {
+	match    []byte
+	count    int
+	noValues bool
+}
+
+// parseScanOptions 解析 [MATCH pattern] [COUNT count]，allowNoValues 为 true 时还接受 HSCAN 的 NOVALUES。
+// MATCH * 与不带 MATCH 等价，返回的 match 为 nil
+func parseScanOptions(args [][]byte, allowNoValues bool) (scanOptions, error) {
+	opts := scanOptions{count: 10}
+	for i := 0; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "MATCH" && i+1 < len(args):
+			opts.match = args[i+1]
+			if string(opts.match) == "*" {
+				opts.match = nil
+			}
+			i++
+		case opt == "COUNT" && i+1 < len(args):
+			count, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return opts, errNotInteger
+			}
+			if count < 1 {
+				return opts, errSyntax
+			}
+			opts.count = int(min(count, 1<<31))
+			i++
+		case opt == "NOVALUES" && allowNoValues:
+			opts.noValues = true
+		default:
+			return opts, errSyntax
+		}
+	}
+	return opts, nil
+}
+
+// writeScanReply 回复 [cursor, [item ...]]，游标以字符串形式返回
+func writeScanReply(c *Client, cursor uint64, items [][]byte) {
+	c.WriteArrayHeader(2)
+	c.WriteBulkString(strconv.FormatUint(cursor, 10))
+	c.WriteBulkArray(items)
+}
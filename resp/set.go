@@ -2,9 +2,282 @@ package resp
 
 import (
 	"PumbaaDB/store"
-	"net"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
 )
 
-func HandleSCARD(conn net.Conn, args [][]byte, store *store.BadgerStore) {
-    
-}  
\ No newline at end of file
+var (
+	errSInterCardNumKeys = errors.New("ERR Number of keys can't be greater than number of args")
+	errLimitNegative     = errors.New("ERR LIMIT can't be negative")
+)
+
+// handleSAdd 实现 SADD key member [member ...]，回复新加入的成员数量
+func handleSAdd(c *Client, args [][]byte, store *store.BadgerStore) {
+	added, err := store.SAdd(args[0], args[1:]...)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(int64(added))
+}
+
+// handleSRem 实现 SREM key member [member ...]，回复实际删除的成员数量
+func handleSRem(c *Client, args [][]byte, store *store.BadgerStore) {
+	removed, err := store.SRem(args[0], args[1:]...)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(int64(removed))
+}
+
+// handleSCard 实现 SCARD key
+func handleSCard(c *Client, args [][]byte, store *store.BadgerStore) {
+	count, err := store.SCard(args[0])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(int64(count))
+}
+
+// handleSIsMember 实现 SISMEMBER key member
+func handleSIsMember(c *Client, args [][]byte, store *store.BadgerStore) {
+	exists, err := store.SIsMember(args[0], args[1])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if exists {
+		c.WriteInt64(1)
+	} else {
+		c.WriteInt64(0)
+	}
+}
+
+// handleSMIsMember 实现 SMISMEMBER key member [member ...]，按顺序回复每个成员是否存在
+func handleSMIsMember(c *Client, args [][]byte, store *store.BadgerStore) {
+	result, err := store.SMIsMember(args[0], args[1:]...)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteArrayHeader(len(result))
+	for _, exists := range result {
+		if exists {
+			c.WriteInt64(1)
+		} else {
+			c.WriteInt64(0)
+		}
+	}
+}
+
+// handleSMembers 实现 SMEMBERS key
+func handleSMembers(c *Client, args [][]byte, store *store.BadgerStore) {
+	members, err := store.SMembers(args[0])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	writeSetReply(c, members)
+}
+
+// handleSRandMember 实现 SRANDMEMBER key [count]，不带 count 时回复单个成员或空值
+func handleSRandMember(c *Client, args [][]byte, store *store.BadgerStore) {
+	if len(args) > 2 {
+		c.WriteError(errSyntax)
+		return
+	}
+	if len(args) == 1 {
+		members, err := store.SRandMember(args[0], 1)
+		if err != nil {
+			c.WriteError(err)
+			return
+		}
+		if len(members) == 0 {
+			c.WriteNullBulk()
+			return
+		}
+		c.WriteBulk(members[0])
+		return
+	}
+	count, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	if count == math.MinInt64 {
+		c.WriteError(errValueOutOfRange)
+		return
+	}
+	members, err := store.SRandMember(args[0], count)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	// count 为负数时成员可能重复，始终以数组回复
+	c.WriteBulkArray(members)
+}
+
+// handleSPop 实现 SPOP key [count]，不带 count 时回复单个成员或空值
+func handleSPop(c *Client, args [][]byte, store *store.BadgerStore) {
+	if len(args) > 2 {
+		c.WriteError(errSyntax)
+		return
+	}
+	if len(args) == 1 {
+		members, err := store.SPop(args[0], 1)
+		if err != nil {
+			c.WriteError(err)
+			return
+		}
+		if len(members) == 0 {
+			c.WriteNullBulk()
+			return
+		}
+		c.WriteBulk(members[0])
+		return
+	}
+	count, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	if count < 0 {
+		c.WriteError(errNotPositive)
+		return
+	}
+	members, err := store.SPop(args[0], uint64(count))
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	writeSetReply(c, members)
+}
+
+// handleSMove 实现 SMOVE source destination member
+func handleSMove(c *Client, args [][]byte, store *store.BadgerStore) {
+	moved, err := store.SMove(args[0], args[1], args[2])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if moved {
+		c.WriteInt64(1)
+	} else {
+		c.WriteInt64(0)
+	}
+}
+
+// handleSScan 实现 SSCAN key cursor [MATCH pattern] [COUNT count]
+func handleSScan(c *Client, args [][]byte, store *store.BadgerStore) {
+	cursor, err := strconv.ParseUint(string(args[1]), 10, 64)
+	if err != nil {
+		c.WriteError(errInvalidCursor)
+		return
+	}
+	opts, err := parseScanOptions(args[2:], false)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	next, members, err := store.SScan(args[0], cursor, opts.match, opts.count)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	writeScanReply(c, next, members)
+}
+
+// handleSInter 实现 SINTER key [key ...]
+func handleSInter(c *Client, args [][]byte, store *store.BadgerStore) {
+	setAlgebra(c, args, store.SInter)
+}
+
+// handleSUnion 实现 SUNION key [key ...]
+func handleSUnion(c *Client, args [][]byte, store *store.BadgerStore) {
+	setAlgebra(c, args, store.SUnion)
+}
+
+// handleSDiff 实现 SDIFF key [key ...]
+func handleSDiff(c *Client, args [][]byte, store *store.BadgerStore) {
+	setAlgebra(c, args, store.SDiff)
+}
+
+func setAlgebra(c *Client, keys [][]byte, compute func(keys ...[]byte) ([][]byte, error)) {
+	members, err := compute(keys...)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	writeSetReply(c, members)
+}
+
+// handleSInterStore 实现 SINTERSTORE destination key [key ...]
+func handleSInterStore(c *Client, args [][]byte, store *store.BadgerStore) {
+	setAlgebraStore(c, args, store.SInterStore)
+}
+
+// handleSUnionStore 实现 SUNIONSTORE destination key [key ...]
+func handleSUnionStore(c *Client, args [][]byte, store *store.BadgerStore) {
+	setAlgebraStore(c, args, store.SUnionStore)
+}
+
+// handleSDiffStore 实现 SDIFFSTORE destination key [key ...]
+func handleSDiffStore(c *Client, args [][]byte, store *store.BadgerStore) {
+	setAlgebraStore(c, args, store.SDiffStore)
+}
+
+// setAlgebraStore 回复写入 destination 的成员数量
+func setAlgebraStore(c *Client, args [][]byte, compute func(destination []byte, keys ...[]byte) (uint64, error)) {
+	count, err := compute(args[0], args[1:]...)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(int64(count))
+}
+
+// handleSInterCard 实现 SINTERCARD numkeys key [key ...] [LIMIT limit]
+func handleSInterCard(c *Client, args [][]byte, store *store.BadgerStore) {
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil || numKeys <= 0 {
+		c.WriteError(errNumKeys)
+		return
+	}
+	if numKeys > int64(len(args)-1) {
+		c.WriteError(errSInterCardNumKeys)
+		return
+	}
+	var limit uint64
+	rest := args[numKeys+1:]
+	for i := 0; i < len(rest); i++ {
+		if !strings.EqualFold(string(rest[i]), "LIMIT") || i+1 >= len(rest) {
+			c.WriteError(errSyntax)
+			return
+		}
+		n, err := strconv.ParseInt(string(rest[i+1]), 10, 64)
+		if err != nil || n < 0 {
+			c.WriteError(errLimitNegative)
+			return
+		}
+		limit = uint64(n)
+		i++
+	}
+	count, err := store.SInterCard(args[1:numKeys+1], limit)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(int64(count))
+}
+
+// writeSetReply 以 RESP3 的集合类型回复成员，RESP2 下为普通数组
+func writeSetReply(c *Client, members [][]byte) {
+	c.WriteSetHeader(len(members))
+	for _, member := range members {
+		c.WriteBulk(member)
+	}
+}
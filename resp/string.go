@@ -3,35 +3,353 @@ package resp
 import (
 	"PumbaaDB/store"
 	"fmt"
-	"net"
+	"math"
 	"strconv"
+	"strings"
 	"time"
 )
 
-func HandleSet(conn net.Conn, args [][]byte, store *store.BadgerStore) {
-	if len(args) < 2 {
-		conn.Write(Encode(fmt.Errorf("ERR wrong number of arguments")))
+// 过期时间参数的单位
+const (
+	expireEX   = "EX"
+	expirePX   = "PX"
+	expireEXAT = "EXAT"
+	expirePXAT = "PXAT"
+)
+
+// parseExpireAt 把 EX/PX/EXAT/PXAT 参数换算为毫秒时间戳，cmdName 用于错误信息
+func parseExpireAt(unit string, arg []byte, cmdName string) (int64, error) {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	invalid := fmt.Errorf("ERR invalid expire time in '%s' command", cmdName)
+	if n <= 0 {
+		return 0, invalid
+	}
+	if unit == expireEX || unit == expireEXAT {
+		if n > math.MaxInt64/1000 {
+			return 0, invalid
+		}
+		n *= 1000
+	}
+	if unit == expireEX || unit == expirePX {
+		now := time.Now().UnixMilli()
+		if n > math.MaxInt64-now {
+			return 0, invalid
+		}
+		n += now
+	}
+	return n, nil
+}
+
+// HandleSet 实现 SET key value [NX | XX] [GET] [EX seconds | PX milliseconds |
+// EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+func HandleSet(c *Client, args [][]byte, s *store.BadgerStore) {
+	var opt store.SetOption
+	expireUnit := ""
+	for i := 2; i < len(args); i++ {
+		switch option := strings.ToUpper(string(args[i])); option {
+		case "NX":
+			if opt.XX || opt.NX {
+				c.WriteError(errSyntax)
+				return
+			}
+			opt.NX = true
+		case "XX":
+			if opt.NX || opt.XX {
+				c.WriteError(errSyntax)
+				return
+			}
+			opt.XX = true
+		case "GET":
+			opt.Get = true
+		case "KEEPTTL":
+			if expireUnit != "" || opt.KeepTTL {
+				c.WriteError(errSyntax)
+				return
+			}
+			opt.KeepTTL = true
+		case expireEX, expirePX, expireEXAT, expirePXAT:
+			if expireUnit != "" || opt.KeepTTL || i+1 >= len(args) {
+				c.WriteError(errSyntax)
+				return
+			}
+			expireAt, err := parseExpireAt(option, args[i+1], "set")
+			if err != nil {
+				c.WriteError(err)
+				return
+			}
+			expireUnit = option
+			opt.ExpireAt = expireAt
+			i++
+		default:
+			c.WriteError(errSyntax)
+			return
+		}
+	}
+
+	old, written, err := s.SetWithOption(args[0], args[1], opt)
+	switch {
+	case err != nil:
+		c.WriteError(err)
+	case opt.Get:
+		c.WriteBulk(old)
+	case !written:
+		c.WriteNullBulk()
+	default:
+		c.WriteOK()
+	}
+}
+
+// handleGet 实现 GET key
+func handleGet(c *Client, args [][]byte, store *store.BadgerStore) {
+	value, err := store.Get(args[0])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteBulk(value)
+}
+
+// handleSetNX 实现 SETNX key value
+func handleSetNX(c *Client, args [][]byte, store *store.BadgerStore) {
+	written, err := store.SetNX(args[0], args[1])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if written {
+		c.WriteInt64(1)
+	} else {
+		c.WriteInt64(0)
+	}
+}
+
+// handleSetEX 实现 SETEX key seconds value
+func handleSetEX(c *Client, args [][]byte, store *store.BadgerStore) {
+	setWithExpire(c, args, store, expireEX, "setex")
+}
+
+// handlePSetEX 实现 PSETEX key milliseconds value
+func handlePSetEX(c *Client, args [][]byte, store *store.BadgerStore) {
+	setWithExpire(c, args, store, expirePX, "psetex")
+}
+
+func setWithExpire(c *Client, args [][]byte, s *store.BadgerStore, unit, cmdName string) {
+	expireAt, err := parseExpireAt(unit, args[1], cmdName)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if _, _, err := s.SetWithOption(args[0], args[2], store.SetOption{ExpireAt: expireAt}); err != nil {
+		c.WriteError(err)
 		return
 	}
-	var ttl time.Duration
-	if len(args) > 2 {
-		for i := 2; i < len(args); i++ {
-			switch string(args[i]) {
-			case "EX":
-				if i+1 >= len(args) {
-					conn.Write(Encode(fmt.Errorf("ERR syntax error")))
-					return
-				}
-				sec, _ := strconv.Atoi(string(args[i+1]))
-				ttl = time.Duration(sec) * time.Second
-				i++
+	c.WriteOK()
+}
+
+// handleGetSet 实现 GETSET key value
+func handleGetSet(c *Client, args [][]byte, store *store.BadgerStore) {
+	old, err := store.GetSet(args[0], args[1])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteBulk(old)
+}
+
+// handleGetDel 实现 GETDEL key
+func handleGetDel(c *Client, args [][]byte, store *store.BadgerStore) {
+	value, err := store.GetDel(args[0])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteBulk(value)
+}
+
+// handleGetEx 实现 GETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds |
+// PXAT unix-time-milliseconds | PERSIST]
+func handleGetEx(c *Client, args [][]byte, store *store.BadgerStore) {
+	var expireAt int64
+	persist := false
+	if len(args) > 1 {
+		switch option := strings.ToUpper(string(args[1])); option {
+		case "PERSIST":
+			if len(args) != 2 {
+				c.WriteError(errSyntax)
+				return
+			}
+			persist = true
+		case expireEX, expirePX, expireEXAT, expirePXAT:
+			if len(args) != 3 {
+				c.WriteError(errSyntax)
+				return
 			}
+			var err error
+			if expireAt, err = parseExpireAt(option, args[2], "getex"); err != nil {
+				c.WriteError(err)
+				return
+			}
+		default:
+			c.WriteError(errSyntax)
+			return
 		}
 	}
-	err := store.Set(args[0], args[1], ttl)
+	value, err := store.GetEx(args[0], expireAt, persist)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteBulk(value)
+}
+
+// handleIncr 实现 INCR key
+func handleIncr(c *Client, args [][]byte, store *store.BadgerStore) {
+	incrBy(c, args[0], 1, store)
+}
+
+// handleDecr 实现 DECR key
+func handleDecr(c *Client, args [][]byte, store *store.BadgerStore) {
+	incrBy(c, args[0], -1, store)
+}
+
+// handleIncrBy 实现 INCRBY key increment
+func handleIncrBy(c *Client, args [][]byte, store *store.BadgerStore) {
+	delta, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	incrBy(c, args[0], delta, store)
+}
+
+// handleDecrBy 实现 DECRBY key decrement
+func handleDecrBy(c *Client, args [][]byte, store *store.BadgerStore) {
+	delta, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	if delta == math.MinInt64 {
+		c.WriteError(fmt.Errorf("ERR decrement would overflow"))
+		return
+	}
+	incrBy(c, args[0], -delta, store)
+}
+
+func incrBy(c *Client, key []byte, delta int64, store *store.BadgerStore) {
+	result, err := store.IncrBy(key, delta)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(result)
+}
+
+// handleIncrByFloat 实现 INCRBYFLOAT key increment
+func handleIncrByFloat(c *Client, args [][]byte, store *store.BadgerStore) {
+	result, err := store.IncrByFloat(args[0], args[1])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteBulk(result)
+}
+
+// handleAppend 实现 APPEND key value
+func handleAppend(c *Client, args [][]byte, store *store.BadgerStore) {
+	length, err := store.Append(args[0], args[1])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(length)
+}
+
+// handleStrLen 实现 STRLEN key
+func handleStrLen(c *Client, args [][]byte, store *store.BadgerStore) {
+	length, err := store.StrLen(args[0])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(length)
+}
+
+// handleGetRange 实现 GETRANGE key start end
+func handleGetRange(c *Client, args [][]byte, store *store.BadgerStore) {
+	start, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	end, err := strconv.ParseInt(string(args[2]), 10, 64)
 	if err != nil {
-		conn.Write(Encode(err))
+		c.WriteError(errNotInteger)
+		return
+	}
+	value, err := store.GetRange(args[0], start, end)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteBulk(value)
+}
+
+// handleSetRange 实现 SETRANGE key offset value
+func handleSetRange(c *Client, args [][]byte, store *store.BadgerStore) {
+	offset, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	length, err := store.SetRange(args[0], offset, args[2])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(length)
+}
+
+// handleMGet 实现 MGET key [key ...]
+func handleMGet(c *Client, args [][]byte, store *store.BadgerStore) {
+	values, err := store.MGet(args)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteBulkArray(values)
+}
+
+// handleMSet 实现 MSET key value [key value ...]
+func handleMSet(c *Client, args [][]byte, store *store.BadgerStore) {
+	if len(args)%2 != 0 {
+		c.WriteError(errWrongArgs("mset"))
+		return
+	}
+	if err := store.MSet(args); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteOK()
+}
+
+// handleMSetNX 实现 MSETNX key value [key value ...]
+func handleMSetNX(c *Client, args [][]byte, store *store.BadgerStore) {
+	if len(args)%2 != 0 {
+		c.WriteError(errWrongArgs("msetnx"))
+		return
+	}
+	written, err := store.MSetNX(args)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if written {
+		c.WriteInt64(1)
 	} else {
-		conn.Write(Encode("OK"))
+		c.WriteInt64(0)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,301 @@
+package resp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// writerBufSize 是每个连接的写缓冲大小
+const writerBufSize = 16 * 1024
+
+// ReplyWriter 按连接的协议版本把回复编码写入缓冲，由连接循环在一批流水线命令处理完后统一 Flush。
+// 处理函数只通过它回复客户端，不直接写 socket。
+type ReplyWriter struct {
+	w     *bufio.Writer
+	proto int
+	num   [20]byte
+}
+
+// NewReplyWriter 创建一个 RESP2 协议的 ReplyWriter
+func NewReplyWriter(w io.Writer) *ReplyWriter {
+	return &ReplyWriter{w: bufio.NewWriterSize(w, writerBufSize), proto: ProtoRESP2}
+}
+
+// Proto 返回当前的协议版本
+func (w *ReplyWriter) Proto() int {
+	return w.proto
+}
+
+// SetProto 切换协议版本，由 HELLO 调用
+func (w *ReplyWriter) SetProto(proto int) {
+	w.proto = proto
+}
+
+func (w *ReplyWriter) resp3() bool {
+	return w.proto == ProtoRESP3
+}
+
+// Flush 把缓冲中的回复写到连接上
+func (w *ReplyWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// Buffered 返回尚未 Flush 的字节数
+func (w *ReplyWriter) Buffered() int {
+	return w.w.Buffered()
+}
+
+func (w *ReplyWriter) writeHeader(prefix byte, n int64) {
+	w.w.WriteByte(prefix)
+	w.w.Write(strconv.AppendInt(w.num[:0], n, 10))
+	w.w.WriteString("\r\n")
+}
+
+// WriteOK 回复 +OK
+func (w *ReplyWriter) WriteOK() {
+	w.w.WriteString("+OK\r\n")
+}
+
+// WriteSimpleString 回复状态字符串，s 中不能包含 \r\n
+func (w *ReplyWriter) WriteSimpleString(s string) {
+	w.w.WriteByte('+')
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+// WriteError 回复错误。没有以大写错误码（如 ERR、WRONGTYPE）开头的错误会补上 ERR 前缀
+func (w *ReplyWriter) WriteError(err error) {
+	msg := err.Error()
+	if !hasErrorCode(msg) {
+		msg = "ERR " + msg
+	}
+	msg = strings.NewReplacer("\r", " ", "\n", " ").Replace(msg)
+	w.w.WriteByte('-')
+	w.w.WriteString(msg)
+	w.w.WriteString("\r\n")
+}
+
+func hasErrorCode(msg string) bool {
+	code, _, _ := strings.Cut(msg, " ")
+	if code == "" {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < 'A' || code[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteBulk 回复二进制安全的字符串，nil 等价于 WriteNullBulk
+func (w *ReplyWriter) WriteBulk(b []byte) {
+	if b == nil {
+		w.WriteNullBulk()
+		return
+	}
+	w.writeHeader('$', int64(len(b)))
+	w.w.Write(b)
+	w.w.WriteString("\r\n")
+}
+
+// WriteBulkString 回复字符串
+func (w *ReplyWriter) WriteBulkString(s string) {
+	w.writeHeader('$', int64(len(s)))
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+// WriteBulkArray 回复由字符串组成的数组，其中的 nil 元素回复为空值
+func (w *ReplyWriter) WriteBulkArray(items [][]byte) {
+	w.WriteArrayHeader(len(items))
+	for _, item := range items {
+		w.WriteBulk(item)
+	}
+}
+
+// WriteInt64 回复整数
+func (w *ReplyWriter) WriteInt64(n int64) {
+	w.writeHeader(':', n)
+}
+
+// WriteNullBulk 回复空字符串，RESP2 为 $-1，RESP3 为 _
+func (w *ReplyWriter) WriteNullBulk() {
+	if w.resp3() {
+		w.w.WriteString("_\r\n")
+		return
+	}
+	w.w.WriteString("$-1\r\n")
+}
+
+// WriteNullArray 回复空数组，RESP2 为 *-1，RESP3 为 _
+func (w *ReplyWriter) WriteNullArray() {
+	if w.resp3() {
+		w.w.WriteString("_\r\n")
+		return
+	}
+	w.w.WriteString("*-1\r\n")
+}
+
+// WriteArrayHeader 写入数组头，随后需要写入 n 个元素
+func (w *ReplyWriter) WriteArrayHeader(n int) {
+	w.writeHeader('*', int64(n))
+}
+
+// WriteMapHeader 写入 map 头，随后需要写入 n 对键值。RESP2 下为 2n 个元素的数组
+func (w *ReplyWriter) WriteMapHeader(n int) {
+	if w.resp3() {
+		w.writeHeader('%', int64(n))
+		return
+	}
+	w.writeHeader('*', int64(n)*2)
+}
+
+// WriteSetHeader 写入集合头，随后需要写入 n 个元素。RESP2 下为数组
+func (w *ReplyWriter) WriteSetHeader(n int) {
+	if w.resp3() {
+		w.writeHeader('~', int64(n))
+		return
+	}
+	w.writeHeader('*', int64(n))
+}
+
+// WritePushHeader 写入推送消息头，随后需要写入 n 个元素。RESP2 下为数组
+func (w *ReplyWriter) WritePushHeader(n int) {
+	if w.resp3() {
+		w.writeHeader('>', int64(n))
+		return
+	}
+	w.writeHeader('*', int64(n))
+}
+
+// WriteMap 回复 field -> value 的映射，field 按字典序输出
+func (w *ReplyWriter) WriteMap(m map[string][]byte) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	w.WriteMapHeader(len(keys))
+	for _, k := range keys {
+		w.WriteBulkString(k)
+		w.WriteBulk(m[k])
+	}
+}
+
+// WriteDouble 回复浮点数，RESP2 下为字符串
+func (w *ReplyWriter) WriteDouble(f float64) {
+	s := formatDouble(f)
+	if w.resp3() {
+		w.w.WriteByte(',')
+		w.w.WriteString(s)
+		w.w.WriteString("\r\n")
+		return
+	}
+	w.WriteBulkString(s)
+}
+
+// WriteBool 回复布尔值，RESP2 下为整数 1/0
+func (w *ReplyWriter) WriteBool(b bool) {
+	if w.resp3() {
+		if b {
+			w.w.WriteString("#t\r\n")
+		} else {
+			w.w.WriteString("#f\r\n")
+		}
+		return
+	}
+	if b {
+		w.WriteInt64(1)
+	} else {
+		w.WriteInt64(0)
+	}
+}
+
+// WriteBigNumber 回复十进制表示的大整数，RESP2 下为字符串
+func (w *ReplyWriter) WriteBigNumber(n string) {
+	if w.resp3() {
+		w.w.WriteByte('(')
+		w.w.WriteString(n)
+		w.w.WriteString("\r\n")
+		return
+	}
+	w.WriteBulkString(n)
+}
+
+// WriteVerbatim 回复带格式的字符串，format 为三个字符（如 txt），RESP2 下为普通字符串
+func (w *ReplyWriter) WriteVerbatim(format, text string) {
+	if w.resp3() {
+		w.writeHeader('=', int64(len(text)+4))
+		w.w.WriteString(format)
+		w.w.WriteByte(':')
+		w.w.WriteString(text)
+		w.w.WriteString("\r\n")
+		return
+	}
+	w.WriteBulkString(text)
+}
+
+// WriteValue 按 Go 类型递归写入回复，用于 COMMAND 这类结构固定的嵌套回复。
+// 普通命令应优先使用上面的具体方法。
+func (w *ReplyWriter) WriteValue(value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		w.WriteNullBulk()
+	case nullReply:
+		w.WriteNullBulk()
+	case string:
+		w.WriteSimpleString(v)
+	case []byte:
+		w.WriteBulk(v)
+	case [][]byte:
+		w.WriteBulkArray(v)
+	case int:
+		w.WriteInt64(int64(v))
+	case int64:
+		w.WriteInt64(v)
+	case uint64:
+		w.WriteInt64(int64(v))
+	case float64:
+		w.WriteDouble(v)
+	case Double:
+		w.WriteDouble(float64(v))
+	case bool:
+		w.WriteBool(v)
+	case Bool:
+		w.WriteBool(bool(v))
+	case BigNumber:
+		w.WriteBigNumber(string(v))
+	case Verbatim:
+		w.WriteVerbatim(v.Format, v.Text)
+	case error:
+		w.WriteError(v)
+	case []interface{}:
+		w.WriteArrayHeader(len(v))
+		for _, item := range v {
+			w.WriteValue(item)
+		}
+	case Set:
+		w.WriteSetHeader(len(v))
+		for _, item := range v {
+			w.WriteValue(item)
+		}
+	case Push:
+		w.WritePushHeader(len(v))
+		for _, item := range v {
+			w.WriteValue(item)
+		}
+	case Map:
+		w.WriteMapHeader(len(v))
+		for _, item := range v {
+			w.WriteValue(item.Key)
+			w.WriteValue(item.Value)
+		}
+	default:
+		w.WriteError(fmt.Errorf("ERR unsupported reply type %T", value))
+	}
+}
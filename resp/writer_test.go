@@ -0,0 +1,60 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func writeWith(proto int, fn func(w *ReplyWriter)) string {
+	var buf bytes.Buffer
+	w := NewReplyWriter(&buf)
+	w.SetProto(proto)
+	fn(w)
+	w.Flush()
+	return buf.String()
+}
+
+func TestReplyWriterTypes(t *testing.T) {
+	m := map[string][]byte{"name": []byte("Alice")}
+	assert.Equal(t, "*2\r\n$4\r\nname\r\n$5\r\nAlice\r\n", writeWith(ProtoRESP2, func(w *ReplyWriter) { w.WriteMap(m) }))
+	assert.Equal(t, "%1\r\n$4\r\nname\r\n$5\r\nAlice\r\n", writeWith(ProtoRESP3, func(w *ReplyWriter) { w.WriteMap(m) }))
+
+	assert.Equal(t, "$-1\r\n", writeWith(ProtoRESP2, func(w *ReplyWriter) { w.WriteNullBulk() }))
+	assert.Equal(t, "*-1\r\n", writeWith(ProtoRESP2, func(w *ReplyWriter) { w.WriteNullArray() }))
+	assert.Equal(t, "_\r\n", writeWith(ProtoRESP3, func(w *ReplyWriter) { w.WriteBulk(nil) }))
+	assert.Equal(t, "_\r\n", writeWith(ProtoRESP3, func(w *ReplyWriter) { w.WriteNullArray() }))
+
+	assert.Equal(t, ":-42\r\n", writeWith(ProtoRESP2, func(w *ReplyWriter) { w.WriteInt64(-42) }))
+	assert.Equal(t, ":7\r\n", writeWith(ProtoRESP2, func(w *ReplyWriter) { w.WriteValue(uint64(7)) }))
+
+	assert.Equal(t, "$3\r\n1.5\r\n", writeWith(ProtoRESP2, func(w *ReplyWriter) { w.WriteDouble(1.5) }))
+	assert.Equal(t, ",1.5\r\n", writeWith(ProtoRESP3, func(w *ReplyWriter) { w.WriteDouble(1.5) }))
+	assert.Equal(t, ",-inf\r\n", writeWith(ProtoRESP3, func(w *ReplyWriter) { w.WriteDouble(math.Inf(-1)) }))
+
+	assert.Equal(t, ":1\r\n", writeWith(ProtoRESP2, func(w *ReplyWriter) { w.WriteBool(true) }))
+	assert.Equal(t, "#f\r\n", writeWith(ProtoRESP3, func(w *ReplyWriter) { w.WriteBool(false) }))
+
+	assert.Equal(t, "(12345678901234567890\r\n", writeWith(ProtoRESP3, func(w *ReplyWriter) { w.WriteBigNumber("12345678901234567890") }))
+	assert.Equal(t, "=7\r\ntxt:abc\r\n", writeWith(ProtoRESP3, func(w *ReplyWriter) { w.WriteVerbatim("txt", "abc") }))
+	assert.Equal(t, "$3\r\nabc\r\n", writeWith(ProtoRESP2, func(w *ReplyWriter) { w.WriteVerbatim("txt", "abc") }))
+}
+
+func TestReplyWriterError(t *testing.T) {
+	assert.Equal(t, "-WRONGTYPE bad\r\n", writeWith(ProtoRESP2, func(w *ReplyWriter) { w.WriteError(errors.New("WRONGTYPE bad")) }))
+	assert.Equal(t, "-ERR Key not found\r\n", writeWith(ProtoRESP2, func(w *ReplyWriter) { w.WriteError(errors.New("Key not found")) }))
+	assert.Equal(t, "-ERR a  b\r\n", writeWith(ProtoRESP2, func(w *ReplyWriter) { w.WriteError(errors.New("ERR a\r\nb")) }))
+}
+
+func TestReplyWriterBuffersUntilFlush(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewReplyWriter(&buf)
+	w.WriteOK()
+	w.WriteInt64(1)
+	assert.Equal(t, 0, buf.Len())
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "+OK\r\n:1\r\n", buf.String())
+}
@@ -0,0 +1,157 @@
+package resp
+
+import (
+	"PumbaaDB/store"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parseScore 解析分数，接受 inf、+inf、-inf，不接受 nan 和超出 float64 范围的值
+func parseScore(arg []byte) (float64, error) {
+	score, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, store.ErrNotFloat
+	}
+	return score, nil
+}
+
+// parseZAdd 解析 ZADD 的选项和 score member 对，INCR 时 members 只有一个元素
+func parseZAdd(args [][]byte) (opt store.ZAddOption, incr bool, members []store.ZMember, err error) {
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NX":
+			opt.NX = true
+		case "XX":
+			opt.XX = true
+		case "GT":
+			opt.GT = true
+		case "LT":
+			opt.LT = true
+		case "CH":
+			opt.CH = true
+		case "INCR":
+			incr = true
+		default:
+			break options
+		}
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return opt, false, nil, errSyntax
+	}
+	if opt.NX && opt.XX {
+		return opt, false, nil, errZAddNXXX
+	}
+	if (opt.GT && opt.NX) || (opt.LT && opt.NX) || (opt.GT && opt.LT) {
+		return opt, false, nil, errZAddGTLTNX
+	}
+	if incr && len(pairs) > 2 {
+		return opt, false, nil, errZAddIncr
+	}
+	members = make([]store.ZMember, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, err := parseScore(pairs[j])
+		if err != nil {
+			return opt, false, nil, err
+		}
+		members = append(members, store.ZMember{Member: pairs[j+1], Score: score})
+	}
+	return opt, incr, members, nil
+}
+
+// handleZAdd 实现 ZADD key [NX | XX] [GT | LT] [CH] [INCR] score member [score member ...]
+func handleZAdd(c *Client, args [][]byte, store *store.BadgerStore) {
+	opt, incr, members, err := parseZAdd(args)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if incr {
+		score, ok, err := store.ZAddIncr(args[0], opt, members[0].Member, members[0].Score)
+		if err != nil {
+			c.WriteError(err)
+			return
+		}
+		if !ok {
+			c.WriteNullBulk()
+			return
+		}
+		c.WriteDouble(score)
+		return
+	}
+	changed, err := store.ZAdd(args[0], opt, members)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(changed)
+}
+
+// handleZIncrBy 实现 ZINCRBY key increment member，回复新的分数
+func handleZIncrBy(c *Client, args [][]byte, store *store.BadgerStore) {
+	incr, err := parseScore(args[1])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	score, err := store.ZIncrBy(args[0], incr, args[2])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteDouble(score)
+}
+
+// handleZScore 实现 ZSCORE key member，成员不存在时回复空值
+func handleZScore(c *Client, args [][]byte, store *store.BadgerStore) {
+	score, exists, err := store.ZScore(args[0], args[1])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	if !exists {
+		c.WriteNullBulk()
+		return
+	}
+	c.WriteDouble(score)
+}
+
+// handleZMScore 实现 ZMSCORE key member [member ...]，不存在的成员回复空值
+func handleZMScore(c *Client, args [][]byte, store *store.BadgerStore) {
+	scores, err := store.ZMScore(args[0], args[1:]...)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteArrayHeader(len(scores))
+	for _, score := range scores {
+		if score == nil {
+			c.WriteNullBulk()
+		} else {
+			c.WriteDouble(*score)
+		}
+	}
+}
+
+// handleZCard 实现 ZCARD key
+func handleZCard(c *Client, args [][]byte, store *store.BadgerStore) {
+	count, err := store.ZCard(args[0])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(int64(count))
+}
+
+// handleZRem 实现 ZREM key member [member ...]，回复实际删除的成员数量
+func handleZRem(c *Client, args [][]byte, store *store.BadgerStore) {
+	removed, err := store.ZRem(args[0], args[1:]...)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteInt64(removed)
+}
@@ -1,61 +1,204 @@
 package store
 
 import (
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
 
 	"github.com/dgraph-io/badger/v4"
 )
 
 func (s *BadgerStore) Del(key string) error {
 	// TODO 需要完善，多个key，返回删除的数量
-	bKey := []byte(key)
-	bTypeKey := TypeKeyGet(key)
 	return s.db.Update(func(txn *badger.Txn) error {
-		item, err := txn.Get(bTypeKey)
+		existed, err := s.deleteKey(txn, []byte(key))
 		if err != nil {
 			return err
 		}
-		valCopy, errValueCopy := item.ValueCopy(nil)
-		if errValueCopy != nil {
-			return errValueCopy
-		}
-		switch string(valCopy) {
-		case KeyTypeString:
-			// 处理 type1 的删除逻辑
-			return s.DelString(key)
-		case KeyTypeList:
-			// 处理 type2 的删除逻辑
-			return txn.Delete(bKey)
-		case KeyTypeHash:
-			// 处理 type3 的删除逻辑
-			return txn.Delete(bKey)
-		case KeyTypeSet:
-			// 处理 type4 的删除逻辑
-			return txn.Delete(bKey)
-		case KeyTypeZSet:
-			// 处理 type5 的删除逻辑
-			return txn.Delete(bKey)
-		default:
-			// 默认的删除逻辑
-			return txn.Delete(bKey)
+		if !existed {
+			return badger.ErrKeyNotFound
 		}
+		return nil
 	})
 }
 
 func (s *BadgerStore) DelString(key string) error {
 	logFuncTag := "BadgerStoreDelString"
-	bKey := []byte(key)
-	badgerTypeKey := TypeKeyGet(key)
-	badgerValueKey := keyBadgetGet(prefixKeyString, bKey)
 	return s.db.Update(func(txn *badger.Txn) error {
-		errDel := txn.Delete(badgerTypeKey)
-		if errDel != nil {
-			return fmt.Errorf("%s,Del Badger Type Key:%v", logFuncTag, errDel)
-		}
-		errDel = txn.Delete(badgerValueKey)
-		if errDel != nil {
-			return fmt.Errorf("%s,Del Badger Value Key:%v", logFuncTag, errDel)
+		if err := s.stringDelete(txn, []byte(key)); err != nil {
+			return fmt.Errorf("%s,%v", logFuncTag, err)
 		}
 		return nil
 	})
 }
+
+// keyType 返回 key 当前的类型，key 不存在或已过期时返回空字符串
+func (s *BadgerStore) keyType(txn *badger.Txn, key []byte) (string, error) {
+	item, err := txn.Get(TypeKeyGet(string(key)))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return "", err
+	}
+	keyType := string(val)
+	if keyType == KeyTypeString {
+		// 类型标记的 badger TTL 只精确到秒，毫秒级的过期以字符串值中的时间戳为准
+		entry, err := s.stringRead(txn, key)
+		if err != nil {
+			return "", err
+		}
+		if entry == nil {
+			return "", nil
+		}
+	}
+	return keyType, nil
+}
+
+// checkKeyType 检查 key 的类型，key 存在但不是 want 类型时返回 ErrWrongType
+func (s *BadgerStore) checkKeyType(txn *badger.Txn, key []byte, want string) (exists bool, err error) {
+	keyType, err := s.keyType(txn, key)
+	if err != nil {
+		return false, err
+	}
+	if keyType == "" {
+		return false, nil
+	}
+	if keyType != want {
+		return true, ErrWrongType
+	}
+	return true, nil
+}
+
+// setKeyType 写入 key 的类型标记
+func (s *BadgerStore) setKeyType(txn *badger.Txn, key []byte, keyType string) error {
+	return txn.Set(TypeKeyGet(string(key)), []byte(keyType))
+}
+
+// deleteKey 删除 key 的类型标记和全部数据，返回删除前 key 是否存在。
+// 各类型的记录都在 keyPrefix 生成的带长度的前缀下，名字以 key 开头的其他 key 不受影响
+func (s *BadgerStore) deleteKey(txn *badger.Txn, key []byte) (bool, error) {
+	keyType, err := s.keyType(txn, key)
+	if err != nil {
+		return false, err
+	}
+	switch keyType {
+	case "":
+		return false, nil
+	case KeyTypeString:
+		err = s.stringDelete(txn, key)
+	case KeyTypeList:
+		err = deletePrefix(txn, keyPrefix(prefixKeyList, key, ""))
+	case KeyTypeHash:
+		err = deletePrefix(txn, keyPrefix(prefixKeyHash, key, ""))
+	case KeyTypeSet:
+		err = deletePrefix(txn, keyPrefix(prefixKeySet, key, ""))
+	case KeyTypeZSet:
+		err = deletePrefix(txn, keyPrefix(prefixKeyZSet, key, ""))
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, txn.Delete(TypeKeyGet(string(key)))
+}
+
+// deletePrefix 删除所有以 prefix 开头的键
+func deletePrefix(txn *badger.Txn, prefix []byte) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+	var keys [][]byte
+	for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+		keys = append(keys, iter.Item().KeyCopy(nil))
+	}
+	for _, k := range keys {
+		if err := txn.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxConflictRetries 是读改写事务遇到冲突时的最大重试次数
+const maxConflictRetries = 100
+
+// update 在读写事务中执行 fn，提交时遇到 badger.ErrConflict 会重新执行整个事务，
+// 保证多个连接并发地读改写同一个 key 时不会丢失更新。fn 可能被执行多次，不能有事务外的副作用
+func (s *BadgerStore) update(fn func(txn *badger.Txn) error) error {
+	for i := 0; ; i++ {
+		err := s.db.Update(fn)
+		if !errors.Is(err, badger.ErrConflict) || i >= maxConflictRetries {
+			return err
+		}
+	}
+}
+
+// nowMilli 返回当前的毫秒时间戳，过期时间统一使用毫秒时间戳表示
+func nowMilli() int64 {
+	return time.Now().UnixMilli()
+}
+
+// maxRandomCount 是 HRANDFIELD、SRANDMEMBER 的负数 count 允许的最大绝对值。
+// 负数 count 允许重复，结果的长度与 key 的大小无关，需要单独限制
+const maxRandomCount = 1 << 20
+
+// errIterationDone 用于提前结束没有停止机制的遍历
+var errIterationDone = errors.New("iteration done")
+
+// randomSample 为 HRANDFIELD、SRANDMEMBER、SPOP 等命令从 size 个元素中随机抽样，返回的顺序是随机的。
+// iterate 按固定的顺序把元素逐个交给回调，回调返回 false 时停止遍历。
+// count 为正数时用选择抽样在一次遍历中选出 min(count, size) 个不重复的元素，只保存选中的元素；
+// count 为负数时选出 -count 个可能重复的元素，-count 超过 maxRandomCount 时返回 ErrRandomCountRange
+func randomSample[T any](size uint64, count int64, iterate func(fn func(item T) bool) error) ([]T, error) {
+	if size == 0 || count == 0 {
+		return nil, nil
+	}
+	var result []T
+	var err error
+	if count > 0 {
+		// 剩下 remaining 个元素里还要选 need 个，当前元素以 need/remaining 的概率被选中
+		need := min(uint64(count), size)
+		remaining := size
+		result = make([]T, 0, need)
+		err = iterate(func(item T) bool {
+			if rand.Uint64N(remaining) < need {
+				result = append(result, item)
+				need--
+			}
+			remaining--
+			return need > 0 && remaining > 0
+		})
+	} else {
+		if count < -maxRandomCount {
+			return nil, ErrRandomCountRange
+		}
+		// 先选出有序的序号，再在一次遍历中按序号取出元素
+		picks := make([]uint64, -count)
+		for i := range picks {
+			picks[i] = rand.Uint64N(size)
+		}
+		slices.Sort(picks)
+		result = make([]T, 0, len(picks))
+		var index uint64
+		err = iterate(func(item T) bool {
+			for len(result) < len(picks) && picks[len(result)] == index {
+				result = append(result, item)
+			}
+			index++
+			return len(result) < len(picks)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	rand.Shuffle(len(result), func(i, j int) { result[i], result[j] = result[j], result[i] })
+	return result, nil
+}
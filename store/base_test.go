@@ -1 +1,44 @@
 package store
+
+import (
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+// 覆盖或删除一个 key 时，名字以 key: 开头的其他 key 保持不变
+func TestDeleteKeyKeepsNeighbours(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	field := [][]byte{[]byte("f"), []byte("v")}
+
+	for _, key := range []string{"user", "tags", "q", "bits", "dest"} {
+		_, _ = store.HSet([]byte(key), field)
+		_, _ = store.HSet([]byte(key+":1"), field)
+		_, _ = store.SAdd([]byte(key+":set"), []byte("m"))
+		_, _ = store.RPush([]byte(key+":list"), []byte("e"))
+		_, _ = store.ZAdd([]byte(key+":zset"), ZAddOption{}, []ZMember{{Member: []byte("m"), Score: 1}})
+	}
+	_ = store.Set([]byte("src"), []byte{0xff})
+
+	assert.NoError(t, store.Set([]byte("user"), []byte("x")))
+	assert.NoError(t, store.Del("tags"))
+	assert.NoError(t, store.MSet([][]byte{[]byte("q"), []byte("v")}))
+	_, err := store.BitOp(BitOpOr, []byte("bits"), [][]byte{[]byte("src")})
+	assert.NoError(t, err)
+	_, _ = store.SAdd([]byte("other"), []byte("m"))
+	_, err = store.SUnionStore([]byte("dest"), []byte("other"))
+	assert.NoError(t, err)
+
+	for _, key := range []string{"user", "tags", "q", "bits", "dest"} {
+		all, err := store.HGetAll([]byte(key + ":1"))
+		assert.NoError(t, err)
+		assert.Equal(t, map[string][]byte{"f": []byte("v")}, all)
+		members, _ := store.SMembers([]byte(key + ":set"))
+		assert.Equal(t, [][]byte{[]byte("m")}, members)
+		values, _ := store.LRange([]byte(key+":list"), 0, -1)
+		assert.Equal(t, [][]byte{[]byte("e")}, values)
+		count, _ := store.ZCard([]byte(key + ":zset"))
+		assert.Equal(t, uint64(1), count)
+	}
+}
@@ -0,0 +1,679 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// 位图保存在字符串中，bit 的顺序与 Redis 一致：第 0 位是第一个字节的最高位。
+// 大的位图复用字符串的分片存储，SETBIT 和 BITFIELD 只改写涉及的分片，
+// BITCOUNT、BITPOS 只读取实际存在的分片
+
+// MaxBitOffset 是 bit 偏移量的上限，对应 512MB 的字符串
+const MaxBitOffset = maxStringSize*8 - 1
+
+var (
+	// ErrBitOffset 表示 bit 偏移量不是整数或超出范围
+	ErrBitOffset = errors.New("ERR bit offset is not an integer or out of range")
+	// ErrBitValue 表示 SETBIT 的值不是 0 或 1
+	ErrBitValue = errors.New("ERR bit is not an integer or out of range")
+	// ErrBitOpNot 表示 BITOP NOT 指定了多个源 key
+	ErrBitOpNot = errors.New("ERR BITOP NOT must be called with a single source key.")
+)
+
+// BITOP 支持的运算
+const (
+	BitOpAnd = "AND"
+	BitOpOr  = "OR"
+	BitOpXor = "XOR"
+	BitOpNot = "NOT"
+)
+
+// BitRange 是 BITCOUNT 和 BITPOS 的区间参数，Start 和 End 都包含在内，负数表示从末尾倒数
+type BitRange struct {
+	Start, End int64
+	BitUnit    bool // 区间以 bit 为单位，否则以字节为单位
+}
+
+// bitByteRange 按 Redis 的规则把区间换算为字节区间 [start, end]，
+// BIT 单位时同时返回首尾字节中不在区间内的 bit 掩码。start > end 表示区间为空
+func bitByteRange(r BitRange, strLen int64) (start, end int64, firstMask, lastMask byte) {
+	total := strLen
+	if r.BitUnit {
+		total = strLen * 8
+	}
+	start, end = r.Start, r.End
+	if start < 0 {
+		start += total
+	}
+	if end < 0 {
+		end += total
+	}
+	start = max(start, 0)
+	end = max(end, 0)
+	end = min(end, total-1)
+	if r.BitUnit && start <= end {
+		firstMask = ^byte(0xff >> (start & 7))
+		lastMask = byte(1<<(7-(end&7))) - 1
+		start >>= 3
+		end >>= 3
+	}
+	return start, end, firstMask, lastMask
+}
+
+// bitReadBytes 读取 [start, start+n) 的字节，超出字符串长度的部分为 0
+func (s *BadgerStore) bitReadBytes(txn *badger.Txn, key []byte, entry *stringEntry, start, n int64) ([]byte, error) {
+	buf := make([]byte, n)
+	if entry == nil || start >= entry.length {
+		return buf, nil
+	}
+	data, err := s.stringLoadRange(txn, key, entry, start, min(start+n, entry.length))
+	if err != nil {
+		return nil, err
+	}
+	copy(buf, data)
+	return buf, nil
+}
+
+// SetBit 实现 Redis SETBIT 命令，返回该位原来的值
+func (s *BadgerStore) SetBit(key []byte, offset int64, on bool) (int, error) {
+	if offset < 0 || offset > MaxBitOffset {
+		return 0, ErrBitOffset
+	}
+	var old int
+	err := s.update(func(txn *badger.Txn) error {
+		old = 0
+		entry, err := s.stringLookup(txn, key)
+		if err != nil {
+			return err
+		}
+		b, err := s.bitReadBytes(txn, key, entry, offset>>3, 1)
+		if err != nil {
+			return err
+		}
+		mask := byte(0x80) >> (offset & 7)
+		if b[0]&mask != 0 {
+			old = 1
+		}
+		if on {
+			b[0] |= mask
+		} else {
+			b[0] &^= mask
+		}
+		_, err = s.stringWriteRange(txn, key, entry, offset>>3, b)
+		return err
+	})
+	return old, err
+}
+
+// GetBit 实现 Redis GETBIT 命令，key 不存在或偏移量超出长度时返回 0
+func (s *BadgerStore) GetBit(key []byte, offset int64) (int, error) {
+	if offset < 0 || offset > MaxBitOffset {
+		return 0, ErrBitOffset
+	}
+	var bit int
+	err := s.db.View(func(txn *badger.Txn) error {
+		entry, err := s.stringLookup(txn, key)
+		if err != nil || entry == nil {
+			return err
+		}
+		b, err := s.bitReadBytes(txn, key, entry, offset>>3, 1)
+		if err != nil {
+			return err
+		}
+		if b[0]&(0x80>>(offset&7)) != 0 {
+			bit = 1
+		}
+		return nil
+	})
+	return bit, err
+}
+
+// BitCount 实现 Redis BITCOUNT 命令，r 为 nil 时统计整个字符串
+func (s *BadgerStore) BitCount(key []byte, r *BitRange) (int64, error) {
+	var count int64
+	err := s.db.View(func(txn *badger.Txn) error {
+		entry, err := s.stringLookup(txn, key)
+		if err != nil || entry == nil {
+			return err
+		}
+		rng := BitRange{Start: 0, End: -1}
+		if r != nil {
+			// 与 Redis 一致：两端都为负数且 start > end 时直接返回 0
+			if r.Start < 0 && r.End < 0 && r.Start > r.End {
+				return nil
+			}
+			rng = *r
+		}
+		start, end, firstMask, lastMask := bitByteRange(rng, entry.length)
+		if start > end {
+			return nil
+		}
+		err = s.stringScanRange(txn, key, entry, start, end+1, func(offset, length int64, data []byte) error {
+			for i, b := range data {
+				pos := offset + int64(i)
+				if pos == start {
+					b &^= firstMask
+				}
+				if pos == end {
+					b &^= lastMask
+				}
+				count += int64(bits.OnesCount8(b))
+			}
+			return nil
+		})
+		return err
+	})
+	return count, err
+}
+
+// BitPos 实现 Redis BITPOS 命令，返回第一个值为 bit 的位置，找不到时返回 -1。
+// 查找 0 且没有指定 endGiven 时，区间之后的位视为 0，返回区间末尾的下一位
+func (s *BadgerStore) BitPos(key []byte, bit int, r BitRange, endGiven bool) (int64, error) {
+	pos := int64(-1)
+	err := s.db.View(func(txn *badger.Txn) error {
+		entry, err := s.stringLookup(txn, key)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			if bit == 0 {
+				pos = 0
+			}
+			return nil
+		}
+		start, end, firstMask, lastMask := bitByteRange(r, entry.length)
+		if start > end {
+			return nil
+		}
+
+		// 查找 1 时把区间外的位清零，查找 0 时把区间外的位置 1
+		found := errors.New("found")
+		err = s.stringScanRange(txn, key, entry, start, end+1, func(offset, length int64, data []byte) error {
+			if data == nil && bit == 1 {
+				return nil
+			}
+			for i := int64(0); i < length; i++ {
+				var b byte
+				if data != nil {
+					b = data[i]
+				}
+				p := offset + i
+				if p == start {
+					b = maskBitByte(b, firstMask, bit)
+				}
+				if p == end {
+					b = maskBitByte(b, lastMask, bit)
+				}
+				if bit == 0 {
+					b = ^b
+				}
+				if b != 0 {
+					pos = p*8 + int64(bits.LeadingZeros8(b))
+					return found
+				}
+			}
+			return nil
+		})
+		if err != nil && err != found {
+			return err
+		}
+		if pos == -1 && bit == 0 && !endGiven {
+			pos = (end + 1) * 8
+		}
+		return nil
+	})
+	return pos, err
+}
+
+// bitReadFragment 读取第 index 个分片位置上的 n 个字节，超出字符串长度的部分为 0。
+// 分片存储时直接点查分片，不必为每个分片创建一个迭代器
+func (s *BadgerStore) bitReadFragment(txn *badger.Txn, key []byte, entry *stringEntry, index, n int64) ([]byte, error) {
+	if entry == nil || !entry.fragmented {
+		return s.bitReadBytes(txn, key, entry, index*stringFragmentSize, n)
+	}
+	data, err := s.stringReadFragment(txn, key, index)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	copy(buf, data)
+	return buf, nil
+}
+
+func maskBitByte(b, mask byte, bit int) byte {
+	if bit == 1 {
+		return b &^ mask
+	}
+	return b | mask
+}
+
+// BitOp 实现 Redis BITOP 命令，把运算结果写入 destKey 并返回结果的长度。
+// 按分片逐段计算，全为 0 的分片不写入；结果为空时删除 destKey。
+// 结果在一个事务中写不下时改由 bitOpLarge 分批写入
+func (s *BadgerStore) BitOp(op string, destKey []byte, srcKeys [][]byte) (int64, error) {
+	if op == BitOpNot && len(srcKeys) != 1 {
+		return 0, ErrBitOpNot
+	}
+	var length int64
+	err := s.update(func(txn *badger.Txn) error {
+		// 先算出全部结果再写入，destKey 可能同时是源 key
+		var fragments [][]byte
+		var err error
+		length, err = s.bitOpFragments(txn, op, srcKeys, func(_ int64, result []byte) error {
+			fragments = append(fragments, result)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.deleteKey(txn, destKey); err != nil {
+			return err
+		}
+		// 已过期但尚未回收的旧分片也要清理，否则会被当作新结果中为 0 的分片读出
+		if err := s.stringDeleteFragments(txn, destKey); err != nil {
+			return err
+		}
+		if length == 0 {
+			return nil
+		}
+		if length <= stringFragmentSize {
+			return s.stringWrite(txn, destKey, fragments[0], 0)
+		}
+		for index, fragment := range fragments {
+			if isZeroBytes(fragment) {
+				continue
+			}
+			if err := s.stringPutFragment(txn, destKey, int64(index), fragment, 0); err != nil {
+				return err
+			}
+		}
+		return s.stringPutMeta(txn, destKey, &stringEntry{fragmented: true, length: length})
+	})
+	if errors.Is(err, badger.ErrTxnTooBig) {
+		return s.bitOpLarge(op, destKey, srcKeys)
+	}
+	return length, err
+}
+
+// bitOpFragments 读取源 key，按分片逐段计算运算结果，依次把第 index 个分片的结果交给 fn，返回结果的长度
+func (s *BadgerStore) bitOpFragments(txn *badger.Txn, op string, srcKeys [][]byte, fn func(index int64, result []byte) error) (int64, error) {
+	var length int64
+	entries := make([]*stringEntry, len(srcKeys))
+	for i, key := range srcKeys {
+		entry, err := s.stringLookup(txn, key)
+		if err != nil {
+			return 0, err
+		}
+		entries[i] = entry
+		if entry != nil {
+			length = max(length, entry.length)
+		}
+	}
+
+	for from := int64(0); from < length; from += stringFragmentSize {
+		n := min(stringFragmentSize, length-from)
+		var result []byte
+		for i, key := range srcKeys {
+			data, err := s.bitReadFragment(txn, key, entries[i], from/stringFragmentSize, n)
+			if err != nil {
+				return 0, err
+			}
+			if i == 0 {
+				result = data
+				continue
+			}
+			for j := range result {
+				switch op {
+				case BitOpAnd:
+					result[j] &= data[j]
+				case BitOpOr:
+					result[j] |= data[j]
+				case BitOpXor:
+					result[j] ^= data[j]
+				}
+			}
+		}
+		if op == BitOpNot {
+			for j := range result {
+				result[j] = ^result[j]
+			}
+		}
+		if err := fn(from/stringFragmentSize, result); err != nil {
+			return 0, err
+		}
+	}
+	return length, nil
+}
+
+// bitOpLarge 处理结果超出一个事务容量的 BITOP。源 key 在同一个只读快照中读取，destKey 同时是源 key 时读到的也是运算前的值；
+// 结果的分片用 WriteBatch 分批写入，最后在一个事务中写入 destKey 的元信息，此前读取 destKey 得到的仍是原来的值。
+// destKey 原来就是分片存储的字符串时，它的分片会被逐批改写，所以先删除它的元信息，写入期间 destKey 视为不存在。
+// 分批写入与同时修改 destKey 的命令之间没有隔离
+func (s *BadgerStore) bitOpLarge(op string, destKey []byte, srcKeys [][]byte) (int64, error) {
+	snap := s.db.NewTransaction(false)
+	defer snap.Discard()
+
+	// destKey 原有的分片（包括已过期但尚未回收的）不被新结果覆盖时要删除
+	oldFragments := make(map[int64]bool)
+	raw, err := s.stringReadRaw(snap, destKey)
+	if err != nil {
+		return 0, err
+	}
+	if raw != nil && raw.fragmented {
+		err = s.stringIterFragments(snap, destKey, 0, stringLastFragment(raw.length), false, func(index int64, _ []byte) error {
+			oldFragments[index] = true
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		keyType, err := s.keyType(snap, destKey)
+		if err != nil {
+			return 0, err
+		}
+		if keyType == KeyTypeString && !raw.expired() {
+			err = s.update(func(txn *badger.Txn) error {
+				if err := txn.Delete(TypeKeyGet(string(destKey))); err != nil {
+					return err
+				}
+				return txn.Delete(s.stringKey(destKey))
+			})
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	length, err := s.bitOpFragments(snap, op, srcKeys, func(index int64, result []byte) error {
+		old := oldFragments[index]
+		delete(oldFragments, index)
+		if !isZeroBytes(result) {
+			return wb.Set(s.stringFragmentKey(destKey, index), result)
+		}
+		if old {
+			return wb.Delete(s.stringFragmentKey(destKey, index))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for index := range oldFragments {
+		if err := wb.Delete(s.stringFragmentKey(destKey, index)); err != nil {
+			return 0, err
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		return 0, err
+	}
+
+	err = s.update(func(txn *badger.Txn) error {
+		keyType, err := s.keyType(txn, destKey)
+		if err != nil {
+			return err
+		}
+		// 字符串的分片已经改写完，只需替换元信息；其他类型的旧数据在这里删除
+		if keyType != "" && keyType != KeyTypeString {
+			if _, err := s.deleteKey(txn, destKey); err != nil {
+				return err
+			}
+		}
+		if length == 0 {
+			if keyType == KeyTypeString {
+				return s.stringDelete(txn, destKey)
+			}
+			return nil
+		}
+		return s.stringPutMeta(txn, destKey, &stringEntry{fragmented: true, length: length})
+	})
+	return length, err
+}
+
+func isZeroBytes(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// BITFIELD 子命令
+const (
+	BitfieldGet = iota
+	BitfieldSet
+	BitfieldIncrBy
+)
+
+// BITFIELD 溢出处理方式
+const (
+	BitfieldWrap = iota
+	BitfieldSat
+	BitfieldFail
+)
+
+// BitfieldOp 是 BITFIELD 的一个子命令
+type BitfieldOp struct {
+	Op       int
+	Signed   bool
+	Bits     int   // 有符号 1-64，无符号 1-63
+	Offset   int64 // bit 偏移量
+	Value    int64 // SET 的值或 INCRBY 的增量
+	Overflow int
+}
+
+// BitField 实现 Redis BITFIELD 命令，全部子命令在一个事务内按顺序执行。
+// 返回每个子命令的结果，溢出方式为 FAIL 且发生溢出时对应结果为 nil
+func (s *BadgerStore) BitField(key []byte, ops []BitfieldOp) ([]*int64, error) {
+	var results []*int64
+	// 只有 GET 时在只读事务中执行
+	var highest int64 = -1
+	for _, op := range ops {
+		if op.Op != BitfieldGet {
+			highest = max(highest, op.Offset+int64(op.Bits)-1)
+		}
+	}
+	if highest > MaxBitOffset {
+		return nil, ErrBitOffset
+	}
+
+	run := func(txn *badger.Txn) error {
+		results = make([]*int64, 0, len(ops))
+		entry, err := s.stringLookup(txn, key)
+		if err != nil {
+			return err
+		}
+		// 与 Redis 一致，有写操作时先把字符串扩展到最高写入位置，即使写入因 FAIL 被跳过
+		if highest >= 0 && (entry == nil || entry.length <= highest>>3) {
+			if _, err := s.stringWriteRange(txn, key, entry, highest>>3, []byte{0}); err != nil {
+				return err
+			}
+			if entry, err = s.stringRead(txn, key); err != nil {
+				return err
+			}
+		}
+
+		for _, op := range ops {
+			first := op.Offset >> 3
+			n := (op.Offset+int64(op.Bits)-1)>>3 - first + 1
+			buf, err := s.bitReadBytes(txn, key, entry, first, n)
+			if err != nil {
+				return err
+			}
+			inner := op.Offset & 7
+			old := getBitfield(buf, inner, op.Bits)
+			if op.Signed {
+				old = signExtend(old, op.Bits)
+			}
+			if op.Op == BitfieldGet {
+				value := int64(old)
+				results = append(results, &value)
+				continue
+			}
+
+			var newVal uint64
+			var overflow bool
+			var reply int64
+			if op.Signed {
+				if op.Op == BitfieldIncrBy {
+					var wrapped int64
+					overflow, wrapped = signedBitfieldOverflow(int64(old), op.Value, op.Bits, op.Overflow)
+					sum := int64(old) + op.Value
+					if overflow {
+						sum = wrapped
+					}
+					newVal, reply = uint64(sum), sum
+				} else {
+					var wrapped int64
+					newVal = uint64(op.Value)
+					if overflow, wrapped = signedBitfieldOverflow(op.Value, 0, op.Bits, op.Overflow); overflow {
+						newVal = uint64(wrapped)
+					}
+					reply = int64(old)
+				}
+			} else {
+				if op.Op == BitfieldIncrBy {
+					var wrapped uint64
+					overflow, wrapped = unsignedBitfieldOverflow(old, op.Value, op.Bits, op.Overflow)
+					newVal = old + uint64(op.Value)
+					if overflow {
+						newVal = wrapped
+					}
+					reply = int64(newVal)
+				} else {
+					var wrapped uint64
+					newVal = uint64(op.Value)
+					if overflow, wrapped = unsignedBitfieldOverflow(newVal, 0, op.Bits, op.Overflow); overflow {
+						newVal = wrapped
+					}
+					reply = int64(old)
+				}
+			}
+			if overflow && op.Overflow == BitfieldFail {
+				results = append(results, nil)
+				continue
+			}
+			results = append(results, &reply)
+			setBitfield(buf, inner, op.Bits, newVal)
+			if _, err := s.stringWriteRange(txn, key, entry, first, buf); err != nil {
+				return err
+			}
+			if entry, err = s.stringRead(txn, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var err error
+	if highest >= 0 {
+		err = s.update(run)
+	} else {
+		err = s.db.View(run)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// getBitfield 从 buf 的第 offset 位开始读取 n 位无符号整数，高位在前
+func getBitfield(buf []byte, offset int64, n int) uint64 {
+	var value uint64
+	for i := 0; i < n; i++ {
+		pos := offset + int64(i)
+		value <<= 1
+		if buf[pos>>3]&(0x80>>(pos&7)) != 0 {
+			value |= 1
+		}
+	}
+	return value
+}
+
+// setBitfield 把 value 的低 n 位写入 buf 的第 offset 位开始处
+func setBitfield(buf []byte, offset int64, n int, value uint64) {
+	for i := 0; i < n; i++ {
+		pos := offset + int64(i)
+		mask := byte(0x80) >> (pos & 7)
+		if value&(1<<(n-1-i)) != 0 {
+			buf[pos>>3] |= mask
+		} else {
+			buf[pos>>3] &^= mask
+		}
+	}
+}
+
+func signExtend(value uint64, n int) uint64 {
+	if n < 64 && value&(1<<(n-1)) != 0 {
+		value |= math.MaxUint64 << n
+	}
+	return value
+}
+
+// unsignedBitfieldOverflow 检查 value+incr 是否超出 n 位无符号整数的范围，
+// 溢出时按 WRAP 或 SAT 返回替代的结果，与 Redis 的 checkUnsignedBitfieldOverflow 一致
+func unsignedBitfieldOverflow(value uint64, incr int64, n int, overflow int) (bool, uint64) {
+	maxVal := uint64(1)<<n - 1
+	maxIncr := int64(maxVal - value)
+	minIncr := -int64(value)
+	wrap := func() uint64 {
+		return (value + uint64(incr)) &^ (math.MaxUint64 << n)
+	}
+	if value > maxVal || (incr > 0 && incr > maxIncr) {
+		if overflow == BitfieldWrap {
+			return true, wrap()
+		}
+		return true, maxVal
+	}
+	if incr < 0 && incr < minIncr {
+		if overflow == BitfieldWrap {
+			return true, wrap()
+		}
+		return true, 0
+	}
+	return false, 0
+}
+
+// signedBitfieldOverflow 检查 value+incr 是否超出 n 位有符号整数的范围，
+// 溢出时按 WRAP 或 SAT 返回替代的结果，与 Redis 的 checkSignedBitfieldOverflow 一致
+func signedBitfieldOverflow(value, incr int64, n int, overflow int) (bool, int64) {
+	maxVal := int64(math.MaxInt64)
+	if n < 64 {
+		maxVal = int64(1)<<(n-1) - 1
+	}
+	minVal := -maxVal - 1
+	maxIncr := maxVal - value
+	minIncr := minVal - value
+	wrap := func() int64 {
+		c := uint64(value) + uint64(incr)
+		if n < 64 {
+			mask := uint64(math.MaxUint64) << n
+			if c&(1<<(n-1)) != 0 {
+				c |= mask
+			} else {
+				c &^= mask
+			}
+		}
+		return int64(c)
+	}
+	if value > maxVal || (n != 64 && incr > maxIncr) || (value >= 0 && incr > 0 && incr > maxIncr) {
+		if overflow == BitfieldWrap {
+			return true, wrap()
+		}
+		return true, maxVal
+	}
+	if value < minVal || (n != 64 && incr < minIncr) || (value < 0 && incr < 0 && incr < minIncr) {
+		if overflow == BitfieldWrap {
+			return true, wrap()
+		}
+		return true, minVal
+	}
+	return false, 0
+}
@@ -0,0 +1,237 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/zeebo/assert"
+)
+
+func TestSetBitGetBit(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("bits")
+
+	old, err := store.SetBit(key, 7, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, old)
+	old, _ = store.SetBit(key, 7, true)
+	assert.Equal(t, 1, old)
+	val, _ := store.Get(key)
+	assert.Equal(t, "\x01", string(val))
+	bit, _ := store.GetBit(key, 7)
+	assert.Equal(t, 1, bit)
+	bit, _ = store.GetBit(key, 100)
+	assert.Equal(t, 0, bit)
+
+	// 高位的 SETBIT 转换为分片存储，只写入涉及的分片
+	offset := int64(100_000_000)
+	_, _ = store.SetBit(key, offset, true)
+	n, _ := store.StrLen(key)
+	assert.Equal(t, offset/8+1, n)
+	assert.NoError(t, store.db.View(func(txn *badger.Txn) error {
+		found := 0
+		err := store.stringIterFragments(txn, key, 0, offset, false, func(int64, []byte) error {
+			found++
+			return nil
+		})
+		assert.Equal(t, 2, found)
+		return err
+	}))
+	bit, _ = store.GetBit(key, offset)
+	assert.Equal(t, 1, bit)
+	count, _ := store.BitCount(key, nil)
+	assert.Equal(t, int64(2), count)
+	pos, _ := store.BitPos(key, 1, BitRange{Start: 1, End: -1}, false)
+	assert.Equal(t, offset, pos)
+
+	_, err = store.SetBit(key, MaxBitOffset+1, true)
+	assert.Equal(t, ErrBitOffset, err)
+	assert.NoError(t, store.db.Update(func(txn *badger.Txn) error {
+		return store.setKeyType(txn, []byte("list"), KeyTypeList)
+	}))
+	_, err = store.SetBit([]byte("list"), 1, true)
+	assert.Equal(t, ErrWrongType, err)
+}
+
+func TestBitCountBitPos(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("mykey")
+
+	_ = store.Set(key, []byte("foobar"))
+	for _, tc := range []struct {
+		r    *BitRange
+		want int64
+	}{
+		{nil, 26}, {&BitRange{Start: 0, End: 0}, 4}, {&BitRange{Start: 1, End: 1}, 6},
+		{&BitRange{Start: 1, End: 1, BitUnit: true}, 1}, {&BitRange{Start: 5, End: 30, BitUnit: true}, 17},
+		{&BitRange{Start: -1, End: -2}, 0},
+	} {
+		count, err := store.BitCount(key, tc.r)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, count)
+	}
+
+	_ = store.Set(key, []byte("\xff\xf0\x00"))
+	pos, _ := store.BitPos(key, 0, BitRange{Start: 0, End: -1}, false)
+	assert.Equal(t, int64(12), pos)
+
+	_ = store.Set(key, []byte("\x00\xff\xf0"))
+	for _, tc := range []struct {
+		r        BitRange
+		endGiven bool
+		want     int64
+	}{
+		{BitRange{Start: 0, End: -1}, false, 8},
+		{BitRange{Start: 2, End: -1}, false, 16},
+		{BitRange{Start: 2, End: -1}, true, 16},
+		{BitRange{Start: 7, End: 15, BitUnit: true}, true, 8},
+	} {
+		pos, err := store.BitPos(key, 1, tc.r, tc.endGiven)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, pos)
+	}
+
+	_ = store.Set(key, []byte("\x00\x00\x00"))
+	pos, _ = store.BitPos(key, 1, BitRange{Start: 0, End: -1}, false)
+	assert.Equal(t, int64(-1), pos)
+	pos, _ = store.BitPos(key, 1, BitRange{Start: 7, End: -3, BitUnit: true}, true)
+	assert.Equal(t, int64(-1), pos)
+
+	// 全为 1 时查找 0：没有指定 end 返回末尾的下一位，指定了 end 返回 -1
+	_ = store.Set(key, []byte("\xff\xff"))
+	pos, _ = store.BitPos(key, 0, BitRange{Start: 0, End: -1}, false)
+	assert.Equal(t, int64(16), pos)
+	pos, _ = store.BitPos(key, 0, BitRange{Start: 0, End: -1}, true)
+	assert.Equal(t, int64(-1), pos)
+	pos, _ = store.BitPos([]byte("missing"), 0, BitRange{Start: 0, End: -1}, false)
+	assert.Equal(t, int64(0), pos)
+}
+
+func TestBitOp(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+
+	_ = store.Set([]byte("key1"), []byte("foobar"))
+	_ = store.Set([]byte("key2"), []byte("abcdef"))
+	n, err := store.BitOp(BitOpAnd, []byte("dest"), [][]byte{[]byte("key1"), []byte("key2")})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), n)
+	val, _ := store.Get([]byte("dest"))
+	assert.Equal(t, "`bc`ab", string(val))
+
+	// 长度不同时较短的值以 0 补齐
+	_ = store.Set([]byte("short"), []byte("\x0f"))
+	_, _ = store.BitOp(BitOpOr, []byte("dest"), [][]byte{[]byte("short"), []byte("key1"), []byte("missing")})
+	val, _ = store.Get([]byte("dest"))
+	assert.Equal(t, "ooobar", string(val))
+	_, _ = store.BitOp(BitOpNot, []byte("dest"), [][]byte{[]byte("short")})
+	val, _ = store.Get([]byte("dest"))
+	assert.Equal(t, "\xf0", string(val))
+
+	_, err = store.BitOp(BitOpNot, []byte("dest"), [][]byte{[]byte("key1"), []byte("key2")})
+	assert.Equal(t, ErrBitOpNot, err)
+
+	// 结果为空时删除目标 key
+	n, _ = store.BitOp(BitOpXor, []byte("dest"), [][]byte{[]byte("missing")})
+	assert.Equal(t, int64(0), n)
+	val, _ = store.Get([]byte("dest"))
+	assert.Nil(t, val)
+
+	// 大位图按分片计算，目标 key 同时是源 key
+	_, _ = store.SetBit([]byte("big"), 80_000, true)
+	_, _ = store.SetBit([]byte("big2"), 3, true)
+	n, _ = store.BitOp(BitOpOr, []byte("big"), [][]byte{[]byte("big"), []byte("big2")})
+	assert.Equal(t, int64(10_001), n)
+	count, _ := store.BitCount([]byte("big"), nil)
+	assert.Equal(t, int64(2), count)
+	bit, _ := store.GetBit([]byte("big"), 80_000)
+	assert.Equal(t, 1, bit)
+}
+
+// 结果超出一个事务的容量时分批写入
+func TestBitOpLarge(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	const bits = 100_000_000
+	_, _ = store.SetBit([]byte("a"), bits-1, true)
+	_, _ = store.RPush([]byte("dst"), []byte("x"))
+
+	// 12.5MB 的结果写不进一个事务，目标 key 原来是列表
+	n, err := store.BitOp(BitOpNot, []byte("dst"), [][]byte{[]byte("a")})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(bits/8), n)
+	count, _ := store.BitCount([]byte("dst"), nil)
+	assert.Equal(t, int64(bits-1), count)
+	bit, _ := store.GetBit([]byte("dst"), bits-1)
+	assert.Equal(t, 0, bit)
+	assert.NoError(t, store.db.View(func(txn *badger.Txn) error {
+		keyType, err := store.keyType(txn, []byte("dst"))
+		assert.Equal(t, KeyTypeString, keyType)
+		return err
+	}))
+
+	// 目标 key 同时是源 key，原来就是分片存储的字符串
+	n, err = store.BitOp(BitOpOr, []byte("dst"), [][]byte{[]byte("dst"), []byte("a")})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(bits/8), n)
+	count, _ = store.BitCount([]byte("dst"), nil)
+	assert.Equal(t, int64(bits), count)
+	_, err = store.BitOp(BitOpNot, []byte("a"), [][]byte{[]byte("a")})
+	assert.NoError(t, err)
+	count, _ = store.BitCount([]byte("a"), nil)
+	assert.Equal(t, int64(bits-1), count)
+	bit, _ = store.GetBit([]byte("a"), 0)
+	assert.Equal(t, 1, bit)
+}
+
+func TestBitField(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("mykey")
+
+	results, err := store.BitField(key, []BitfieldOp{
+		{Op: BitfieldIncrBy, Signed: true, Bits: 5, Offset: 100, Value: 1},
+		{Op: BitfieldGet, Bits: 4, Offset: 0},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), *results[0])
+	assert.Equal(t, int64(0), *results[1])
+
+	// u2 自增：WRAP 回绕，SAT 饱和，FAIL 返回 nil 且不修改
+	wantWrap := []int64{1, 2, 3, 0}
+	wantSat := []int64{1, 2, 3, 3}
+	for i := 0; i < 4; i++ {
+		results, _ = store.BitField(key, []BitfieldOp{
+			{Op: BitfieldIncrBy, Bits: 2, Offset: 200, Value: 1, Overflow: BitfieldWrap},
+			{Op: BitfieldIncrBy, Bits: 2, Offset: 202, Value: 1, Overflow: BitfieldSat},
+		})
+		assert.Equal(t, wantWrap[i], *results[0])
+		assert.Equal(t, wantSat[i], *results[1])
+	}
+	results, _ = store.BitField(key, []BitfieldOp{{Op: BitfieldIncrBy, Bits: 2, Offset: 202, Value: 1, Overflow: BitfieldFail}})
+	assert.Nil(t, results[0])
+
+	// 有符号 SET 返回旧值，溢出时按方式处理
+	results, _ = store.BitField(key, []BitfieldOp{
+		{Op: BitfieldSet, Signed: true, Bits: 8, Offset: 0, Value: 127},
+		{Op: BitfieldIncrBy, Signed: true, Bits: 8, Offset: 0, Value: 1},
+		{Op: BitfieldSet, Signed: true, Bits: 8, Offset: 0, Value: 200, Overflow: BitfieldSat},
+		{Op: BitfieldGet, Signed: true, Bits: 8, Offset: 0},
+		{Op: BitfieldIncrBy, Signed: true, Bits: 64, Offset: 8, Value: -1},
+		{Op: BitfieldGet, Bits: 63, Offset: 8},
+	})
+	assert.Equal(t, int64(0), *results[0])
+	assert.Equal(t, int64(-128), *results[1])
+	assert.Equal(t, int64(-128), *results[2])
+	assert.Equal(t, int64(127), *results[3])
+	assert.Equal(t, int64(-1), *results[4])
+	assert.Equal(t, int64(1<<63-1), *results[5])
+
+	// 只有 GET 时不会创建 key
+	results, _ = store.BitField([]byte("missing"), []BitfieldOp{{Op: BitfieldGet, Bits: 8, Offset: 0}})
+	assert.Equal(t, int64(0), *results[0])
+	val, _ := store.Get([]byte("missing"))
+	assert.Nil(t, val)
+}
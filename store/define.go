@@ -1,6 +1,10 @@
 package store
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
 	"github.com/dgraph-io/badger/v4"
 )
 
@@ -15,10 +19,39 @@ const (
 var (
 	keyTypeBytes    = []byte("TYPE:")
 	prefixKeyString = []byte("STRING:")
-	prefixKeyList   = []byte("LIST:")
-	prefixKeyHash   = []byte("HASH:")
-	prefixKeySet    = []byte("SET:")
-	prefixKeyZSet   = []byte("ZSET:")
+	// prefixKeyStringFragment 是分片存储的大字符串的分片前缀
+	prefixKeyStringFragment = []byte("STRFRAG:")
+	prefixKeyList           = []byte("LIST:")
+	prefixKeyHash           = []byte("HASH:")
+	prefixKeySet            = []byte("SET:")
+	prefixKeyZSet           = []byte("ZSET:")
+)
+
+var (
+	// ErrWrongType 表示 key 已存在且类型与命令要求的不一致
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	// ErrNotInteger 表示值无法解析为 64 位整数
+	ErrNotInteger = errors.New("ERR value is not an integer or out of range")
+	// ErrNotFloat 表示值无法解析为浮点数
+	ErrNotFloat = errors.New("ERR value is not a valid float")
+	// ErrOverflow 表示整数自增或自减的结果溢出
+	ErrOverflow = errors.New("ERR increment or decrement would overflow")
+	// ErrNaNOrInfinity 表示浮点自增的结果为 NaN 或无穷大
+	ErrNaNOrInfinity = errors.New("ERR increment would produce NaN or Infinity")
+	// ErrHashNotInteger 表示哈希字段的值无法解析为 64 位整数
+	ErrHashNotInteger = errors.New("ERR hash value is not an integer")
+	// ErrHashNotFloat 表示哈希字段的值无法解析为浮点数
+	ErrHashNotFloat = errors.New("ERR hash value is not a float")
+	// ErrIncrNaNOrInfinity 表示 HINCRBYFLOAT 的增量本身是 NaN 或无穷大
+	ErrIncrNaNOrInfinity = errors.New("ERR value is NaN or Infinity")
+	// ErrStringTooLong 表示写入后字符串会超过 512MB 的上限
+	ErrStringTooLong = errors.New("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+	// ErrOffsetOutOfRange 表示 SETRANGE 的偏移量为负数
+	ErrOffsetOutOfRange = errors.New("ERR offset is out of range")
+	// ErrRandomCountRange 表示 HRANDFIELD、SRANDMEMBER 的负数 count 超出了 maxRandomCount
+	ErrRandomCountRange = fmt.Errorf("ERR value is out of range, count must be greater than or equal to -%d", maxRandomCount)
+	// ErrScoreNaN 表示有序集合的分数自增后为 NaN，例如 inf 加上 -inf
+	ErrScoreNaN = errors.New("ERR resulting score is not a number (NaN)")
 )
 
 type BadgerStore struct {
@@ -39,12 +72,23 @@ func (s *BadgerStore) Close() {
 }
 
 func TypeKeyGet(strKey string) []byte {
-	bKey := []byte(strKey)
-	bKey = append(keyTypeBytes, bKey...)
-	return bKey
+	return keyBadgetGet(keyTypeBytes, []byte(strKey))
 }
 
+// keyBadgetGet 拼接前缀和 key，总是分配新的切片，避免 append 改写共享的前缀变量
 func keyBadgetGet(bType, bKey []byte) []byte {
-	bKey = append(bType, bKey...)
-	return bKey
+	buf := make([]byte, 0, len(bType)+len(bKey))
+	buf = append(buf, bType...)
+	return append(buf, bKey...)
+}
+
+// keyPrefix 生成 key 的数据记录的键前缀：类型前缀、4 字节大端序的 key 长度、key，再接上 part。
+// 长度写在 key 前面，一个 key 的记录前缀不会是另一个 key 的记录前缀（如 "a" 与 "a:field"），
+// 按前缀遍历或删除时不会碰到别的 key。总是分配新的切片
+func keyPrefix(bType, bKey []byte, part string) []byte {
+	buf := make([]byte, 0, len(bType)+4+len(bKey)+len(part))
+	buf = append(buf, bType...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(bKey)))
+	buf = append(buf, bKey...)
+	return append(buf, part...)
 }
@@ -2,183 +2,783 @@ package store
 
 import (
 	"PumbaaDB/helper"
+	"encoding/binary"
 	"errors"
 	"fmt"
-	"strings"
+	"math/big"
+	"strconv"
 
 	"github.com/dgraph-io/badger/v4"
 )
 
-// 哈希操作
-//func (s *BadgerStore) HSet(key, field string, value interface{}) error {
-//	logFuncTag := "BadgerStoreHSet"
-//	baggerTypeKey := TypeKeyGet(key)
-//	bValue, err := helper.InterfaceToBytes(value)
-//	if err != nil {
-//		return fmt.Errorf("%s,%v", logFuncTag, err)
-//	}
-//	hkey := s.hashKey(key, field)
-//	return s.db.Update(func(txn *badger.Txn) error {
-//		err := txn.Set(hkey, bValue)
-//		if err != nil {
-//			return err
-//		}
-//		return txn.Set(baggerTypeKey, []byte(KeyTypeHash))
-//	})
-//}
-
-// 修改 HSet 维护计数器
-func (s *BadgerStore) HSet(key, field string, value interface{}) error {
-	logFuncTag := "BadgerStoreHSet"
-	bValue, err := helper.InterfaceToBytes(value)
+// 下面的 HASH:<key> 表示 keyPrefix 生成的带长度的前缀，名字互为前缀的哈希（如 a 与 a:field）的记录不会混在一起。
+// 哈希的字段保存在 HASH:<key>:field:<8 字节哈希><field> 下（见 scanOrderKey），值为客户端写入的原始字节；
+// 字段数量保存在 HASH:<key>:count 下，与字段处于不同的前缀，遍历字段时不会读到计数器，
+// 也不会与名为 count 的字段冲突。最后一个字段被删除时连同类型标记一起删除。
+//
+// 设置了过期时间的字段在值前面加上 8 字节的毫秒时间戳并打上 hashMetaExpire 标志，
+// 同时在 HASH:<key>:expire:<大端序时间戳><field> 下写入一条索引。过期的字段不交给 badger 回收，
+// 读命令根据时间戳跳过过期字段，并按索引扣除字段数量；写命令在修改前先按索引删除已过期的字段，
+// 保证 HASH:<key>:count 与实际的字段数量一致
+
+// hashMetaExpire 表示字段值的前 8 字节是大端序的毫秒级过期时间戳
+const hashMetaExpire byte = 1
+
+// hashField 是从 badger 中解码出的字段
+type hashField struct {
+	value    []byte
+	expireAt int64 // 毫秒时间戳，0 表示不过期
+}
+
+func (f *hashField) expired(now int64) bool {
+	return f.expireAt != 0 && f.expireAt <= now
+}
+
+// hashKey 返回字段的键，字段前面带有 scanHash 的哈希值，按哈希值排序以支持无状态的 HSCAN 游标
+func (s *BadgerStore) hashKey(key, field []byte) []byte {
+	return scanOrderKey(s.hashFieldPrefix(key), field)
+}
+
+// hashFieldPrefix 返回哈希所有字段共同的键前缀
+func (s *BadgerStore) hashFieldPrefix(key []byte) []byte {
+	return keyPrefix(prefixKeyHash, key, ":field:")
+}
+
+// hashExpirePrefix 返回字段过期索引的键前缀，索引按过期时间排序
+func (s *BadgerStore) hashExpirePrefix(key []byte) []byte {
+	return keyPrefix(prefixKeyHash, key, ":expire:")
+}
+
+func (s *BadgerStore) hashExpireKey(key []byte, expireAt int64, field []byte) []byte {
+	k := binary.BigEndian.AppendUint64(s.hashExpirePrefix(key), uint64(expireAt))
+	return append(k, field...)
+}
+
+// hashCountKey 方法用于生成哈希表计数器键
+func (s *BadgerStore) hashCountKey(key []byte) []byte {
+	return keyPrefix(prefixKeyHash, key, ":count")
+}
+
+// hashGetCount 读取哈希的字段数量，不存在时返回 0。只读事务中还要用 hashLiveCount 扣除已过期的字段
+func (s *BadgerStore) hashGetCount(txn *badger.Txn, key []byte) (uint64, error) {
+	item, err := txn.Get(s.hashCountKey(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	val, err := item.ValueCopy(nil)
 	if err != nil {
-		return fmt.Errorf("%s,%v", logFuncTag, err)
+		return 0, fmt.Errorf("hashGetCount: failed to get count value: %v", err)
+	}
+	return helper.BytesToUint64(val), nil
+}
+
+// hashSetCount 写入哈希的字段数量，数量为 0 时删除计数器和类型标记
+func (s *BadgerStore) hashSetCount(txn *badger.Txn, key []byte, count uint64) error {
+	if count == 0 {
+		if err := txn.Delete(s.hashCountKey(key)); err != nil {
+			return err
+		}
+		return txn.Delete(TypeKeyGet(string(key)))
 	}
-	hkey := s.hashKey(key, field)
-	return s.db.Update(func(txn *badger.Txn) error {
-		// 检查字段是否存在
-		exists := false
-		if _, err := txn.Get(hkey); err == nil {
-			exists = true
+	if err := s.setKeyType(txn, key, KeyTypeHash); err != nil {
+		return err
+	}
+	return txn.Set(s.hashCountKey(key), helper.Uint64ToBytes(count))
+}
+
+// hashIterExpired 按过期时间的顺序遍历截至 now 已经过期的字段
+func (s *BadgerStore) hashIterExpired(txn *badger.Txn, key []byte, now int64, fn func(indexKey, field []byte) error) error {
+	prefix := s.hashExpirePrefix(key)
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+	for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+		k := iter.Item().KeyCopy(nil)
+		if len(k) < len(prefix)+8 {
+			continue
 		}
+		if int64(binary.BigEndian.Uint64(k[len(prefix):])) > now {
+			break
+		}
+		if err := fn(k, k[len(prefix)+8:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		// 写入字段值
-		if err := txn.Set(hkey, bValue); err != nil {
+// hashLiveCount 返回扣除已过期字段后的字段数量，用于只读事务
+func (s *BadgerStore) hashLiveCount(txn *badger.Txn, key []byte) (uint64, error) {
+	count, err := s.hashGetCount(txn, key)
+	if err != nil || count == 0 {
+		return count, err
+	}
+	var expired uint64
+	err = s.hashIterExpired(txn, key, nowMilli(), func(_, _ []byte) error {
+		expired++
+		return nil
+	})
+	if err != nil || expired >= count {
+		return 0, err
+	}
+	return count - expired, nil
+}
+
+// hashPrepareWrite 检查 key 的类型并删除已过期的字段，返回 key 是否存在和剩余的字段数量。
+// 写命令都先调用它，之后读到的字段都没有过期，字段数量也是准确的
+func (s *BadgerStore) hashPrepareWrite(txn *badger.Txn, key []byte) (bool, uint64, error) {
+	exists, err := s.checkKeyType(txn, key, KeyTypeHash)
+	if err != nil || !exists {
+		return exists, 0, err
+	}
+	count, err := s.hashGetCount(txn, key)
+	if err != nil {
+		return false, 0, err
+	}
+	var expired uint64
+	err = s.hashIterExpired(txn, key, nowMilli(), func(indexKey, field []byte) error {
+		if err := txn.Delete(s.hashKey(key, field)); err != nil {
 			return err
 		}
+		expired++
+		return txn.Delete(indexKey)
+	})
+	if err != nil || expired == 0 {
+		return true, count, err
+	}
+	count -= min(expired, count)
+	if err := s.hashSetCount(txn, key, count); err != nil {
+		return false, 0, err
+	}
+	return count > 0, count, nil
+}
 
-		// 更新计数器
-		countKey := s.hashCountKey(key)
-		var currentCount uint64
-		countItem, err := txn.Get(countKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			currentCount = 0
-		} else {
-			val, err := countItem.ValueCopy(nil)
+// hashDecodeItem 解码字段的值和过期时间，空字符串返回非 nil 的空切片
+func hashDecodeItem(item *badger.Item) (*hashField, error) {
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	f := &hashField{value: val}
+	if item.UserMeta()&hashMetaExpire != 0 {
+		if len(val) < 8 {
+			return nil, fmt.Errorf("hashDecodeItem: corrupted value of field %q", item.Key())
+		}
+		f.expireAt = int64(binary.BigEndian.Uint64(val))
+		f.value = val[8:]
+	}
+	// badger 对空值返回 nil，空字符串需要与不存在区分开
+	if f.value == nil {
+		f.value = []byte{}
+	}
+	return f, nil
+}
+
+// hashReadField 读取字段，字段不存在或已过期时返回 nil
+func (s *BadgerStore) hashReadField(txn *badger.Txn, key, field []byte) (*hashField, error) {
+	item, err := txn.Get(s.hashKey(key, field))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f, err := hashDecodeItem(item)
+	if err != nil || f.expired(nowMilli()) {
+		return nil, err
+	}
+	return f, nil
+}
+
+// hashGetField 读取字段的值，字段不存在时返回 nil
+func (s *BadgerStore) hashGetField(txn *badger.Txn, key, field []byte) ([]byte, error) {
+	f, err := s.hashReadField(txn, key, field)
+	if err != nil || f == nil {
+		return nil, err
+	}
+	return f.value, nil
+}
+
+// hashPutField 写入字段，expireAt 不为 0 时带上过期时间并写入过期索引，旧的过期索引由调用方删除
+func (s *BadgerStore) hashPutField(txn *badger.Txn, key, field, value []byte, expireAt int64) error {
+	if expireAt == 0 {
+		return txn.Set(s.hashKey(key, field), value)
+	}
+	buf := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(buf, uint64(expireAt))
+	e := badger.NewEntry(s.hashKey(key, field), append(buf, value...)).WithMeta(hashMetaExpire)
+	if err := txn.SetEntry(e); err != nil {
+		return err
+	}
+	return txn.Set(s.hashExpireKey(key, expireAt, field), nil)
+}
+
+// hashDeleteField 删除字段及其过期索引
+func (s *BadgerStore) hashDeleteField(txn *badger.Txn, key, field []byte, f *hashField) error {
+	if f.expireAt != 0 {
+		if err := txn.Delete(s.hashExpireKey(key, f.expireAt, field)); err != nil {
+			return err
+		}
+	}
+	return txn.Delete(s.hashKey(key, field))
+}
+
+// HSet 实现 Redis HSET 命令，pairs 依次为 field、value，返回新增的字段数量。
+// 与 Redis 一致，覆盖字段会清除字段的过期时间
+func (s *BadgerStore) HSet(key []byte, pairs [][]byte) (int64, error) {
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return 0, fmt.Errorf("HSet: odd number of field/value arguments")
+	}
+	var added int64
+	err := s.update(func(txn *badger.Txn) error {
+		added = 0
+		_, count, err := s.hashPrepareWrite(txn, key)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < len(pairs); i += 2 {
+			// 检查字段是否存在
+			old, err := s.hashReadField(txn, key, pairs[i])
 			if err != nil {
-				return fmt.Errorf("HSet: failed to get count value: %v", err)
+				return err
+			}
+			if old == nil {
+				added++
+				count++
+			} else if old.expireAt != 0 {
+				if err := txn.Delete(s.hashExpireKey(key, old.expireAt, pairs[i])); err != nil {
+					return err
+				}
+			}
+			if err := s.hashPutField(txn, key, pairs[i], pairs[i+1], 0); err != nil {
+				return err
 			}
-			currentCount = helper.BytesToUint64(val)
 		}
+		return s.hashSetCount(txn, key, count)
+	})
+	return added, err
+}
 
-		if !exists {
-			currentCount++
+// HSetNX 实现 Redis HSETNX 命令，只在字段不存在时写入，返回是否写入
+func (s *BadgerStore) HSetNX(key, field, value []byte) (bool, error) {
+	var written bool
+	err := s.update(func(txn *badger.Txn) error {
+		written = false
+		_, count, err := s.hashPrepareWrite(txn, key)
+		if err != nil {
+			return err
 		}
-
-		return txn.Set(countKey, helper.Uint64ToBytes(currentCount))
+		old, err := s.hashReadField(txn, key, field)
+		if err != nil || old != nil {
+			return err
+		}
+		if err := s.hashPutField(txn, key, field, value, 0); err != nil {
+			return err
+		}
+		written = true
+		return s.hashSetCount(txn, key, count+1)
 	})
+	return written, err
 }
-func (s *BadgerStore) HGet(key, field string) ([]byte, error) {
-	hkey := s.hashKey(key, field)
+
+// HGet 实现 Redis HGET 命令，key 或字段不存在时返回 nil
+func (s *BadgerStore) HGet(key, field []byte) ([]byte, error) {
 	var val []byte
 	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(hkey)
-		if err != nil {
+		if _, err := s.checkKeyType(txn, key, KeyTypeHash); err != nil {
 			return err
 		}
-		val, err = item.ValueCopy(nil)
+		var err error
+		val, err = s.hashGetField(txn, key, field)
 		return err
 	})
 	return val, err
 }
 
-func (s *BadgerStore) hashKey(key, field string) []byte {
-	return []byte(fmt.Sprintf("%s:%s:%s", KeyTypeHash, key, field))
-}
-
-// hashCountKey 方法用于生成哈希表计数器键
-func (s *BadgerStore) hashCountKey(key string) []byte {
-	return []byte(fmt.Sprintf("%s:%s:count", KeyTypeHash, key))
-}
-
-// HDel 实现 Redis HDEL 命令
-func (s *BadgerStore) HDel(key string, fields ...string) (int, error) {
-	deletedCount := 0
-	return 1, s.db.Update(func(txn *badger.Txn) error {
-		countKey := s.hashCountKey(key)
-		var currentCount uint64
-		countItem, err := txn.Get(countKey)
-		if err == nil {
-			val, err := countItem.ValueCopy(nil)
+// HMGet 实现 Redis HMGET 命令，不存在的字段对应位置返回 nil
+func (s *BadgerStore) HMGet(key []byte, fields [][]byte) ([][]byte, error) {
+	values := make([][]byte, len(fields))
+	err := s.db.View(func(txn *badger.Txn) error {
+		if _, err := s.checkKeyType(txn, key, KeyTypeHash); err != nil {
+			return err
+		}
+		for i, field := range fields {
+			val, err := s.hashGetField(txn, key, field)
 			if err != nil {
-				return fmt.Errorf("HDel: failed to get count value: %v", err)
+				return err
 			}
-			currentCount = helper.BytesToUint64(val)
+			values[i] = val
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// HDel 实现 Redis HDEL 命令，返回实际删除的字段数量，重复的字段只计一次
+func (s *BadgerStore) HDel(key []byte, fields ...[]byte) (int64, error) {
+	var deleted int64
+	err := s.update(func(txn *badger.Txn) error {
+		deleted = 0
+		exists, count, err := s.hashPrepareWrite(txn, key)
+		if err != nil || !exists {
+			return err
 		}
 
 		for _, field := range fields {
-			hkey := s.hashKey(key, field)
 			// 检查是否存在
-			_, err := txn.Get(hkey)
-			if err == nil {
-				// 存在则删除
-				if err := txn.Delete(hkey); err != nil {
-					return err
-				}
-				deletedCount++
-				currentCount--
+			old, err := s.hashReadField(txn, key, field)
+			if err != nil {
+				return err
+			}
+			if old == nil {
+				continue
+			}
+			// 存在则删除
+			if err := s.hashDeleteField(txn, key, field, old); err != nil {
+				return err
 			}
+			deleted++
+			count--
 		}
 
-		// 更新计数器（即使计数器为0也要保留）
-		if deletedCount > 0 {
-			return txn.Set(countKey, helper.Uint64ToBytes(currentCount))
+		if deleted == 0 {
+			return nil
 		}
-		return nil
+		return s.hashSetCount(txn, key, count)
 	})
+	return deleted, err
 }
 
 // HLen 实现 Redis HLEN 命令
-func (s *BadgerStore) HLen(key string) (uint64, error) {
+func (s *BadgerStore) HLen(key []byte) (int64, error) {
 	var count uint64
 	err := s.db.View(func(txn *badger.Txn) error {
-		countKey := s.hashCountKey(key)
-		item, err := txn.Get(countKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			count = 0
-		} else if err != nil {
-			return err
-		} else {
-			val, err := item.ValueCopy(nil)
+		if _, err := s.checkKeyType(txn, key, KeyTypeHash); err != nil {
+			return err
+		}
+		var err error
+		count, err = s.hashLiveCount(txn, key)
+		return err
+	})
+	return int64(count), err
+}
+
+// hashIterate 按字段的哈希顺序遍历未过期的字段。withValues 为 false 时不预取值，
+// 只有带过期时间的字段才会读取值，传给 fn 的 value 为 nil
+func (s *BadgerStore) hashIterate(txn *badger.Txn, key []byte, withValues bool, fn func(field, value []byte) error) error {
+	prefix := s.hashFieldPrefix(key)
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = withValues
+	opts.Prefix = prefix
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+	now := nowMilli()
+	for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+		item := iter.Item()
+		var value []byte
+		if withValues || item.UserMeta()&hashMetaExpire != 0 {
+			f, err := hashDecodeItem(item)
 			if err != nil {
-				return fmt.Errorf("HLen: failed to get count value: %v", err)
+				return err
+			}
+			if f.expired(now) {
+				continue
+			}
+			if withValues {
+				value = f.value
+			}
+		}
+		if err := fn(scanOrderElement(prefix, item.KeyCopy(nil)), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HGetAll 实现 Redis HGETALL 命令。返回的 map 没有顺序，回复时由 WriteMap 按字段的字节序输出
+func (s *BadgerStore) HGetAll(key []byte) (map[string][]byte, error) {
+	result := make(map[string][]byte)
+	err := s.db.View(func(txn *badger.Txn) error {
+		if _, err := s.checkKeyType(txn, key, KeyTypeHash); err != nil {
+			return err
+		}
+		return s.hashIterate(txn, key, true, func(field, value []byte) error {
+			result[string(field)] = value
+			return nil
+		})
+	})
+	return result, err
+}
+
+// HExists 实现 Redis HEXISTS 命令
+func (s *BadgerStore) HExists(key, field []byte) (bool, error) {
+	var exists bool
+	err := s.db.View(func(txn *badger.Txn) error {
+		if _, err := s.checkKeyType(txn, key, KeyTypeHash); err != nil {
+			return err
+		}
+		f, err := s.hashReadField(txn, key, field)
+		exists = f != nil
+		return err
+	})
+	return exists, err
+}
+
+// HStrLen 实现 Redis HSTRLEN 命令，key 或字段不存在时返回 0
+func (s *BadgerStore) HStrLen(key, field []byte) (int64, error) {
+	var length int64
+	err := s.db.View(func(txn *badger.Txn) error {
+		if _, err := s.checkKeyType(txn, key, KeyTypeHash); err != nil {
+			return err
+		}
+		f, err := s.hashReadField(txn, key, field)
+		if f != nil {
+			length = int64(len(f.value))
+		}
+		return err
+	})
+	return length, err
+}
+
+// HKeys 实现 Redis HKEYS 命令，字段按哈希顺序返回，与 HVALS 的顺序一致。只遍历键，不读取字段的值
+func (s *BadgerStore) HKeys(key []byte) ([][]byte, error) {
+	var fields [][]byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		if _, err := s.checkKeyType(txn, key, KeyTypeHash); err != nil {
+			return err
+		}
+		return s.hashIterate(txn, key, false, func(field, _ []byte) error {
+			fields = append(fields, field)
+			return nil
+		})
+	})
+	return fields, err
+}
+
+// HVals 实现 Redis HVALS 命令，值按字段的哈希顺序返回，与 HKEYS 的顺序一致
+func (s *BadgerStore) HVals(key []byte) ([][]byte, error) {
+	var values [][]byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		if _, err := s.checkKeyType(txn, key, KeyTypeHash); err != nil {
+			return err
+		}
+		return s.hashIterate(txn, key, true, func(_, value []byte) error {
+			values = append(values, value)
+			return nil
+		})
+	})
+	return values, err
+}
+
+// HRandField 实现 Redis HRANDFIELD 命令。count 为正数时返回不重复的字段，超过字段数量时返回全部字段；
+// count 为负数时返回 -count 个可能重复的字段。withValues 为 false 时 values 为 nil
+func (s *BadgerStore) HRandField(key []byte, count int64, withValues bool) (fields, values [][]byte, err error) {
+	err = s.db.View(func(txn *badger.Txn) error {
+		fields, values = nil, nil
+		if _, err := s.checkKeyType(txn, key, KeyTypeHash); err != nil {
+			return err
+		}
+		size, err := s.hashLiveCount(txn, key)
+		if err != nil || size == 0 || count == 0 {
+			return err
+		}
+
+		pairs, err := randomSample(size, count, func(fn func(pair [2][]byte) bool) error {
+			err := s.hashIterate(txn, key, withValues, func(field, value []byte) error {
+				if !fn([2][]byte{field, value}) {
+					return errIterationDone
+				}
+				return nil
+			})
+			if errors.Is(err, errIterationDone) {
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, pair := range pairs {
+			fields = append(fields, pair[0])
+			if withValues {
+				values = append(values, pair[1])
 			}
-			count = helper.BytesToUint64(val)
 		}
 		return nil
 	})
-	return count, err
+	return fields, values, err
 }
 
-// HGetAll 实现 Redis HGETALL 命令
-func (s *BadgerStore) HGetAll(key string) (map[string][]byte, error) {
-	result := make(map[string][]byte)
-	prefix := fmt.Sprintf("%s:%s:", KeyTypeHash, key)
+// HScan 实现 Redis HSCAN 命令，从游标 cursor 继续遍历，至少检查 count 个字段，
+// 只返回匹配 match 的字段（match 为 nil 时不过滤）。pairs 依次为 field、value，withValues 为 false 时只有 field。
+// 游标是下一个字段的哈希值，遍历结束时返回的游标为 0
+func (s *BadgerStore) HScan(key []byte, cursor uint64, match []byte, count int, withValues bool) (uint64, [][]byte, error) {
+	if count < 1 {
+		count = 1
+	}
+	var next uint64
+	var pairs [][]byte
 	err := s.db.View(func(txn *badger.Txn) error {
-		iter := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer iter.Close()
-		prefixBytes := []byte(prefix)
-		for iter.Seek(prefixBytes); iter.Valid(); iter.Next() {
-			k := iter.Item().Key()
-			kStr := string(k)
-			if !strings.HasPrefix(kStr, prefix) {
-				break
-			}
-			// 提取字段名
-			_, field := splitHashKey(k)
-			val, _ := iter.Item().ValueCopy(nil)
-			result[field] = val
+		next, pairs = 0, nil
+		if _, err := s.checkKeyType(txn, key, KeyTypeHash); err != nil {
+			return err
 		}
+		now := nowMilli()
+		var err error
+		next, err = scanRecords(txn, s.hashFieldPrefix(key), cursor, count, false, func(item *badger.Item, field []byte) error {
+			if match != nil && !helper.StringMatch(match, field, false) {
+				return nil
+			}
+			if !withValues && item.UserMeta()&hashMetaExpire == 0 {
+				pairs = append(pairs, field)
+				return nil
+			}
+			f, err := hashDecodeItem(item)
+			if err != nil || f.expired(now) {
+				return err
+			}
+			pairs = append(pairs, field)
+			if withValues {
+				pairs = append(pairs, f.value)
+			}
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return next, pairs, nil
+}
+
+// hashIncr 在事务中读取字段，用 fn 计算新值后写回，字段不存在时 fn 收到 nil 并新增字段。
+// 与 Redis 一致，自增保留字段原有的过期时间
+func (s *BadgerStore) hashIncr(txn *badger.Txn, key, field []byte, fn func(old []byte) ([]byte, error)) error {
+	_, count, err := s.hashPrepareWrite(txn, key)
+	if err != nil {
+		return err
+	}
+	old, err := s.hashReadField(txn, key, field)
+	if err != nil {
+		return err
+	}
+	var oldValue []byte
+	var expireAt int64
+	if old != nil {
+		oldValue, expireAt = old.value, old.expireAt
+	}
+	val, err := fn(oldValue)
+	if err != nil {
+		return err
+	}
+	if err := s.hashPutField(txn, key, field, val, expireAt); err != nil {
+		return err
+	}
+	if old != nil {
 		return nil
+	}
+	return s.hashSetCount(txn, key, count+1)
+}
+
+// HIncrBy 实现 Redis HINCRBY 命令，字段不存在时视为 0，返回自增后的值
+func (s *BadgerStore) HIncrBy(key, field []byte, delta int64) (int64, error) {
+	var result int64
+	err := s.update(func(txn *badger.Txn) error {
+		result = 0
+		return s.hashIncr(txn, key, field, func(old []byte) ([]byte, error) {
+			var current int64
+			if old != nil {
+				n, err := parseInt64(old)
+				if err != nil {
+					return nil, ErrHashNotInteger
+				}
+				current = n
+			}
+			sum, err := addInt64(current, delta)
+			if err != nil {
+				return nil, err
+			}
+			result = sum
+			return []byte(strconv.FormatInt(sum, 10)), nil
+		})
 	})
 	return result, err
 }
 
-// splitHashKey 从哈希键中解析出字段名
-func splitHashKey(key []byte) (string, string) {
-	parts := strings.SplitN(string(key), ":", 3)
-	if len(parts) >= 3 {
-		return parts[1], parts[2]
+// HIncrByFloat 实现 Redis HINCRBYFLOAT 命令，以 long double 精度计算，返回格式化后的新值
+func (s *BadgerStore) HIncrByFloat(key, field, incr []byte) ([]byte, error) {
+	delta, err := parseLongDouble(incr)
+	if err != nil {
+		return nil, err
+	}
+	if delta.IsInf() {
+		return nil, ErrIncrNaNOrInfinity
+	}
+	var result []byte
+	err = s.update(func(txn *badger.Txn) error {
+		result = nil
+		return s.hashIncr(txn, key, field, func(old []byte) ([]byte, error) {
+			current := new(big.Float).SetPrec(longDoublePrec)
+			if old != nil {
+				f, err := parseLongDouble(old)
+				if err != nil {
+					return nil, ErrHashNotFloat
+				}
+				current = f
+			}
+			sum, err := addLongDouble(current, delta)
+			if err != nil {
+				return nil, err
+			}
+			result = sum
+			return sum, nil
+		})
+	})
+	return result, err
+}
+
+// ExpireCond 是设置过期时间时的 NX | XX | GT | LT 条件
+type ExpireCond int
+
+const (
+	ExpireAlways ExpireCond = iota
+	ExpireNX                // 只在没有过期时间时设置
+	ExpireXX                // 只在已有过期时间时设置
+	ExpireGT                // 只在新的过期时间更晚时设置，没有过期时间视为永不过期
+	ExpireLT                // 只在新的过期时间更早时设置
+)
+
+// allow 判断当前过期时间为 current（0 表示不过期）时能否设置为 expireAt
+func (c ExpireCond) allow(current, expireAt int64) bool {
+	switch c {
+	case ExpireNX:
+		return current == 0
+	case ExpireXX:
+		return current != 0
+	case ExpireGT:
+		return current != 0 && expireAt > current
+	case ExpireLT:
+		return current == 0 || expireAt < current
+	}
+	return true
+}
+
+// HEXPIRE 系列命令对每个字段的回复
+const (
+	HashFieldNotFound = -2 // key 或字段不存在
+	HashFieldNoExpire = -1 // 字段没有过期时间
+	HashExpireSkipped = 0  // 不满足 NX | XX | GT | LT 条件
+	HashExpireSet     = 1  // 已设置过期时间，HPERSIST 中表示已清除过期时间
+	HashExpireDeleted = 2  // 过期时间不晚于当前时间，字段已被删除
+)
+
+// hashForFields 在写事务中对每个存在的字段执行 fn，key 或字段不存在时对应的结果为 HashFieldNotFound。
+// fn 删除字段时返回 deleted 为 true，由这里统一更新字段数量
+func (s *BadgerStore) hashForFields(key []byte, fields [][]byte, fn func(txn *badger.Txn, field []byte, f *hashField) (result int64, deleted bool, err error)) ([]int64, error) {
+	results := make([]int64, len(fields))
+	err := s.update(func(txn *badger.Txn) error {
+		for i := range results {
+			results[i] = HashFieldNotFound
+		}
+		exists, count, err := s.hashPrepareWrite(txn, key)
+		if err != nil || !exists {
+			return err
+		}
+		var deleted uint64
+		for i, field := range fields {
+			f, err := s.hashReadField(txn, key, field)
+			if err != nil {
+				return err
+			}
+			if f == nil {
+				continue
+			}
+			result, del, err := fn(txn, field, f)
+			if err != nil {
+				return err
+			}
+			results[i] = result
+			if del {
+				deleted++
+			}
+		}
+		if deleted == 0 {
+			return nil
+		}
+		return s.hashSetCount(txn, key, count-deleted)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// HExpire 实现 Redis HEXPIRE、HPEXPIRE、HEXPIREAT 和 HPEXPIREAT 命令，expireAt 为毫秒时间戳。
+// 返回值依次对应每个字段，取值见 HashFieldNotFound 等常量
+func (s *BadgerStore) HExpire(key []byte, expireAt int64, cond ExpireCond, fields [][]byte) ([]int64, error) {
+	return s.hashForFields(key, fields, func(txn *badger.Txn, field []byte, f *hashField) (int64, bool, error) {
+		if !cond.allow(f.expireAt, expireAt) {
+			return HashExpireSkipped, false, nil
+		}
+		if expireAt <= nowMilli() {
+			return HashExpireDeleted, true, s.hashDeleteField(txn, key, field, f)
+		}
+		if f.expireAt != 0 {
+			if err := txn.Delete(s.hashExpireKey(key, f.expireAt, field)); err != nil {
+				return 0, false, err
+			}
+		}
+		return HashExpireSet, false, s.hashPutField(txn, key, field, f.value, expireAt)
+	})
+}
+
+// HPersist 实现 Redis HPERSIST 命令，清除字段的过期时间
+func (s *BadgerStore) HPersist(key []byte, fields [][]byte) ([]int64, error) {
+	return s.hashForFields(key, fields, func(txn *badger.Txn, field []byte, f *hashField) (int64, bool, error) {
+		if f.expireAt == 0 {
+			return HashFieldNoExpire, false, nil
+		}
+		if err := txn.Delete(s.hashExpireKey(key, f.expireAt, field)); err != nil {
+			return 0, false, err
+		}
+		return HashExpireSet, false, s.hashPutField(txn, key, field, f.value, 0)
+	})
+}
+
+// HExpireTime 返回字段的毫秒级过期时间戳，没有过期时间时为 HashFieldNoExpire，
+// key 或字段不存在时为 HashFieldNotFound。HTTL、HPTTL、HEXPIRETIME 和 HPEXPIRETIME 都基于它实现
+func (s *BadgerStore) HExpireTime(key []byte, fields [][]byte) ([]int64, error) {
+	results := make([]int64, len(fields))
+	err := s.db.View(func(txn *badger.Txn) error {
+		if _, err := s.checkKeyType(txn, key, KeyTypeHash); err != nil {
+			return err
+		}
+		for i, field := range fields {
+			f, err := s.hashReadField(txn, key, field)
+			if err != nil {
+				return err
+			}
+			switch {
+			case f == nil:
+				results[i] = HashFieldNotFound
+			case f.expireAt == 0:
+				results[i] = HashFieldNoExpire
+			default:
+				results[i] = f.expireAt
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return "", ""
+	return results, nil
 }
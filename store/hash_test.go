@@ -2,7 +2,12 @@ package store
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/zeebo/assert"
 )
 
 func TestHashAuto(t *testing.T) {
@@ -12,27 +17,333 @@ func TestHashAuto(t *testing.T) {
 	defer store.Close()
 
 	// 设置哈希字段
-	err := store.HSet("user:1", "name", "Alice")
+	_, err := store.HSet([]byte("user:1"), [][]byte{[]byte("name"), []byte("Alice")})
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = store.HSet("user:1", "age", 30)
+	_, err = store.HSet([]byte("user:1"), [][]byte{[]byte("age"), []byte("30")})
 	if err != nil {
 		t.Fatal(err)
 	}
 	// 获取所有字段
-	data, err := store.HGetAll("user:1")
+	data, err := store.HGetAll([]byte("user:1"))
 	if err != nil {
 		t.Fatal(err)
 	}
-	// 输出: map[name:[65 108 105 99 101] age:[30]]
 	t.Log(data)
 	// 删除字段
-	deleted, _ := store.HDel("user:1", "age")
+	deleted, _ := store.HDel([]byte("user:1"), []byte("age"))
 	fmt.Println(deleted) // 1
 
 	// 获取字段数量
-	count, _ := store.HLen("user:1")
+	count, _ := store.HLen([]byte("user:1"))
 	fmt.Println(count) // 1
 
 }
+
+func TestHashBinarySafe(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("h")
+
+	added, err := store.HSet(key, [][]byte{[]byte("f1"), []byte("v1"), []byte("f2"), []byte("\x00\xff"), []byte("f1"), []byte("v1b")})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), added)
+	added, _ = store.HSet(key, [][]byte{[]byte("f2"), []byte("x"), []byte("f3"), []byte("")})
+	assert.Equal(t, int64(1), added)
+
+	// 值按原样保存
+	val, _ := store.HGet(key, []byte("f1"))
+	assert.Equal(t, "v1b", string(val))
+	val, _ = store.HGet(key, []byte("f3"))
+	assert.Equal(t, "", string(val))
+	assert.NotNil(t, val)
+	val, err = store.HGet(key, []byte("missing"))
+	assert.NoError(t, err)
+	assert.Nil(t, val)
+
+	values, _ := store.HMGet(key, [][]byte{[]byte("f2"), []byte("nope"), []byte("f1")})
+	assert.Equal(t, "x", string(values[0]))
+	assert.Nil(t, values[1])
+	assert.Equal(t, "v1b", string(values[2]))
+
+	written, _ := store.HSetNX(key, []byte("f1"), []byte("other"))
+	assert.False(t, written)
+	written, _ = store.HSetNX(key, []byte("f4"), []byte("v4"))
+	assert.True(t, written)
+	n, _ := store.HLen(key)
+	assert.Equal(t, int64(4), n)
+
+	// HDEL 返回实际删除的数量，删除全部字段后 key 不再存在
+	deleted, _ := store.HDel(key, []byte("f1"), []byte("nope"), []byte("f1"))
+	assert.Equal(t, int64(1), deleted)
+	deleted, _ = store.HDel(key, []byte("f2"), []byte("f3"), []byte("f4"))
+	assert.Equal(t, int64(3), deleted)
+	n, _ = store.HLen(key)
+	assert.Equal(t, int64(0), n)
+	_ = store.Set(key, []byte("now a string"))
+	val, _ = store.Get(key)
+	assert.Equal(t, "now a string", string(val))
+
+	_, err = store.HSet(key, [][]byte{[]byte("f"), []byte("v")})
+	assert.Equal(t, ErrWrongType, err)
+	_, err = store.HGet(key, []byte("f"))
+	assert.Equal(t, ErrWrongType, err)
+}
+
+func TestHIncrBy(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("user:123")
+
+	n, err := store.HIncrBy(key, []byte("logins"), 5)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	n, _ = store.HIncrBy(key, []byte("logins"), -7)
+	assert.Equal(t, int64(-2), n)
+	// 新建字段时维护字段数量，已有字段不重复计数
+	count, _ := store.HLen(key)
+	assert.Equal(t, int64(1), count)
+
+	_, _ = store.HSet(key, [][]byte{[]byte("name"), []byte("alice"), []byte("max"), []byte("9223372036854775807")})
+	_, err = store.HIncrBy(key, []byte("name"), 1)
+	assert.Equal(t, ErrHashNotInteger, err)
+	_, err = store.HIncrBy(key, []byte("max"), 1)
+	assert.Equal(t, ErrOverflow, err)
+	val, _ := store.HGet(key, []byte("max"))
+	assert.Equal(t, "9223372036854775807", string(val))
+
+	f, err := store.HIncrByFloat(key, []byte("points"), []byte("10.5"))
+	assert.NoError(t, err)
+	assert.Equal(t, "10.5", string(f))
+	f, _ = store.HIncrByFloat(key, []byte("points"), []byte("0.1"))
+	assert.Equal(t, "10.6", string(f))
+	f, _ = store.HIncrByFloat(key, []byte("logins"), []byte("5.0e3"))
+	assert.Equal(t, "4998", string(f))
+	count, _ = store.HLen(key)
+	assert.Equal(t, int64(4), count)
+
+	_, err = store.HIncrByFloat(key, []byte("name"), []byte("1"))
+	assert.Equal(t, ErrHashNotFloat, err)
+	_, err = store.HIncrByFloat(key, []byte("points"), []byte("abc"))
+	assert.Equal(t, ErrNotFloat, err)
+	_, err = store.HIncrByFloat(key, []byte("points"), []byte("inf"))
+	assert.Equal(t, ErrIncrNaNOrInfinity, err)
+
+	_ = store.Set([]byte("str"), []byte("1"))
+	_, err = store.HIncrBy([]byte("str"), []byte("f"), 1)
+	assert.Equal(t, ErrWrongType, err)
+}
+
+func TestHashRead(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("h")
+
+	// 名为 count 的字段不会与字段数量冲突，遍历时也不会读到计数器
+	_, _ = store.HSet(key, [][]byte{[]byte("count"), []byte("7"), []byte("a"), []byte("hello"), []byte("b"), []byte("")})
+	n, _ := store.HLen(key)
+	assert.Equal(t, int64(3), n)
+	all, _ := store.HGetAll(key)
+	assert.Equal(t, map[string][]byte{"count": []byte("7"), "a": []byte("hello"), "b": {}}, all)
+	keys, _ := store.HKeys(key)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("count")}, keys)
+	vals, _ := store.HVals(key)
+	assert.Equal(t, [][]byte{[]byte("hello"), {}, []byte("7")}, vals)
+
+	exists, _ := store.HExists(key, []byte("a"))
+	assert.True(t, exists)
+	exists, _ = store.HExists(key, []byte("z"))
+	assert.False(t, exists)
+	length, _ := store.HStrLen(key, []byte("a"))
+	assert.Equal(t, int64(5), length)
+	length, _ = store.HStrLen(key, []byte("z"))
+	assert.Equal(t, int64(0), length)
+
+	// 名字以 h: 开头的哈希与 h 的记录互不干扰，覆盖 h 也不会删除它们
+	_, _ = store.HSet([]byte("h:field"), [][]byte{[]byte("x"), []byte("1")})
+	_, _ = store.HSet([]byte("h:count"), [][]byte{[]byte("y"), []byte("2")})
+	keys, _ = store.HKeys(key)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("count")}, keys)
+	n, _ = store.HLen(key)
+	assert.Equal(t, int64(3), n)
+	_ = store.Set(key, []byte("v"))
+	all, _ = store.HGetAll([]byte("h:field"))
+	assert.Equal(t, map[string][]byte{"x": []byte("1")}, all)
+	n, _ = store.HLen([]byte("h:count"))
+	assert.Equal(t, int64(1), n)
+	_ = store.Del(string(key))
+
+	keys, err := store.HKeys([]byte("missing"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(keys))
+	_ = store.Set([]byte("str"), []byte("v"))
+	_, err = store.HExists([]byte("str"), []byte("a"))
+	assert.Equal(t, ErrWrongType, err)
+}
+
+func TestHRandField(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("h")
+	var pairs [][]byte
+	for i := 0; i < 20; i++ {
+		pairs = append(pairs, []byte(fmt.Sprintf("f%d", i)), []byte(fmt.Sprintf("v%d", i)))
+	}
+	_, _ = store.HSet(key, pairs)
+
+	// 正数返回不重复的字段
+	for _, count := range []int64{1, 5, 15, 20, 100} {
+		fields, values, err := store.HRandField(key, count, true)
+		assert.NoError(t, err)
+		assert.Equal(t, int(min(count, 20)), len(fields))
+		seen := make(map[string]bool)
+		for i, field := range fields {
+			assert.False(t, seen[string(field)])
+			seen[string(field)] = true
+			assert.Equal(t, "v"+string(field[1:]), string(values[i]))
+		}
+	}
+	// 负数允许重复
+	fields, values, _ := store.HRandField(key, -50, false)
+	assert.Equal(t, 50, len(fields))
+	assert.Nil(t, values)
+	_, _, err := store.HRandField(key, -9223372036854775807, false)
+	assert.Equal(t, ErrRandomCountRange, err)
+
+	fields, _, _ = store.HRandField(key, 0, false)
+	assert.Equal(t, 0, len(fields))
+	fields, _, err = store.HRandField([]byte("missing"), -3, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(fields))
+}
+
+func TestHScan(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewBadgerStore(dir)
+	defer func() { store.Close() }()
+	key := []byte("h")
+	var pairs [][]byte
+	for i := 0; i < 100; i++ {
+		pairs = append(pairs, []byte(fmt.Sprintf("field:%03d", i)), []byte(fmt.Sprintf("%d", i)))
+	}
+	_, _ = store.HSet(key, pairs)
+	_, _ = store.HSet([]byte("h2"), [][]byte{[]byte("other"), []byte("x")})
+
+	// 分多次遍历完所有字段，每个字段恰好返回一次；游标不依赖服务端状态，重启后继续有效
+	seen := make(map[string]string)
+	cursor, calls := uint64(0), 0
+	for {
+		next, items, err := store.HScan(key, cursor, nil, 7, true)
+		assert.NoError(t, err)
+		assert.True(t, len(items) <= 14)
+		for i := 0; i < len(items); i += 2 {
+			_, dup := seen[string(items[i])]
+			assert.False(t, dup)
+			seen[string(items[i])] = string(items[i+1])
+		}
+		calls++
+		if cursor = next; cursor == 0 {
+			break
+		}
+		if calls == 5 {
+			store.Close()
+			store, _ = NewBadgerStore(dir)
+		}
+	}
+	assert.Equal(t, 100, len(seen))
+	assert.Equal(t, "42", seen["field:042"])
+	assert.Equal(t, 15, calls)
+
+	// MATCH 只过滤返回的字段，COUNT 仍然按检查的字段数计算
+	next, items, _ := store.HScan(key, 0, []byte("field:0[0-1]?"), 1000, false)
+	assert.Equal(t, uint64(0), next)
+	assert.Equal(t, 20, len(items))
+	for _, field := range items {
+		assert.True(t, strings.HasPrefix(string(field), "field:0"))
+	}
+
+	// 任意游标都从对应的哈希值继续，只返回哈希值不小于游标的字段
+	cursor = scanHash([]byte("field:050"))
+	_, items, _ = store.HScan(key, cursor, nil, 1000, false)
+	assert.True(t, len(items) > 0)
+	for _, field := range items {
+		assert.True(t, scanHash(field) >= cursor)
+	}
+	assert.Equal(t, "field:050", string(items[0]))
+}
+
+func TestHashFieldExpire(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("session")
+	_, _ = store.HSet(key, [][]byte{[]byte("a"), []byte("1"), []byte("b"), []byte("2"), []byte("c"), []byte("3")})
+	fields := [][]byte{[]byte("a"), []byte("b"), []byte("missing")}
+
+	results, err := store.HExpire(key, nowMilli()+60000, ExpireAlways, fields)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{HashExpireSet, HashExpireSet, HashFieldNotFound}, results)
+	// NX 要求没有过期时间，GT 要求更晚，LT 要求更早，没有过期时间视为永不过期
+	results, _ = store.HExpire(key, nowMilli()+120000, ExpireNX, [][]byte{[]byte("a"), []byte("c")})
+	assert.Equal(t, []int64{HashExpireSkipped, HashExpireSet}, results)
+	results, _ = store.HExpire(key, nowMilli()+30000, ExpireGT, [][]byte{[]byte("a"), []byte("c")})
+	assert.Equal(t, []int64{HashExpireSkipped, HashExpireSkipped}, results)
+	results, _ = store.HExpire(key, nowMilli()+30000, ExpireLT, [][]byte{[]byte("a")})
+	assert.Equal(t, []int64{HashExpireSet}, results)
+	times, _ := store.HExpireTime(key, [][]byte{[]byte("a"), []byte("c"), []byte("missing")})
+	assert.True(t, times[0] > nowMilli() && times[0] <= nowMilli()+30000)
+	assert.True(t, times[1] > nowMilli()+60000)
+	assert.Equal(t, int64(HashFieldNotFound), times[2])
+
+	// HPERSIST 清除过期时间，HSET 覆盖字段时也会清除
+	results, _ = store.HPersist(key, [][]byte{[]byte("a"), []byte("a"), []byte("missing")})
+	assert.Equal(t, []int64{HashExpireSet, HashFieldNoExpire, HashFieldNotFound}, results)
+	_, _ = store.HSet(key, [][]byte{[]byte("c"), []byte("33")})
+	times, _ = store.HExpireTime(key, [][]byte{[]byte("c")})
+	assert.Equal(t, []int64{HashFieldNoExpire}, times)
+	// HINCRBY 保留过期时间
+	_, _ = store.HExpire(key, nowMilli()+60000, ExpireAlways, [][]byte{[]byte("c")})
+	_, _ = store.HIncrBy(key, []byte("c"), 1)
+	times, _ = store.HExpireTime(key, [][]byte{[]byte("c")})
+	assert.True(t, times[0] > 0)
+	val, _ := store.HGet(key, []byte("c"))
+	assert.Equal(t, "34", string(val))
+
+	// 过期时间不晚于当前时间时直接删除字段
+	results, _ = store.HExpire(key, 1, ExpireAlways, [][]byte{[]byte("a")})
+	assert.Equal(t, []int64{HashExpireDeleted}, results)
+	n, _ := store.HLen(key)
+	assert.Equal(t, int64(2), n)
+
+	// 到期的字段从读命令中消失，字段数量随之减少，下一次写入时清理
+	_, _ = store.HExpire(key, nowMilli()+30, ExpireAlways, [][]byte{[]byte("b")})
+	time.Sleep(60 * time.Millisecond)
+	val, _ = store.HGet(key, []byte("b"))
+	assert.Nil(t, val)
+	n, _ = store.HLen(key)
+	assert.Equal(t, int64(1), n)
+	all, _ := store.HGetAll(key)
+	assert.Equal(t, map[string][]byte{"c": []byte("34")}, all)
+	keys, _ := store.HKeys(key)
+	assert.Equal(t, [][]byte{[]byte("c")}, keys)
+	_, items, _ := store.HScan(key, 0, nil, 10, false)
+	assert.Equal(t, [][]byte{[]byte("c")}, items)
+	added, _ := store.HSet(key, [][]byte{[]byte("b"), []byte("new")})
+	assert.Equal(t, int64(1), added)
+	n, _ = store.HLen(key)
+	assert.Equal(t, int64(2), n)
+
+	// 所有字段都过期后 key 不再存在
+	_, _ = store.HExpire(key, nowMilli()+30, ExpireAlways, [][]byte{[]byte("b"), []byte("c")})
+	time.Sleep(60 * time.Millisecond)
+	deleted, _ := store.HDel(key, []byte("b"))
+	assert.Equal(t, int64(0), deleted)
+	_ = store.db.View(func(txn *badger.Txn) error {
+		keyType, err := store.keyType(txn, key)
+		assert.NoError(t, err)
+		assert.Equal(t, "", keyType)
+		return nil
+	})
+	results, _ = store.HExpire(key, nowMilli()+1000, ExpireAlways, [][]byte{[]byte("b")})
+	assert.Equal(t, []int64{HashFieldNotFound}, results)
+}
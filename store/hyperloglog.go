@@ -0,0 +1,609 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// HyperLogLog 以字符串保存，字节格式与 Redis 的 hyperloglog.c 完全一致，
+// 从 Redis 中 GET 出来的 HLL 可以直接 SET 进来使用，反之亦然。
+//
+// 16 字节头部："HYLL" + 1 字节编码 + 3 字节保留 + 8 字节小端序的基数缓存，
+// 缓存最高字节的最高位为 1 表示缓存失效。之后是寄存器：
+//   - 稠密编码：16384 个 6 位寄存器，共 12288 字节
+//   - 稀疏编码：由 ZERO(00xxxxxx)、XZERO(01xxxxxx yyyyyyyy)、VAL(1vvvvvxx) 三种操作码组成的游程编码
+
+const (
+	hllP           = 14
+	hllQ           = 64 - hllP
+	hllRegisters   = 1 << hllP
+	hllPMask       = hllRegisters - 1
+	hllBits        = 6
+	hllRegisterMax = 1<<hllBits - 1
+	hllHdrSize     = 16
+	hllDenseSize   = hllHdrSize + (hllRegisters*hllBits+7)/8
+
+	hllDense       = 0
+	hllSparse      = 1
+	hllMaxEncoding = 1
+
+	hllSparseXZeroBit    = 0x40
+	hllSparseValBit      = 0x80
+	hllSparseValMaxValue = 32
+	hllSparseValMaxLen   = 4
+	hllSparseZeroMaxLen  = 64
+	hllSparseXZeroMaxLen = 16384
+	hllSparseMaxBytes    = 3000 // 对应 Redis 默认的 hll-sparse-max-bytes
+	hllAlphaInf          = 0.721347520444481703680
+	hllMurmurSeed        = 0xadc83b19
+)
+
+var (
+	// ErrInvalidHLL 表示字符串不是合法的 HyperLogLog
+	ErrInvalidHLL = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	// ErrCorruptedHLL 表示 HyperLogLog 的稀疏编码已损坏
+	ErrCorruptedHLL = errors.New("INVALIDOBJ Corrupted HLL object detected")
+)
+
+// hllCreate 创建一个空的稀疏编码 HLL，全部寄存器由 XZERO 操作码表示，基数缓存为 0
+func hllCreate() []byte {
+	b := make([]byte, hllHdrSize, hllHdrSize+hllRegisters/hllSparseXZeroMaxLen*2)
+	copy(b, "HYLL")
+	b[4] = hllSparse
+	for left := hllRegisters; left > 0; left -= hllSparseXZeroMaxLen {
+		b = hllSparseXZeroSet(b, min(left, hllSparseXZeroMaxLen))
+	}
+	return b
+}
+
+// hllValid 检查字符串是否是合法的 HLL，对应 Redis 的 isHLLObjectOrReply
+func hllValid(b []byte) bool {
+	if len(b) < hllHdrSize || string(b[:4]) != "HYLL" || b[4] > hllMaxEncoding {
+		return false
+	}
+	return b[4] != hllDense || len(b) == hllDenseSize
+}
+
+func hllInvalidateCache(b []byte) {
+	b[15] |= 1 << 7
+}
+
+func hllCacheValid(b []byte) bool {
+	return b[15]&(1<<7) == 0
+}
+
+// murmurHash64A 是 Redis 使用的 MurmurHash64A，按小端序读取
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const m uint64 = 0xc6a4a7935bd1e995
+	const r = 47
+	h := seed ^ (uint64(len(key)) * m)
+
+	n := len(key) &^ 7
+	for i := 0; i < n; i += 8 {
+		k := binary.LittleEndian.Uint64(key[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+	}
+	tail := key[n:]
+	if len(tail) > 0 {
+		for i := len(tail) - 1; i >= 0; i-- {
+			h ^= uint64(tail[i]) << (8 * i)
+		}
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// hllPatLen 返回元素对应的寄存器序号，以及去掉序号位之后从低位开始 "000..1" 模式的长度
+func hllPatLen(ele []byte) (int, uint8) {
+	hash := murmurHash64A(ele, hllMurmurSeed)
+	index := int(hash & hllPMask)
+	hash >>= hllP
+	hash |= 1 << hllQ // 保证循环结束且长度不超过 Q+1
+	count := uint8(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return index, count
+}
+
+// hllDenseGet 读取稠密编码的第 index 个寄存器
+func hllDenseGet(regs []byte, index int) uint8 {
+	byteIdx := index * hllBits / 8
+	fb := uint(index * hllBits & 7)
+	b0 := regs[byteIdx]
+	var b1 byte
+	if byteIdx+1 < len(regs) {
+		b1 = regs[byteIdx+1]
+	}
+	return ((b0 >> fb) | (b1 << (8 - fb))) & hllRegisterMax
+}
+
+// hllDenseSetRegister 写入稠密编码的第 index 个寄存器
+func hllDenseSetRegister(regs []byte, index int, val uint8) {
+	byteIdx := index * hllBits / 8
+	fb := uint(index * hllBits & 7)
+	regs[byteIdx] &^= hllRegisterMax << fb
+	regs[byteIdx] |= val << fb
+	if byteIdx+1 < len(regs) {
+		regs[byteIdx+1] &^= hllRegisterMax >> (8 - fb)
+		regs[byteIdx+1] |= val >> (8 - fb)
+	}
+}
+
+// hllDenseSet 在 count 大于寄存器当前值时更新寄存器，返回是否修改
+func hllDenseSet(regs []byte, index int, count uint8) bool {
+	if count > hllDenseGet(regs, index) {
+		hllDenseSetRegister(regs, index, count)
+		return true
+	}
+	return false
+}
+
+func hllSparseIsZero(op byte) bool  { return op&0xc0 == 0 }
+func hllSparseIsXZero(op byte) bool { return op&0xc0 == hllSparseXZeroBit }
+func hllSparseZeroLen(op byte) int  { return int(op&0x3f) + 1 }
+func hllSparseXZeroLen(op, next byte) int {
+	return (int(op&0x3f)<<8 | int(next)) + 1
+}
+func hllSparseValValue(op byte) uint8 { return (op>>2)&0x1f + 1 }
+func hllSparseValLen(op byte) int     { return int(op&0x3) + 1 }
+
+func hllSparseValOp(val uint8, length int) byte {
+	return byte(val-1)<<2 | byte(length-1) | hllSparseValBit
+}
+
+func hllSparseZeroSet(b []byte, length int) []byte {
+	return append(b, byte(length-1))
+}
+
+func hllSparseXZeroSet(b []byte, length int) []byte {
+	l := length - 1
+	return append(b, byte(l>>8)|hllSparseXZeroBit, byte(l&0xff))
+}
+
+// hllSparseToDense 把稀疏编码转换为稠密编码，头部（包括基数缓存）保持不变
+func hllSparseToDense(b []byte) ([]byte, error) {
+	if b[4] == hllDense {
+		return b, nil
+	}
+	dense := make([]byte, hllDenseSize)
+	copy(dense, b[:hllHdrSize])
+	dense[4] = hllDense
+	regs := dense[hllHdrSize:]
+
+	idx := 0
+	for p := hllHdrSize; p < len(b); {
+		op := b[p]
+		switch {
+		case hllSparseIsZero(op):
+			idx += hllSparseZeroLen(op)
+			p++
+		case hllSparseIsXZero(op):
+			if p+1 >= len(b) {
+				return nil, ErrCorruptedHLL
+			}
+			idx += hllSparseXZeroLen(op, b[p+1])
+			p += 2
+		default:
+			runLen, val := hllSparseValLen(op), hllSparseValValue(op)
+			if idx+runLen > hllRegisters {
+				return nil, ErrCorruptedHLL
+			}
+			for ; runLen > 0; runLen-- {
+				hllDenseSetRegister(regs, idx, val)
+				idx++
+			}
+			p++
+		}
+	}
+	if idx != hllRegisters {
+		return nil, ErrCorruptedHLL
+	}
+	return dense, nil
+}
+
+// hllSparseSet 在 count 大于寄存器当前值时更新稀疏编码中的寄存器，返回新的 HLL 和是否修改。
+// 值超过 VAL 操作码的上限或长度超过 hllSparseMaxBytes 时转换为稠密编码，算法与 Redis 的 hllSparseSet 一致
+func hllSparseSet(b []byte, index int, count uint8) ([]byte, bool, error) {
+	if count > hllSparseValMaxValue {
+		return hllPromote(b, index, count)
+	}
+
+	// 1. 找到包含 index 的操作码
+	end := len(b)
+	p, prev := hllHdrSize, -1
+	first, span, opLen := 0, 0, 1
+	for p < end {
+		op := b[p]
+		opLen = 1
+		switch {
+		case hllSparseIsZero(op):
+			span = hllSparseZeroLen(op)
+		case hllSparseIsXZero(op):
+			if p+1 >= end {
+				return nil, false, ErrCorruptedHLL
+			}
+			span = hllSparseXZeroLen(op, b[p+1])
+			opLen = 2
+		default:
+			span = hllSparseValLen(op)
+		}
+		if index <= first+span-1 {
+			break
+		}
+		prev = p
+		p += opLen
+		first += span
+	}
+	if span == 0 || p >= end {
+		return nil, false, ErrCorruptedHLL
+	}
+
+	op := b[p]
+	isVal := !hllSparseIsZero(op) && !hllSparseIsXZero(op)
+	runLen := span
+
+	// 2. 计算替换后的操作码序列
+	updated := false
+	if isVal {
+		oldCount := hllSparseValValue(op)
+		if oldCount >= count {
+			return b, false, nil
+		}
+		if runLen == 1 {
+			b[p] = hllSparseValOp(count, 1)
+			updated = true
+		}
+	} else if hllSparseIsZero(op) && runLen == 1 {
+		b[p] = hllSparseValOp(count, 1)
+		updated = true
+	}
+
+	if !updated {
+		last := first + span - 1
+		seq := make([]byte, 0, 5)
+		if !isVal {
+			if index != first {
+				if l := index - first; l > hllSparseZeroMaxLen {
+					seq = hllSparseXZeroSet(seq, l)
+				} else {
+					seq = hllSparseZeroSet(seq, l)
+				}
+			}
+			seq = append(seq, hllSparseValOp(count, 1))
+			if index != last {
+				if l := last - index; l > hllSparseZeroMaxLen {
+					seq = hllSparseXZeroSet(seq, l)
+				} else {
+					seq = hllSparseZeroSet(seq, l)
+				}
+			}
+		} else {
+			curVal := hllSparseValValue(op)
+			if index != first {
+				seq = append(seq, hllSparseValOp(curVal, index-first))
+			}
+			seq = append(seq, hllSparseValOp(count, 1))
+			if index != last {
+				seq = append(seq, hllSparseValOp(curVal, last-index))
+			}
+		}
+
+		// 3. 用新的序列替换原操作码，超过稀疏编码的长度上限时转换为稠密编码
+		delta := len(seq) - opLen
+		if delta > 0 && len(b)+delta > hllSparseMaxBytes {
+			return hllPromote(b, index, count)
+		}
+		replaced := make([]byte, 0, len(b)+delta)
+		replaced = append(replaced, b[:p]...)
+		replaced = append(replaced, seq...)
+		replaced = append(replaced, b[p+opLen:]...)
+		b = replaced
+		end = len(b)
+	}
+
+	// 4. 合并前后相邻且值相同的 VAL 操作码，最多检查 5 个操作码
+	if prev >= 0 {
+		p = prev
+	} else {
+		p = hllHdrSize
+	}
+	for scan := 5; p < end && scan > 0; scan-- {
+		switch {
+		case hllSparseIsXZero(b[p]):
+			p += 2
+			continue
+		case hllSparseIsZero(b[p]):
+			p++
+			continue
+		}
+		if p+1 < end && !hllSparseIsZero(b[p+1]) && !hllSparseIsXZero(b[p+1]) {
+			v1, v2 := hllSparseValValue(b[p]), hllSparseValValue(b[p+1])
+			if l := hllSparseValLen(b[p]) + hllSparseValLen(b[p+1]); v1 == v2 && l <= hllSparseValMaxLen {
+				b[p+1] = hllSparseValOp(v1, l)
+				b = append(b[:p], b[p+1:]...)
+				end--
+				// 合并后不移动 p，继续尝试与右边的操作码合并
+				continue
+			}
+		}
+		p++
+	}
+	return b, true, nil
+}
+
+func hllPromote(b []byte, index int, count uint8) ([]byte, bool, error) {
+	dense, err := hllSparseToDense(b)
+	if err != nil {
+		return nil, false, err
+	}
+	hllDenseSet(dense[hllHdrSize:], index, count)
+	return dense, true, nil
+}
+
+// hllAdd 把元素加入 HLL，返回新的 HLL 和是否有寄存器被修改
+func hllAdd(b, ele []byte) ([]byte, bool, error) {
+	index, count := hllPatLen(ele)
+	if b[4] == hllDense {
+		return b, hllDenseSet(b[hllHdrSize:], index, count), nil
+	}
+	return hllSparseSet(b, index, count)
+}
+
+// hllMerge 把 HLL 的寄存器按最大值合并到 max 中，max 每个寄存器占一个字节
+func hllMerge(max []uint8, b []byte) error {
+	if b[4] == hllDense {
+		regs := b[hllHdrSize:]
+		for i := 0; i < hllRegisters; i++ {
+			if val := hllDenseGet(regs, i); val > max[i] {
+				max[i] = val
+			}
+		}
+		return nil
+	}
+	i := 0
+	for p := hllHdrSize; p < len(b); {
+		op := b[p]
+		switch {
+		case hllSparseIsZero(op):
+			i += hllSparseZeroLen(op)
+			p++
+		case hllSparseIsXZero(op):
+			if p+1 >= len(b) {
+				return ErrCorruptedHLL
+			}
+			i += hllSparseXZeroLen(op, b[p+1])
+			p += 2
+		default:
+			runLen, val := hllSparseValLen(op), hllSparseValValue(op)
+			if i+runLen > hllRegisters {
+				return ErrCorruptedHLL
+			}
+			for ; runLen > 0; runLen-- {
+				if val > max[i] {
+					max[i] = val
+				}
+				i++
+			}
+			p++
+		}
+	}
+	if i != hllRegisters {
+		return ErrCorruptedHLL
+	}
+	return nil
+}
+
+// hllCount 估算 HLL 的基数
+func hllCount(b []byte) (uint64, error) {
+	// 稠密和稀疏编码都先合并到每个寄存器一个字节的数组中，再统计直方图
+	regs := make([]uint8, hllRegisters)
+	if err := hllMerge(regs, b); err != nil {
+		return 0, err
+	}
+	return hllCountRaw(regs), nil
+}
+
+// hllCountRaw 用 Ertl 的改进估计算法计算基数，与 Redis 的 hllCount 一致
+func hllCountRaw(regs []uint8) uint64 {
+	var histo [64]int
+	for _, reg := range regs {
+		histo[reg]++
+	}
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histo[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histo[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histo[0])/m)
+	return uint64(math.Round(hllAlphaInf * m * m / z))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+// hllLookup 读取 key 中保存的 HLL，key 不存在时返回 nil，不是合法的 HLL 时返回 ErrInvalidHLL
+func (s *BadgerStore) hllLookup(txn *badger.Txn, key []byte) ([]byte, *stringEntry, error) {
+	entry, err := s.stringLookup(txn, key)
+	if err != nil || entry == nil {
+		return nil, nil, err
+	}
+	val, err := s.stringLoad(txn, key, entry)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !hllValid(val) {
+		return nil, nil, ErrInvalidHLL
+	}
+	return val, entry, nil
+}
+
+// PFAdd 实现 Redis PFADD 命令，返回是否有寄存器被修改或新建了 key
+func (s *BadgerStore) PFAdd(key []byte, elements [][]byte) (bool, error) {
+	var updated bool
+	err := s.update(func(txn *badger.Txn) error {
+		updated = false
+		hll, entry, err := s.hllLookup(txn, key)
+		if err != nil {
+			return err
+		}
+		var expireAt int64
+		if hll == nil {
+			hll = hllCreate()
+			updated = true
+		} else {
+			expireAt = entry.expireAt
+		}
+		for _, ele := range elements {
+			var changed bool
+			if hll, changed, err = hllAdd(hll, ele); err != nil {
+				return err
+			}
+			updated = updated || changed
+		}
+		if !updated {
+			return nil
+		}
+		hllInvalidateCache(hll)
+		return s.stringWrite(txn, key, hll, expireAt)
+	})
+	return updated, err
+}
+
+// PFCount 实现 Redis PFCOUNT 命令。单个 key 时优先使用头部的基数缓存，缓存失效时重新计算并写回；
+// 多个 key 时返回它们并集的基数估计，不修改任何 key
+func (s *BadgerStore) PFCount(keys [][]byte) (int64, error) {
+	var card uint64
+	if len(keys) == 1 {
+		err := s.update(func(txn *badger.Txn) error {
+			card = 0
+			hll, entry, err := s.hllLookup(txn, keys[0])
+			if err != nil || hll == nil {
+				return err
+			}
+			if hllCacheValid(hll) {
+				card = binary.LittleEndian.Uint64(hll[8:hllHdrSize])
+				return nil
+			}
+			if card, err = hllCount(hll); err != nil {
+				return err
+			}
+			binary.LittleEndian.PutUint64(hll[8:hllHdrSize], card)
+			return s.stringWrite(txn, keys[0], hll, entry.expireAt)
+		})
+		return int64(card), err
+	}
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		regs := make([]uint8, hllRegisters)
+		for _, key := range keys {
+			hll, _, err := s.hllLookup(txn, key)
+			if err != nil {
+				return err
+			}
+			if hll == nil {
+				continue
+			}
+			if err := hllMerge(regs, hll); err != nil {
+				return err
+			}
+		}
+		card = hllCountRaw(regs)
+		return nil
+	})
+	return int64(card), err
+}
+
+// PFMerge 实现 Redis PFMERGE 命令，把 destKey 和全部源 key 的并集写入 destKey。
+// 任意一个输入是稠密编码时结果为稠密编码，destKey 原有的过期时间保持不变
+func (s *BadgerStore) PFMerge(destKey []byte, srcKeys [][]byte) error {
+	return s.update(func(txn *badger.Txn) error {
+		regs := make([]uint8, hllRegisters)
+		useDense := false
+		var dest []byte
+		var destEntry *stringEntry
+		for i, key := range append([][]byte{destKey}, srcKeys...) {
+			hll, entry, err := s.hllLookup(txn, key)
+			if err != nil {
+				return err
+			}
+			if hll == nil {
+				continue
+			}
+			if i == 0 {
+				dest, destEntry = hll, entry
+			}
+			if hll[4] == hllDense {
+				useDense = true
+			}
+			if err := hllMerge(regs, hll); err != nil {
+				return err
+			}
+		}
+
+		var expireAt int64
+		if dest == nil {
+			dest = hllCreate()
+		} else {
+			expireAt = destEntry.expireAt
+		}
+		var err error
+		if useDense {
+			if dest, err = hllSparseToDense(dest); err != nil {
+				return err
+			}
+		}
+		for i, val := range regs {
+			if val == 0 {
+				continue
+			}
+			if dest[4] == hllDense {
+				hllDenseSet(dest[hllHdrSize:], i, val)
+			} else if dest, _, err = hllSparseSet(dest, i, val); err != nil {
+				return err
+			}
+		}
+		hllInvalidateCache(dest)
+		return s.stringWrite(txn, destKey, dest, expireAt)
+	})
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"bytes"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestPFAdd(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("hll")
+
+	// 不带元素时创建空的稀疏 HLL，与 Redis 一致新建的 key 也会把基数缓存标记为失效
+	updated, err := store.PFAdd(key, nil)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	val, _ := store.Get(key)
+	assert.Equal(t, "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80\x7f\xff", string(val))
+	updated, _ = store.PFAdd(key, nil)
+	assert.False(t, updated)
+
+	updated, _ = store.PFAdd(key, [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+	assert.True(t, updated)
+	updated, _ = store.PFAdd(key, [][]byte{[]byte("a")})
+	assert.False(t, updated)
+	n, _ := store.PFCount([][]byte{key})
+	assert.Equal(t, int64(3), n)
+
+	// 基数缓存写回头部，再次 PFADD 时失效
+	val, _ = store.Get(key)
+	assert.True(t, hllCacheValid(val))
+	_, _ = store.PFAdd(key, [][]byte{[]byte("d")})
+	val, _ = store.Get(key)
+	assert.False(t, hllCacheValid(val))
+	n, _ = store.PFCount([][]byte{key})
+	assert.Equal(t, int64(4), n)
+
+	_ = store.Set([]byte("str"), []byte("not a hll"))
+	_, err = store.PFAdd([]byte("str"), [][]byte{[]byte("a")})
+	assert.Equal(t, ErrInvalidHLL, err)
+	_, err = store.PFCount([][]byte{[]byte("str")})
+	assert.Equal(t, ErrInvalidHLL, err)
+	n, _ = store.PFCount([][]byte{[]byte("missing")})
+	assert.Equal(t, int64(0), n)
+}
+
+func TestPFCountAccuracy(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("hll")
+
+	for _, total := range []int{100, 1000, 10000, 50000} {
+		var batch [][]byte
+		for i := len(batch); i < total; i++ {
+			batch = append(batch, []byte("ele:"+strconv.Itoa(i)))
+		}
+		_, err := store.PFAdd(key, batch)
+		assert.NoError(t, err)
+		n, _ := store.PFCount([][]byte{key})
+		diff := float64(n-int64(total)) / float64(total)
+		assert.True(t, diff < 0.02 && diff > -0.02)
+	}
+	// 寄存器较多时已转换为稠密编码
+	val, _ := store.Get(key)
+	assert.Equal(t, byte(hllDense), val[4])
+	assert.Equal(t, hllDenseSize, len(val))
+
+	// 原始字节可以通过 GET/SET 搬到别的 key
+	assert.NoError(t, store.Set([]byte("copy"), val))
+	n1, _ := store.PFCount([][]byte{key})
+	n2, _ := store.PFCount([][]byte{[]byte("copy")})
+	assert.Equal(t, n1, n2)
+}
+
+func TestHLLSparseMatchesDense(t *testing.T) {
+	// 随机写入稀疏编码，每一步都与稠密寄存器逐个比较
+	rnd := rand.New(rand.NewSource(1))
+	sparse := hllCreate()
+	dense := make([]byte, hllDenseSize)
+	for i := 0; i < 3000 && sparse[4] == hllSparse; i++ {
+		index := rnd.Intn(hllRegisters)
+		if i%3 == 0 && index > 0 {
+			// 集中写入相邻的寄存器，覆盖 VAL 操作码的拆分与合并
+			index = i % 40
+		}
+		count := uint8(rnd.Intn(hllSparseValMaxValue) + 1)
+		var err error
+		sparse, _, err = hllSparseSet(sparse, index, count)
+		assert.NoError(t, err)
+		hllDenseSet(dense[hllHdrSize:], index, count)
+
+		regs := make([]uint8, hllRegisters)
+		assert.NoError(t, hllMerge(regs, sparse))
+		for j := 0; j < hllRegisters; j++ {
+			if regs[j] != hllDenseGet(dense[hllHdrSize:], j) {
+				t.Fatalf("step %d: register %d is %d, want %d", i, j, regs[j], hllDenseGet(dense[hllHdrSize:], j))
+			}
+		}
+	}
+
+	converted, err := hllSparseToDense(hllCreate())
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(converted[hllHdrSize:], make([]byte, hllDenseSize-hllHdrSize)))
+}
+
+func TestPFMerge(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+
+	var a, b [][]byte
+	for i := 0; i < 5000; i++ {
+		a = append(a, []byte(strconv.Itoa(i)))
+		b = append(b, []byte(strconv.Itoa(i+2500)))
+	}
+	_, _ = store.PFAdd([]byte("a"), a)
+	_, _ = store.PFAdd([]byte("b"), b)
+	union, _ := store.PFCount([][]byte{[]byte("a"), []byte("b"), []byte("missing")})
+	assert.True(t, union > 7350 && union < 7650)
+
+	assert.NoError(t, store.PFMerge([]byte("dest"), [][]byte{[]byte("a"), []byte("b")}))
+	n, _ := store.PFCount([][]byte{[]byte("dest")})
+	assert.Equal(t, union, n)
+
+	// 都是稀疏编码时结果保持稀疏编码，目标 key 自身也参与合并
+	_, _ = store.PFAdd([]byte("s1"), [][]byte{[]byte("x"), []byte("y")})
+	_, _ = store.PFAdd([]byte("s2"), [][]byte{[]byte("z")})
+	assert.NoError(t, store.PFMerge([]byte("s1"), [][]byte{[]byte("s2")}))
+	val, _ := store.Get([]byte("s1"))
+	assert.Equal(t, byte(hllSparse), val[4])
+	n, _ = store.PFCount([][]byte{[]byte("s1")})
+	assert.Equal(t, int64(3), n)
+
+	_ = store.Set([]byte("str"), []byte("HYLL"))
+	assert.Equal(t, ErrInvalidHLL, store.PFMerge([]byte("dest"), [][]byte{[]byte("str")}))
+}
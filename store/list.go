@@ -2,26 +2,75 @@ package store
 
 import (
 	"PumbaaDB/helper"
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/dgraph-io/badger/v4"
-	"github.com/google/uuid"
 )
 
-// ListNode 结构体定义了链表节点的结构
-// ID 是节点的唯一标识符，使用字符串存储
-// Value 是节点存储的数据，以字节切片形式存储
-// Prev 是指向前一个节点的 ID，使用字符串存储
-// Next 是指向后一个节点的 ID，使用字符串存储
-type ListNode struct {
-	ID    string
-	Value []byte // 节点存储的值，以字节切片形式表示
-	Prev  string // 指向前一个节点的 ID
-	Next  string // 指向后一个节点的 ID
+// 列表的元素按序号保存在 LIST:<key>:<大端序 8 字节序号> 下，序号在 [head, tail) 范围内连续，
+// 元数据保存在 LIST:<key>:meta 下，值为大端序的 head 和 tail。LIST:<key> 是 keyPrefix 生成的带长度的前缀。LPUSH 把元素写到 head-1，
+// RPUSH 写到 tail，push 和 pop 只改动元素和元数据两个键，按下标读取可以直接定位，
+// 范围读取是一次有序的前缀扫描。新列表的 head 和 tail 从 listInitialIndex 开始，两端都留有足够的空间
+//
+// 旧版本把元素保存在随机 uuid 节点下，用 LIST:key:length、start、end 记录元数据，
+// 用 LIST:key:<uuid>:prev、next 记录前后节点。第一次访问旧格式的列表时会在一个事务内转换为新格式
+
+// listInitialIndex 是新列表的起始序号
+const listInitialIndex uint64 = 1 << 63
+
+var (
+	// ErrNoSuchKey 表示 LSET 等命令要求的 key 不存在
+	ErrNoSuchKey = errors.New("ERR no such key")
+	// ErrIndexOutOfRange 表示 LSET 的下标超出列表范围
+	ErrIndexOutOfRange = errors.New("ERR index out of range")
+
+	// errListLegacy 表示列表仍是旧的链表格式，需要先转换
+	errListLegacy = errors.New("list is stored in the legacy linked-list format")
+)
+
+// listMeta 是列表的元数据，元素的序号范围为 [head, tail)
+type listMeta struct {
+	head uint64
+	tail uint64
 }
 
-// listKey 方法用于生成存储在 Badger 数据库中的键
+func (m *listMeta) length() uint64 {
+	return m.tail - m.head
+}
+
+// index 把 Redis 的下标（负数从尾部计数）换算为元素的序号，超出范围时返回 false
+func (m *listMeta) index(i int64) (uint64, bool) {
+	length := int64(m.length())
+	if i < 0 {
+		i += length
+	}
+	if i < 0 || i >= length {
+		return 0, false
+	}
+	return m.head + uint64(i), true
+}
+
+// rangeOf 按 LRANGE、LTRIM 的规则把 [start, stop] 换算为序号范围 [from, to)，范围为空时 from == to
+func (m *listMeta) rangeOf(start, stop int64) (from, to uint64) {
+	length := int64(m.length())
+	if start < 0 {
+		start = max(start+length, 0)
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start > stop || start >= length {
+		return m.head, m.head
+	}
+	stop = min(stop, length-1)
+	return m.head + uint64(start), m.head + uint64(stop) + 1
+}
+
+// listKey 方法用于生成旧的链表格式在 Badger 数据库中的键
 // key 是链表的主键，以字节切片形式传入
 // parts 是可变参数，用于拼接更多的键信息
 // 返回一个字节切片，作为存储在数据库中的完整键
@@ -29,209 +78,724 @@ func (s *BadgerStore) listKey(key []byte, parts ...string) []byte {
 	return []byte(fmt.Sprintf("%s:%s:%s", KeyTypeList, key, strings.Join(parts, ":")))
 }
 
-// listLength 方法用于获取链表的长度
-// key 是链表的主键，以字节切片形式传入
-// 返回链表的长度（无符号 64 位整数）和可能出现的错误
-func (s *BadgerStore) listLength(key []byte) (uint64, error) {
-	var length uint64
-	errView := s.db.View(func(txn *badger.Txn) error {
-		// 获取长度
-		// 通过 listKey 方法生成存储长度信息的键
-		lengthItem, err := txn.Get(s.listKey(key, "length"))
+// listMetaKey 返回列表元数据的键
+func (s *BadgerStore) listMetaKey(key []byte) []byte {
+	return keyPrefix(prefixKeyList, key, ":meta")
+}
+
+// listElementPrefix 返回列表元素共同的键前缀，元数据的键也以它开头
+func (s *BadgerStore) listElementPrefix(key []byte) []byte {
+	return keyPrefix(prefixKeyList, key, ":")
+}
+
+// listElementKey 返回序号为 index 的元素的键
+func (s *BadgerStore) listElementKey(key []byte, index uint64) []byte {
+	return binary.BigEndian.AppendUint64(s.listElementPrefix(key), index)
+}
+
+// listGetMeta 读取列表的元数据，列表不存在时返回 nil，列表是旧格式时返回 errListLegacy
+func (s *BadgerStore) listGetMeta(txn *badger.Txn, key []byte) (*listMeta, error) {
+	item, err := txn.Get(s.listMetaKey(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		_, err = txn.Get(s.listKey(key, "length"))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, nil
+		}
 		if err != nil {
-			return err
+			return nil, err
 		}
-		// 从数据库中获取长度值，并将其复制到 lengthVal 中
-		lengthVal, _ := lengthItem.ValueCopy(nil)
-		// 将字节切片形式的长度值转换为无符号 64 位整数
-		length = helper.BytesToUint64(lengthVal)
-		return nil
-	})
-	return length, errView
+		return nil, errListLegacy
+	}
+	if err != nil {
+		return nil, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(val) != 16 {
+		return nil, fmt.Errorf("listGetMeta: corrupted metadata of key %q", key)
+	}
+	return &listMeta{
+		head: binary.BigEndian.Uint64(val),
+		tail: binary.BigEndian.Uint64(val[8:]),
+	}, nil
 }
 
-// store/badger_store.go
-func (s *BadgerStore) listCreate(key []byte) error {
-	return s.db.Update(func(txn *badger.Txn) error {
-		// 初始化链表元数据
-		lengthKey := s.listKey(key, "length")
-		if err := txn.Set(lengthKey, helper.Uint64ToBytes(0)); err != nil {
-			return err
-		}
-		startKey := s.listKey(key, "start")
-		if err := txn.Set(startKey, []byte{}); err != nil {
+// listSetMeta 写入列表的元数据，列表为空时删除元数据和类型标记
+func (s *BadgerStore) listSetMeta(txn *badger.Txn, key []byte, meta *listMeta) error {
+	if meta.length() == 0 {
+		if err := txn.Delete(s.listMetaKey(key)); err != nil {
 			return err
 		}
-		endKey := s.listKey(key, "end")
-		return txn.Set(endKey, []byte{})
+		return txn.Delete(TypeKeyGet(string(key)))
+	}
+	if err := s.setKeyType(txn, key, KeyTypeList); err != nil {
+		return err
+	}
+	val := binary.BigEndian.AppendUint64(nil, meta.head)
+	val = binary.BigEndian.AppendUint64(val, meta.tail)
+	return txn.Set(s.listMetaKey(key), val)
+}
+
+// listPrepare 在写事务中检查 key 的类型并读取元数据，旧格式的列表会先被转换。
+// 列表不存在时返回 head、tail 为初始序号的空元数据
+func (s *BadgerStore) listPrepare(txn *badger.Txn, key []byte) (*listMeta, error) {
+	if _, err := s.checkKeyType(txn, key, KeyTypeList); err != nil {
+		return nil, err
+	}
+	meta, err := s.listGetMeta(txn, key)
+	if errors.Is(err, errListLegacy) {
+		meta, err = s.listMigrate(txn, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		meta = &listMeta{head: listInitialIndex, tail: listInitialIndex}
+	}
+	return meta, nil
+}
+
+// listView 在只读事务中执行 fn，fn 遇到旧格式的列表时先在写事务中转换再重新执行
+func (s *BadgerStore) listView(key []byte, fn func(txn *badger.Txn) error) error {
+	err := s.db.View(fn)
+	if !errors.Is(err, errListLegacy) {
+		return err
+	}
+	err = s.update(func(txn *badger.Txn) error {
+		_, err := s.listPrepare(txn, key)
+		return err
 	})
+	if err != nil {
+		return err
+	}
+	return s.db.View(fn)
 }
 
-func (s *BadgerStore) listGetMeta(keyRedis []byte) (length uint64, start, end string, err error) {
-	err = s.db.View(func(txn *badger.Txn) error {
-		// 获取长度
-		lengthItem, errGet := txn.Get(s.listKey(keyRedis, "length"))
-		if errGet != nil {
-			return errGet
+// listReadLegacy 沿旧格式链表的 next 指针读出全部元素，同时返回这个列表全部旧格式的键：
+// length、start、end 以及读到的每个节点的值、prev、next。名字以 key: 开头的其他列表的键不在其中
+func (s *BadgerStore) listReadLegacy(txn *badger.Txn, key []byte) (values, legacyKeys [][]byte, err error) {
+	readString := func(k []byte) (string, error) {
+		item, err := txn.Get(k)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return "", nil
 		}
-		lengthVal, errValueCopy := lengthItem.ValueCopy(nil)
-		if errValueCopy != nil {
-			return errValueCopy
+		if err != nil {
+			return "", err
 		}
-		length = helper.BytesToUint64(lengthVal)
+		val, err := item.ValueCopy(nil)
+		return string(val), err
+	}
+	legacyKeys = [][]byte{s.listKey(key, "length"), s.listKey(key, "start"), s.listKey(key, "end")}
+	lengthVal, err := readString(legacyKeys[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	length := helper.BytesToUint64([]byte(lengthVal))
+	node, err := readString(legacyKeys[1])
+	if err != nil {
+		return nil, nil, err
+	}
 
-		// 获取起始节点
-		startItem, errStart := txn.Get(s.listKey(keyRedis, "start"))
-		if errStart == nil {
-			startVal, _ := startItem.ValueCopy(nil)
-			start = string(startVal)
+	// 旧格式的尾节点可能指回头节点，只读取 length 个
+	values = make([][]byte, 0, length)
+	for uint64(len(values)) < length && node != "" {
+		nodeKey := s.listKey(key, node)
+		item, err := txn.Get(nodeKey)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, nil, err
 		}
+		values = append(values, val)
+		nextKey := s.listKey(key, node, "next")
+		legacyKeys = append(legacyKeys, nodeKey, s.listKey(key, node, "prev"), nextKey)
+		if node, err = readString(nextKey); err != nil {
+			return nil, nil, err
+		}
+	}
+	return values, legacyKeys, nil
+}
 
-		// 获取结束节点
-		endItem, errEnd := txn.Get(s.listKey(keyRedis, "end"))
-		if errEnd == nil {
-			endVal, _ := endItem.ValueCopy(nil)
-			end = string(endVal)
+// listMigrate 把旧的 uuid 链表转换为按序号存储的格式，返回转换后的元数据
+func (s *BadgerStore) listMigrate(txn *badger.Txn, key []byte) (*listMeta, error) {
+	values, legacyKeys, err := s.listReadLegacy(txn, key)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range legacyKeys {
+		if err := txn.Delete(k); err != nil {
+			return nil, err
 		}
+	}
+	meta := &listMeta{head: listInitialIndex, tail: listInitialIndex}
+	for _, val := range values {
+		if err := txn.Set(s.listElementKey(key, meta.tail), val); err != nil {
+			return nil, err
+		}
+		meta.tail++
+	}
+	if err := s.listSetMeta(txn, key, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// listIterate 遍历序号在 [from, to) 范围内的元素，reverse 为 true 时从尾部开始，fn 返回 false 时停止
+func (s *BadgerStore) listIterate(txn *badger.Txn, key []byte, from, to uint64, reverse bool, fn func(index uint64, value []byte) (bool, error)) error {
+	if from >= to {
 		return nil
-	})
-	return
+	}
+	prefix := s.listElementPrefix(key)
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	opts.Reverse = reverse
+	opts.PrefetchSize = int(min(to-from, uint64(opts.PrefetchSize)))
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+	seek := s.listElementKey(key, from)
+	if reverse {
+		seek = s.listElementKey(key, to-1)
+	}
+	for iter.Seek(seek); iter.ValidForPrefix(prefix); iter.Next() {
+		item := iter.Item()
+		k := item.Key()
+		// 跳过元数据等不是元素的键
+		if len(k) != len(prefix)+8 {
+			continue
+		}
+		index := binary.BigEndian.Uint64(k[len(prefix):])
+		if index >= to || index < from {
+			break
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			val = []byte{}
+		}
+		if next, err := fn(index, val); err != nil || !next {
+			return err
+		}
+	}
+	return nil
 }
 
-func (s *BadgerStore) listUpdateMeta(txn *badger.Txn, key []byte, length uint64, start, end string) error {
-	// 更新长度
-	if err := txn.Set(s.listKey(key, "length"), helper.Uint64ToBytes(length)); err != nil {
-		return err
+// listPush 把 values 依次推入列表的左端或右端，调用方负责写回元数据
+func (s *BadgerStore) listPush(txn *badger.Txn, key []byte, meta *listMeta, left bool, values [][]byte) error {
+	for _, value := range values {
+		index := meta.tail
+		if left {
+			meta.head--
+			index = meta.head
+		} else {
+			meta.tail++
+		}
+		if err := txn.Set(s.listElementKey(key, index), value); err != nil {
+			return err
+		}
 	}
-	// 更新起始节点
-	if err := txn.Set(s.listKey(key, "start"), []byte(start)); err != nil {
-		return err
+	return nil
+}
+
+// listPop 从列表的左端或右端弹出最多 count 个元素，调用方负责写回元数据
+func (s *BadgerStore) listPop(txn *badger.Txn, key []byte, meta *listMeta, left bool, count uint64) ([][]byte, error) {
+	count = min(count, meta.length())
+	values := make([][]byte, 0, count)
+	for i := uint64(0); i < count; i++ {
+		index := meta.tail - 1
+		if left {
+			index = meta.head
+		}
+		val, err := s.listGetElement(txn, key, index)
+		if err != nil {
+			return nil, err
+		}
+		if err := txn.Delete(s.listElementKey(key, index)); err != nil {
+			return nil, err
+		}
+		if left {
+			meta.head++
+		} else {
+			meta.tail--
+		}
+		values = append(values, val)
 	}
-	// 更新结束节点
-	return txn.Set(s.listKey(key, "end"), []byte(end))
+	return values, nil
 }
 
-func (s *BadgerStore) createNode(txn *badger.Txn, key []byte, value []byte) (string, error) {
-	nodeID := uuid.New().String()
-	nodeKey := s.listKey(key, nodeID)
-	if err := txn.Set(nodeKey, value); err != nil {
-		return "", err
+// listGetElement 读取序号为 index 的元素，空字符串返回非 nil 的空切片
+func (s *BadgerStore) listGetElement(txn *badger.Txn, key []byte, index uint64) ([]byte, error) {
+	item, err := txn.Get(s.listElementKey(key, index))
+	if err != nil {
+		return nil, fmt.Errorf("listGetElement: failed to read element %d of key %q: %w", index, key, err)
+	}
+	val, err := item.ValueCopy(nil)
+	// badger 对空值返回 nil，空字符串需要与不存在区分开
+	if err == nil && val == nil {
+		val = []byte{}
 	}
-	return nodeID, nil
+	return val, err
 }
 
-func (s *BadgerStore) linkNodes(txn *badger.Txn, key []byte, prevID, nextID string) error {
-	// 更新前节点的next指针
-	if prevID != "" {
-		prevNextKey := s.listKey(key, prevID, "next")
-		if err := txn.Set(prevNextKey, []byte(nextID)); err != nil {
+// listPushCommand 实现 LPUSH、RPUSH、LPUSHX 和 RPUSHX，返回推入后的列表长度。
+// onlyExisting 为 true 时列表不存在则什么也不做并返回 0
+func (s *BadgerStore) listPushCommand(key []byte, left, onlyExisting bool, values [][]byte) (int64, error) {
+	var length uint64
+	err := s.update(func(txn *badger.Txn) error {
+		length = 0
+		meta, err := s.listPrepare(txn, key)
+		if err != nil {
 			return err
 		}
+		if onlyExisting && meta.length() == 0 {
+			return nil
+		}
+		if err := s.listPush(txn, key, meta, left, values); err != nil {
+			return err
+		}
+		length = meta.length()
+		return s.listSetMeta(txn, key, meta)
+	})
+	return int64(length), err
+}
+
+// LPush 实现 Redis LPUSH 命令，返回推入后的列表长度
+func (s *BadgerStore) LPush(key []byte, values ...[]byte) (int64, error) {
+	return s.listPushCommand(key, true, false, values)
+}
+
+// RPush 实现 Redis RPUSH 命令，返回推入后的列表长度
+func (s *BadgerStore) RPush(key []byte, values ...[]byte) (int64, error) {
+	return s.listPushCommand(key, false, false, values)
+}
+
+// LPushX 实现 Redis LPUSHX 命令，只在列表存在时推入
+func (s *BadgerStore) LPushX(key []byte, values ...[]byte) (int64, error) {
+	return s.listPushCommand(key, true, true, values)
+}
+
+// RPushX 实现 Redis RPUSHX 命令，只在列表存在时推入
+func (s *BadgerStore) RPushX(key []byte, values ...[]byte) (int64, error) {
+	return s.listPushCommand(key, false, true, values)
+}
+
+// listPopCommand 从列表的一端弹出最多 count 个元素，列表不存在时返回 nil
+func (s *BadgerStore) listPopCommand(key []byte, left bool, count uint64) ([][]byte, error) {
+	var values [][]byte
+	err := s.update(func(txn *badger.Txn) error {
+		values = nil
+		meta, err := s.listPrepare(txn, key)
+		if err != nil || meta.length() == 0 {
+			return err
+		}
+		if values, err = s.listPop(txn, key, meta, left, count); err != nil {
+			return err
+		}
+		return s.listSetMeta(txn, key, meta)
+	})
+	return values, err
+}
+
+// LPop 实现 Redis LPOP 命令，列表不存在时返回 nil
+func (s *BadgerStore) LPop(key []byte) ([]byte, error) {
+	values, err := s.listPopCommand(key, true, 1)
+	if err != nil || len(values) == 0 {
+		return nil, err
 	}
-	// 更新后节点的prev指针
-	if nextID != "" {
-		nextPrevKey := s.listKey(key, nextID, "prev")
-		return txn.Set(nextPrevKey, []byte(prevID))
+	return values[0], nil
+}
+
+// RPop 实现 Redis RPOP 命令，列表不存在时返回 nil
+func (s *BadgerStore) RPop(key []byte) ([]byte, error) {
+	values, err := s.listPopCommand(key, false, 1)
+	if err != nil || len(values) == 0 {
+		return nil, err
 	}
-	return nil
+	return values[0], nil
+}
+
+// LPopCount 实现带 count 参数的 LPOP，列表不存在时返回 nil，count 为 0 时返回空切片
+func (s *BadgerStore) LPopCount(key []byte, count uint64) ([][]byte, error) {
+	return s.listPopCount(key, true, count)
+}
+
+// RPopCount 实现带 count 参数的 RPOP，列表不存在时返回 nil，count 为 0 时返回空切片
+func (s *BadgerStore) RPopCount(key []byte, count uint64) ([][]byte, error) {
+	return s.listPopCount(key, false, count)
+}
+
+func (s *BadgerStore) listPopCount(key []byte, left bool, count uint64) ([][]byte, error) {
+	if count > 0 {
+		return s.listPopCommand(key, left, count)
+	}
+	// count 为 0 时不修改列表，只区分列表是否存在
+	length, err := s.LLen(key)
+	if err != nil || length == 0 {
+		return nil, err
+	}
+	return [][]byte{}, nil
 }
 
-// LPush Redis LPUSH 实现
-func (s *BadgerStore) LPush(key []byte, values ...[]byte) (isSuccess int, err error) {
-	err = s.db.Update(func(txn *badger.Txn) error {
-		length, start, end, _ := s.listGetMeta(key)
-		for _, value := range values {
-			// 创建新节点
-			nodeID, err := s.createNode(txn, key, value)
+// LMPop 依次检查 keys，从第一个非空列表的一端弹出最多 count 个元素，返回该列表的 key 和弹出的元素。
+// 所有列表都为空时返回 nil，排在非空列表之后的 key 不会被检查
+func (s *BadgerStore) LMPop(keys [][]byte, left bool, count uint64) ([]byte, [][]byte, error) {
+	var popKey []byte
+	var values [][]byte
+	err := s.update(func(txn *badger.Txn) error {
+		popKey, values = nil, nil
+		for _, key := range keys {
+			meta, err := s.listPrepare(txn, key)
 			if err != nil {
 				return err
 			}
-
-			// 链接节点
-			if length == 0 { // 空链表
-				start = nodeID
-				end = nodeID
-				if err := s.linkNodes(txn, key, nodeID, nodeID); err != nil {
-					return err
-				}
-			} else {
-				// 链接新节点和原头节点
-				if err := s.linkNodes(txn, key, nodeID, start); err != nil {
-					return err
-				}
-				// 更新原头节点的prev指针
-				if err := txn.Set(s.listKey(key, start, "prev"), []byte(nodeID)); err != nil {
-					return err
-				}
-				start = nodeID
+			if meta.length() == 0 {
+				continue
 			}
-			length++
+			if values, err = s.listPop(txn, key, meta, left, count); err != nil {
+				return err
+			}
+			popKey = key
+			return s.listSetMeta(txn, key, meta)
 		}
+		return nil
+	})
+	return popKey, values, err
+}
 
-		// 更新元数据
-		err := s.listUpdateMeta(txn, key, length, start, end)
+// LMove 在同一个事务中从 source 的一端弹出一个元素并推入 destination 的一端，返回被移动的元素。
+// source 不存在时返回 nil；source 与 destination 可以是同一个列表，此时相当于旋转
+func (s *BadgerStore) LMove(source, destination []byte, fromLeft, toLeft bool) ([]byte, error) {
+	var value []byte
+	err := s.update(func(txn *badger.Txn) error {
+		value = nil
+		srcMeta, err := s.listPrepare(txn, source)
+		if err != nil || srcMeta.length() == 0 {
+			return err
+		}
+		// 先检查 destination 的类型，类型不对时 source 保持不变
+		dstMeta, err := s.listPrepare(txn, destination)
 		if err != nil {
 			return err
 		}
-		isSuccess = 1
+		same := bytes.Equal(source, destination)
+		if same {
+			dstMeta = srcMeta
+		}
+		values, err := s.listPop(txn, source, srcMeta, fromLeft, 1)
+		if err != nil {
+			return err
+		}
+		if err := s.listPush(txn, destination, dstMeta, toLeft, values); err != nil {
+			return err
+		}
+		if !same {
+			if err := s.listSetMeta(txn, source, srcMeta); err != nil {
+				return err
+			}
+		}
+		value = values[0]
+		return s.listSetMeta(txn, destination, dstMeta)
+	})
+	return value, err
+}
+
+// LLEN 实现
+func (s *BadgerStore) LLen(key []byte) (uint64, error) {
+	var length uint64
+	err := s.listRead(key, func(_ *badger.Txn, meta *listMeta) error {
+		length = 0
+		if meta != nil {
+			length = meta.length()
+		}
 		return nil
 	})
-	return isSuccess, err
+	return length, err
 }
 
-// RPOP 实现
-func (s *BadgerStore) RPop(key []byte) ([]byte, error) {
+// listRead 在只读事务中读取列表的元数据，列表不存在时 fn 收到 nil
+func (s *BadgerStore) listRead(key []byte, fn func(txn *badger.Txn, meta *listMeta) error) error {
+	return s.listView(key, func(txn *badger.Txn) error {
+		if _, err := s.checkKeyType(txn, key, KeyTypeList); err != nil {
+			return err
+		}
+		meta, err := s.listGetMeta(txn, key)
+		if err != nil {
+			return err
+		}
+		return fn(txn, meta)
+	})
+}
+
+// LRange 实现 Redis LRANGE 命令，start、stop 可以是负数，范围为空时返回空切片
+func (s *BadgerStore) LRange(key []byte, start, stop int64) ([][]byte, error) {
+	var values [][]byte
+	err := s.listRead(key, func(txn *badger.Txn, meta *listMeta) error {
+		values = [][]byte{}
+		if meta == nil {
+			return nil
+		}
+		from, to := meta.rangeOf(start, stop)
+		return s.listIterate(txn, key, from, to, false, func(_ uint64, value []byte) (bool, error) {
+			values = append(values, value)
+			return true, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// LIndex 实现 Redis LINDEX 命令，下标超出范围时返回 nil
+func (s *BadgerStore) LIndex(key []byte, index int64) ([]byte, error) {
 	var value []byte
-	err := s.db.Update(func(txn *badger.Txn) error {
-		length, start, end, err := s.listGetMeta(key)
-		if length == 0 {
+	err := s.listRead(key, func(txn *badger.Txn, meta *listMeta) error {
+		value = nil
+		if meta == nil {
 			return nil
 		}
+		i, ok := meta.index(index)
+		if !ok {
+			return nil
+		}
+		var err error
+		value, err = s.listGetElement(txn, key, i)
+		return err
+	})
+	return value, err
+}
 
-		// 获取尾节点值
-		endNodeKey := s.listKey(key, end)
-		item, err := txn.Get(endNodeKey)
+// LSet 实现 Redis LSET 命令，key 不存在时返回 ErrNoSuchKey，下标超出范围时返回 ErrIndexOutOfRange
+func (s *BadgerStore) LSet(key []byte, index int64, value []byte) error {
+	return s.update(func(txn *badger.Txn) error {
+		meta, err := s.listPrepare(txn, key)
 		if err != nil {
 			return err
 		}
-		value, _ = item.ValueCopy(nil)
+		if meta.length() == 0 {
+			return ErrNoSuchKey
+		}
+		i, ok := meta.index(index)
+		if !ok {
+			return ErrIndexOutOfRange
+		}
+		return txn.Set(s.listElementKey(key, i), value)
+	})
+}
 
-		// 获取新的尾节点
-		newEndKey := s.listKey(key, end, "prev")
-		item, err = txn.Get(newEndKey)
-		if err != nil {
+// LTrim 实现 Redis LTRIM 命令，在同一个事务中删除范围之外的元素，范围为空时删除整个列表
+func (s *BadgerStore) LTrim(key []byte, start, stop int64) error {
+	return s.update(func(txn *badger.Txn) error {
+		meta, err := s.listPrepare(txn, key)
+		if err != nil || meta.length() == 0 {
 			return err
 		}
+		from, to := meta.rangeOf(start, stop)
+		if from == to {
+			from, to = meta.tail, meta.tail
+		}
+		for i := meta.head; i < from; i++ {
+			if err := txn.Delete(s.listElementKey(key, i)); err != nil {
+				return err
+			}
+		}
+		for i := to; i < meta.tail; i++ {
+			if err := txn.Delete(s.listElementKey(key, i)); err != nil {
+				return err
+			}
+		}
+		meta.head, meta.tail = from, to
+		return s.listSetMeta(txn, key, meta)
+	})
+}
 
-		newEndVal, _ := item.ValueCopy(nil)
-		newEnd := string(newEndVal)
+// listMove 把序号在 [from, to) 范围内的元素整体移动 shift 个位置，移动后空出的位置不做处理
+func (s *BadgerStore) listMove(txn *badger.Txn, key []byte, from, to uint64, shift int64) error {
+	var values [][]byte
+	err := s.listIterate(txn, key, from, to, false, func(_ uint64, value []byte) (bool, error) {
+		values = append(values, value)
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	for i, value := range values {
+		if err := txn.Set(s.listElementKey(key, uint64(int64(from)+int64(i)+shift)), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		// 更新链表关系
-		if length == 1 {
-			start = ""
-			newEnd = ""
+// LInsert 实现 Redis LINSERT 命令，在第一个等于 pivot 的元素之前或之后插入 element，
+// 返回插入后的列表长度，找不到 pivot 时返回 -1，key 不存在时返回 0。
+// 插入位置之前和之后的元素中只移动较少的一侧
+func (s *BadgerStore) LInsert(key []byte, before bool, pivot, element []byte) (int64, error) {
+	var length int64
+	err := s.update(func(txn *badger.Txn) error {
+		length = 0
+		meta, err := s.listPrepare(txn, key)
+		if err != nil || meta.length() == 0 {
+			return err
+		}
+		pos, found := uint64(0), false
+		err = s.listIterate(txn, key, meta.head, meta.tail, false, func(index uint64, value []byte) (bool, error) {
+			if bytes.Equal(value, pivot) {
+				pos, found = index, true
+				return false, nil
+			}
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+		if !found {
+			length = -1
+			return nil
+		}
+		if !before {
+			pos++
+		}
+
+		// 新元素插入后位于 pos，原来 pos 及之后的元素右移，或者 pos 之前的元素左移
+		if pos-meta.head < meta.tail-pos {
+			if err := s.listMove(txn, key, meta.head, pos, -1); err != nil {
+				return err
+			}
+			meta.head--
+			pos--
 		} else {
-			// 断开旧尾节点连接
-			if err := s.linkNodes(txn, key, newEnd, start); err != nil {
+			if err := s.listMove(txn, key, pos, meta.tail, 1); err != nil {
 				return err
 			}
+			meta.tail++
+		}
+		if err := txn.Set(s.listElementKey(key, pos), element); err != nil {
+			return err
 		}
+		length = int64(meta.length())
+		return s.listSetMeta(txn, key, meta)
+	})
+	return length, err
+}
 
-		// 删除旧节点数据
-		if err := txn.Delete(endNodeKey); err != nil {
+// LRem 实现 Redis LREM 命令，count 为正数时从头部开始删除 count 个等于 element 的元素，
+// 为负数时从尾部开始删除，为 0 时删除全部，返回删除的数量。剩余的元素向删除较少移动的一端靠拢
+func (s *BadgerStore) LRem(key []byte, count int64, element []byte) (int64, error) {
+	var removed int64
+	err := s.update(func(txn *badger.Txn) error {
+		removed = 0
+		meta, err := s.listPrepare(txn, key)
+		if err != nil || meta.length() == 0 {
+			return err
+		}
+		limit := count
+		if limit < 0 {
+			limit = -limit
+		}
+		matched := make(map[uint64]bool)
+		err = s.listIterate(txn, key, meta.head, meta.tail, count < 0, func(index uint64, value []byte) (bool, error) {
+			if bytes.Equal(value, element) {
+				matched[index] = true
+			}
+			return limit == 0 || int64(len(matched)) < limit, nil
+		})
+		if err != nil || len(matched) == 0 {
 			return err
 		}
-		txn.Delete(s.listKey(key, end, "prev"))
-		txn.Delete(s.listKey(key, end, "next"))
+		removed = int64(len(matched))
 
-		// 更新元数据
-		return s.listUpdateMeta(txn, key, length-1, start, newEnd)
+		first, last := meta.tail, meta.head
+		for index := range matched {
+			first, last = min(first, index), max(last, index)
+		}
+		// 保留的元素向头部或尾部靠拢，只需要改写第一个和最后一个删除位置之外较短的一侧
+		var kept [][]byte
+		toHead := meta.tail-first <= last-meta.head+1
+		from, to := first, meta.tail
+		if !toHead {
+			from, to = meta.head, last+1
+		}
+		err = s.listIterate(txn, key, from, to, false, func(index uint64, value []byte) (bool, error) {
+			if !matched[index] {
+				kept = append(kept, value)
+			}
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+		if toHead {
+			for i, value := range kept {
+				if err := txn.Set(s.listElementKey(key, first+uint64(i)), value); err != nil {
+					return err
+				}
+			}
+			for i := first + uint64(len(kept)); i < meta.tail; i++ {
+				if err := txn.Delete(s.listElementKey(key, i)); err != nil {
+					return err
+				}
+			}
+			meta.tail = first + uint64(len(kept))
+		} else {
+			newHead := last + 1 - uint64(len(kept))
+			for i, value := range kept {
+				if err := txn.Set(s.listElementKey(key, newHead+uint64(i)), value); err != nil {
+					return err
+				}
+			}
+			for i := meta.head; i < newHead; i++ {
+				if err := txn.Delete(s.listElementKey(key, i)); err != nil {
+					return err
+				}
+			}
+			meta.head = newHead
+		}
+		return s.listSetMeta(txn, key, meta)
 	})
-	return value, err
+	return removed, err
 }
 
-// LLEN 实现
-func (s *BadgerStore) LLen(key []byte) (uint64, error) {
-	length, err := s.listLength(key)
-	return length, err
+// LPos 实现 Redis LPOS 命令，返回等于 element 的元素的下标。rank 为正数时从头部开始，
+// 跳过前 rank-1 个匹配，为负数时从尾部开始；count 为 0 时返回全部匹配，maxLen 为 0 时不限制比较的元素数量
+func (s *BadgerStore) LPos(key, element []byte, rank, count, maxLen int64) ([]int64, error) {
+	var positions []int64
+	err := s.listRead(key, func(txn *badger.Txn, meta *listMeta) error {
+		positions = []int64{}
+		if meta == nil {
+			return nil
+		}
+		skip := rank - 1
+		if rank < 0 {
+			skip = -rank - 1
+		}
+		var compared int64
+		return s.listIterate(txn, key, meta.head, meta.tail, rank < 0, func(index uint64, value []byte) (bool, error) {
+			if maxLen > 0 && compared >= maxLen {
+				return false, nil
+			}
+			compared++
+			if !bytes.Equal(value, element) {
+				return true, nil
+			}
+			if skip > 0 {
+				skip--
+				return true, nil
+			}
+			positions = append(positions, int64(index-meta.head))
+			return count == 0 || int64(len(positions)) < count, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return positions, nil
 }
@@ -1,8 +1,11 @@
 package store
 
 import (
+	"PumbaaDB/helper"
+	"strings"
 	"testing"
 
+	"github.com/dgraph-io/badger/v4"
 	"github.com/zeebo/assert"
 )
 
@@ -40,3 +43,309 @@ func TestLinkedList(t *testing.T) {
 	val, _ = store.RPop(key)
 	assert.Nil(t, val)
 }
+
+func TestListLegacyMigration(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("old")
+
+	// 按旧的 uuid 链表格式写入 values，尾节点的 next 指回头节点
+	writeLegacy := func(key []byte, ids []string, values string) {
+		err := store.db.Update(func(txn *badger.Txn) error {
+			for i, id := range ids {
+				_ = txn.Set(store.listKey(key, id), []byte{values[i]})
+				_ = txn.Set(store.listKey(key, id, "next"), []byte(ids[(i+1)%len(ids)]))
+				_ = txn.Set(store.listKey(key, id, "prev"), []byte(ids[(i+len(ids)-1)%len(ids)]))
+			}
+			_ = txn.Set(store.listKey(key, "length"), helper.Uint64ToBytes(uint64(len(ids))))
+			_ = txn.Set(store.listKey(key, "start"), []byte(ids[0]))
+			return txn.Set(store.listKey(key, "end"), []byte(ids[len(ids)-1]))
+		})
+		assert.NoError(t, err)
+	}
+	writeLegacy(key, []string{"3f1c", "9a2b", "0d4e"}, "abc")
+	// 名字以 old: 开头的旧格式列表的键也以 LIST:old: 开头，转换 old 时不能删除它们
+	writeLegacy([]byte("old:3f1c"), []string{"77aa"}, "n")
+
+	// 只读命令也会触发转换
+	length, err := store.LLen(key)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), length)
+	val, _ := store.RPop(key)
+	assert.Equal(t, "c", string(val))
+	_, _ = store.LPush(key, []byte("z"))
+
+	// 旧格式的键全部被删除，只剩元数据和按序号保存的元素
+	listKeys := func(prefix []byte) []string {
+		var keys []string
+		_ = store.db.View(func(txn *badger.Txn) error {
+			iter := txn.NewIterator(badger.DefaultIteratorOptions)
+			defer iter.Close()
+			for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+				keys = append(keys, string(iter.Item().Key()[len(prefix):]))
+			}
+			return nil
+		})
+		return keys
+	}
+	keys := listKeys(store.listElementPrefix(key))
+	assert.Equal(t, 4, len(keys))
+	assert.Equal(t, "meta", keys[0])
+	for _, k := range listKeys(store.listKey(key)) {
+		assert.True(t, strings.HasPrefix(k, "3f1c:"))
+	}
+	values, _ := store.LRange([]byte("old:3f1c"), 0, -1)
+	assert.Equal(t, [][]byte{[]byte("n")}, values)
+	for _, want := range []string{"b", "a", "z"} {
+		val, _ = store.RPop(key)
+		assert.Equal(t, want, string(val))
+	}
+	length, _ = store.LLen(key)
+	assert.Equal(t, uint64(0), length)
+
+	// 列表维护类型标记
+	_, _ = store.LPush(key, []byte("x"))
+	_, err = store.HSet(key, [][]byte{[]byte("f"), []byte("v")})
+	assert.Equal(t, ErrWrongType, err)
+	_ = store.Set([]byte("str"), []byte("v"))
+	_, err = store.LPush([]byte("str"), []byte("x"))
+	assert.Equal(t, ErrWrongType, err)
+}
+
+func TestListPushPop(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("queue")
+
+	// 不存在的列表不会被 LPUSHX、RPUSHX 创建
+	n, err := store.RPushX(key, []byte("a"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	n, _ = store.RPush(key, []byte("a"), []byte("b"), []byte("c"))
+	assert.Equal(t, int64(3), n)
+	n, _ = store.LPushX(key, []byte("z"))
+	assert.Equal(t, int64(4), n)
+	n, _ = store.RPushX(key, []byte(""))
+	assert.Equal(t, int64(5), n)
+
+	val, _ := store.LPop(key)
+	assert.Equal(t, "z", string(val))
+	values, _ := store.LPopCount(key, 2)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b")}, values)
+	values, _ = store.RPopCount(key, 0)
+	assert.NotNil(t, values)
+	assert.Equal(t, 0, len(values))
+	values, _ = store.RPopCount(key, 10)
+	assert.Equal(t, [][]byte{{}, []byte("c")}, values)
+
+	// 弹出最后一个元素后列表不再存在
+	length, _ := store.LLen(key)
+	assert.Equal(t, uint64(0), length)
+	values, err = store.LPopCount(key, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, values)
+	values, _ = store.LPopCount(key, 0)
+	assert.Nil(t, values)
+	n, _ = store.LPushX(key, []byte("a"))
+	assert.Equal(t, int64(0), n)
+}
+
+func TestListRandomAccess(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("feed")
+	for i := 0; i < 10; i++ {
+		_, _ = store.LPush(key, []byte{'0' + byte(i)})
+	}
+	// 列表为 9 8 7 ... 0
+	str := func(values [][]byte) string {
+		var b []byte
+		for _, v := range values {
+			b = append(b, v...)
+		}
+		return string(b)
+	}
+
+	values, _ := store.LRange(key, 0, -1)
+	assert.Equal(t, "9876543210", str(values))
+	values, _ = store.LRange(key, 2, 4)
+	assert.Equal(t, "765", str(values))
+	values, _ = store.LRange(key, -3, 100)
+	assert.Equal(t, "210", str(values))
+	values, _ = store.LRange(key, -100, 1)
+	assert.Equal(t, "98", str(values))
+	values, _ = store.LRange(key, 5, 2)
+	assert.NotNil(t, values)
+	assert.Equal(t, 0, len(values))
+	values, _ = store.LRange([]byte("missing"), 0, -1)
+	assert.Equal(t, 0, len(values))
+
+	val, _ := store.LIndex(key, 0)
+	assert.Equal(t, "9", string(val))
+	val, _ = store.LIndex(key, -1)
+	assert.Equal(t, "0", string(val))
+	val, _ = store.LIndex(key, 10)
+	assert.Nil(t, val)
+
+	assert.NoError(t, store.LSet(key, -2, []byte("x")))
+	val, _ = store.LIndex(key, 8)
+	assert.Equal(t, "x", string(val))
+	assert.Equal(t, ErrIndexOutOfRange, store.LSet(key, 10, []byte("x")))
+	assert.Equal(t, ErrNoSuchKey, store.LSet([]byte("missing"), 0, []byte("x")))
+
+	// LTRIM 删除范围之外的元素，之后的 push 仍然从两端继续
+	assert.NoError(t, store.LTrim(key, 1, -3))
+	values, _ = store.LRange(key, 0, -1)
+	assert.Equal(t, "8765432", str(values))
+	_, _ = store.LPush(key, []byte("a"))
+	_, _ = store.RPush(key, []byte("b"))
+	values, _ = store.LRange(key, 0, -1)
+	assert.Equal(t, "a8765432b", str(values))
+	assert.NoError(t, store.LTrim(key, 0, 2))
+	values, _ = store.LRange(key, 0, -1)
+	assert.Equal(t, "a87", str(values))
+
+	var count int
+	_ = store.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = store.listElementPrefix(key)
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			count++
+		}
+		return nil
+	})
+	// 3 个元素加上元数据
+	assert.Equal(t, 4, count)
+
+	assert.NoError(t, store.LTrim(key, 5, 1))
+	length, _ := store.LLen(key)
+	assert.Equal(t, uint64(0), length)
+	val, _ = store.LIndex(key, 0)
+	assert.Nil(t, val)
+}
+
+func TestListByValue(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("l")
+	str := func() string {
+		values, _ := store.LRange(key, 0, -1)
+		var b []byte
+		for _, v := range values {
+			b = append(b, v...)
+		}
+		return string(b)
+	}
+	_, _ = store.RPush(key, []byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e"))
+
+	// 靠近头部时左移前面的元素，靠近尾部时右移后面的元素
+	n, _ := store.LInsert(key, true, []byte("b"), []byte("x"))
+	assert.Equal(t, int64(6), n)
+	n, _ = store.LInsert(key, false, []byte("d"), []byte("y"))
+	assert.Equal(t, int64(7), n)
+	assert.Equal(t, "axbcdye", str())
+	n, _ = store.LInsert(key, false, []byte("e"), []byte("z"))
+	assert.Equal(t, "axbcdyez", str())
+	n, _ = store.LInsert(key, true, []byte("nope"), []byte("z"))
+	assert.Equal(t, int64(-1), n)
+	n, _ = store.LInsert([]byte("missing"), true, []byte("a"), []byte("z"))
+	assert.Equal(t, int64(0), n)
+
+	_ = store.LTrim(key, 1, 0)
+	_, _ = store.RPush(key, []byte("a"), []byte("b"), []byte("a"), []byte("c"), []byte("a"), []byte("d"), []byte("a"))
+	n, _ = store.LRem(key, 2, []byte("a"))
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, "bcada", str())
+	n, _ = store.LRem(key, -1, []byte("a"))
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, "bcad", str())
+	_, _ = store.LPush(key, []byte("a"))
+	n, _ = store.LRem(key, 0, []byte("a"))
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, "bcd", str())
+	n, _ = store.LRem(key, 0, []byte("nope"))
+	assert.Equal(t, int64(0), n)
+	length, _ := store.LLen(key)
+	assert.Equal(t, uint64(3), length)
+	n, _ = store.LRem(key, 0, []byte("c"))
+	_, _ = store.LRem(key, 0, []byte("b"))
+	_, _ = store.LRem(key, 0, []byte("d"))
+	length, _ = store.LLen(key)
+	assert.Equal(t, uint64(0), length)
+
+	// a b c 1 2 3 c c
+	_, _ = store.RPush(key, []byte("a"), []byte("b"), []byte("c"), []byte("1"), []byte("2"), []byte("3"), []byte("c"), []byte("c"))
+	pos, _ := store.LPos(key, []byte("c"), 1, 1, 0)
+	assert.Equal(t, []int64{2}, pos)
+	pos, _ = store.LPos(key, []byte("c"), 1, 0, 0)
+	assert.Equal(t, []int64{2, 6, 7}, pos)
+	pos, _ = store.LPos(key, []byte("c"), 2, 0, 0)
+	assert.Equal(t, []int64{6, 7}, pos)
+	pos, _ = store.LPos(key, []byte("c"), -1, 2, 0)
+	assert.Equal(t, []int64{7, 6}, pos)
+	pos, _ = store.LPos(key, []byte("c"), 1, 0, 3)
+	assert.Equal(t, []int64{2}, pos)
+	pos, _ = store.LPos(key, []byte("c"), -3, 1, 0)
+	assert.Equal(t, []int64{2}, pos)
+	pos, _ = store.LPos(key, []byte("x"), 1, 1, 0)
+	assert.Equal(t, 0, len(pos))
+}
+
+func TestListMove(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	src, dst := []byte("pending"), []byte("processing")
+	str := func(key []byte) string {
+		values, _ := store.LRange(key, 0, -1)
+		var s string
+		for _, v := range values {
+			s += string(v)
+		}
+		return s
+	}
+
+	val, err := store.LMove(src, dst, false, true)
+	assert.NoError(t, err)
+	assert.Nil(t, val)
+	_, _ = store.RPush(src, []byte("a"), []byte("b"), []byte("c"))
+	val, _ = store.LMove(src, dst, false, true)
+	assert.Equal(t, "c", string(val))
+	val, _ = store.LMove(src, dst, true, false)
+	assert.Equal(t, "a", string(val))
+	assert.Equal(t, "b", str(src))
+	assert.Equal(t, "ca", str(dst))
+
+	// 同一个列表相当于旋转
+	val, _ = store.LMove(dst, dst, true, false)
+	assert.Equal(t, "c", string(val))
+	assert.Equal(t, "ac", str(dst))
+
+	// 移走最后一个元素后 source 不再存在
+	_, _ = store.LMove(src, dst, true, true)
+	length, _ := store.LLen(src)
+	assert.Equal(t, uint64(0), length)
+	assert.Equal(t, "bac", str(dst))
+
+	// destination 类型不对时 source 保持不变
+	wrong := []byte("hash")
+	assert.NoError(t, store.db.Update(func(txn *badger.Txn) error {
+		return store.setKeyType(txn, wrong, KeyTypeHash)
+	}))
+	_, err = store.LMove(dst, wrong, true, true)
+	assert.Equal(t, ErrWrongType, err)
+	assert.Equal(t, "bac", str(dst))
+
+	// LMPOP 从第一个非空列表弹出
+	key, values, err := store.LMPop([][]byte{src, dst, wrong}, false, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, string(dst), string(key))
+	assert.Equal(t, [][]byte{[]byte("c"), []byte("a")}, values)
+	key, values, _ = store.LMPop([][]byte{src}, true, 1)
+	assert.Nil(t, key)
+	assert.Nil(t, values)
+	_, _, err = store.LMPop([][]byte{src, wrong, dst}, true, 1)
+	assert.Equal(t, ErrWrongType, err)
+	assert.Equal(t, "b", str(dst))
+}
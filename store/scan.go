@@ -0,0 +1,61 @@
+package store
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// 哈希的字段和集合的成员按 <8 字节大端序的 FNV-1a 哈希><元素> 保存，按哈希值排序。HSCAN、SSCAN 把下一个要检查的
+// 哈希值作为游标返回，下一次调用从这个哈希值继续。游标只由数据决定，不依赖服务端保存的状态，
+// 服务重启后仍然有效；遍历期间一直存在的元素都会被返回，且只返回一次
+
+// scanHash 计算元素的 64 位哈希，决定元素在遍历中的顺序
+func scanHash(element []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(element)
+	return h.Sum64()
+}
+
+// scanOrderKey 生成按哈希排序的元素的键：prefix、元素的哈希、元素
+func scanOrderKey(prefix, element []byte) []byte {
+	k := make([]byte, 0, len(prefix)+8+len(element))
+	k = append(k, prefix...)
+	k = binary.BigEndian.AppendUint64(k, scanHash(element))
+	return append(k, element...)
+}
+
+// scanOrderElement 从 scanOrderKey 生成的键中取出元素
+func scanOrderElement(prefix, k []byte) []byte {
+	return k[len(prefix)+8:]
+}
+
+// scanRecords 从哈希值 cursor 开始按顺序遍历 prefix 下的元素，检查至少 count 个后停下，
+// 哈希值与最后一个元素相同的元素会一并检查，保证游标不会把它们分开。fn 收到的 element 可以保留。
+// 返回下一次调用使用的游标，遍历结束时返回 0。停下时下一个元素的哈希值大于已检查的元素，不会是 0
+func scanRecords(txn *badger.Txn, prefix []byte, cursor uint64, count int, prefetch bool, fn func(item *badger.Item, element []byte) error) (uint64, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = prefetch
+	opts.Prefix = prefix
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+
+	seek := binary.BigEndian.AppendUint64(append([]byte{}, prefix...), cursor)
+	examined := 0
+	var last uint64
+	for iter.Seek(seek); iter.ValidForPrefix(prefix); iter.Next() {
+		item := iter.Item()
+		k := item.KeyCopy(nil)
+		hash := binary.BigEndian.Uint64(k[len(prefix):])
+		if examined >= count && hash != last {
+			return hash, nil
+		}
+		last = hash
+		examined++
+		if err := fn(item, scanOrderElement(prefix, k)); err != nil {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
@@ -2,122 +2,689 @@ package store
 
 import (
 	"PumbaaDB/helper"
+	"bytes"
 	"errors"
-	"github.com/dgraph-io/badger/v4"
+	"fmt"
+	"sort"
 	"strings"
+
+	"github.com/dgraph-io/badger/v4"
 )
 
-// setKey 方法用于生成存储在 Badger 数据库中的键
-func (s *BadgerStore) setKey(key []byte, parts ...string) []byte {
-	base := []string{"SET", string(key)}
-	base = append(base, parts...)
-	return []byte(strings.Join(base, ":"))
+// 集合的成员保存在 SET:<key>:member:<8 字节哈希><member> 下（见 scanOrderKey），值为空；成员数量保存在 SET:<key>:count 下，
+// SET:<key> 是 keyPrefix 生成的带长度的前缀，名字互为前缀的集合的记录不会混在一起。
+// 成员按哈希值排列，SSCAN 的游标是下一个成员的哈希值，读取全部成员是一次只读键的前缀扫描。最后一个成员被删除时连同计数器和类型标记一起删除
+//
+// 旧版本把成员保存在 SET:key:member:<member> 下，成员数量保存在 SET:key:count 下，没有类型标记。
+// 第一次访问旧格式的集合时会在一个事务内转换为新格式
+
+// errSetLegacy 表示集合仍是旧格式，需要先转换
+var errSetLegacy = errors.New("set is stored in the legacy format")
+
+// setLegacyKey 返回旧格式集合的键
+func (s *BadgerStore) setLegacyKey(key []byte, parts ...string) []byte {
+	return []byte(fmt.Sprintf("%s:%s:%s", KeyTypeSet, key, strings.Join(parts, ":")))
 }
 
-// SAdd 实现 Redis SADD 命令
-func (s *BadgerStore) SAdd(key []byte, members ...[]byte) (int, error) {
-	added := 0
-	err := s.db.Update(func(txn *badger.Txn) error {
-		countKey := s.setKey(key, "count")
-		var count uint64
+// setMemberPrefix 返回集合所有成员共同的键前缀
+func (s *BadgerStore) setMemberPrefix(key []byte) []byte {
+	return keyPrefix(prefixKeySet, key, ":member:")
+}
+
+func (s *BadgerStore) setMemberKey(key, member []byte) []byte {
+	return scanOrderKey(s.setMemberPrefix(key), member)
+}
+
+// setCountKey 返回集合成员数量的计数器键
+func (s *BadgerStore) setCountKey(key []byte) []byte {
+	return keyPrefix(prefixKeySet, key, ":count")
+}
+
+// setGetCount 读取集合的成员数量，不存在时返回 0，集合是旧格式时返回 errSetLegacy
+func (s *BadgerStore) setGetCount(txn *badger.Txn, key []byte) (uint64, error) {
+	item, err := txn.Get(s.setCountKey(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		_, err = txn.Get(s.setLegacyKey(key, "count"))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		return 0, errSetLegacy
+	}
+	if err != nil {
+		return 0, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, fmt.Errorf("setGetCount: failed to get count value: %v", err)
+	}
+	return helper.BytesToUint64(val), nil
+}
+
+// setSetCount 写入集合的成员数量，数量为 0 时删除计数器和类型标记
+func (s *BadgerStore) setSetCount(txn *badger.Txn, key []byte, count uint64) error {
+	if count == 0 {
+		if err := txn.Delete(s.setCountKey(key)); err != nil {
+			return err
+		}
+		return txn.Delete(TypeKeyGet(string(key)))
+	}
+	if err := s.setKeyType(txn, key, KeyTypeSet); err != nil {
+		return err
+	}
+	return txn.Set(s.setCountKey(key), helper.Uint64ToBytes(count))
+}
+
+// setPrepare 检查 key 的类型并返回集合的成员数量
+func (s *BadgerStore) setPrepare(txn *badger.Txn, key []byte) (uint64, error) {
+	if _, err := s.checkKeyType(txn, key, KeyTypeSet); err != nil {
+		return 0, err
+	}
+	return s.setGetCount(txn, key)
+}
+
+// setMigrate 把旧格式的集合转换为按哈希排序的格式，成员数量按实际转换的成员重新计算
+func (s *BadgerStore) setMigrate(txn *badger.Txn, key []byte) error {
+	prefix := s.setLegacyKey(key, "member", "")
+	// 名字以 key:member: 开头的其他旧格式集合的键也在这个前缀下，它们的成员形如 <p>:member:<m>，
+	// 计数器形如 <p>:count。存在 SET:key:member:<p>:count 时认为这样的键属于集合 key:member:<p>
+	nested := func(member []byte) (bool, error) {
+		for i := range member {
+			rest := member[i:]
+			if string(rest) != ":count" && !bytes.HasPrefix(rest, []byte(":member:")) {
+				continue
+			}
+			_, err := txn.Get(s.setLegacyKey(key, "member", string(member[:i]), "count"))
+			if err == nil {
+				return true, nil
+			}
+			if !errors.Is(err, badger.ErrKeyNotFound) {
+				return false, err
+			}
+		}
+		return false, nil
+	}
 
-		// 获取当前计数器值
-		item, err := txn.Get(countKey)
-		if err != badger.ErrKeyNotFound {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	iter := txn.NewIterator(opts)
+	var legacyKeys [][]byte
+	for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+		legacyKey := iter.Item().KeyCopy(nil)
+		isNested, err := nested(legacyKey[len(prefix):])
+		if err != nil {
+			iter.Close()
+			return err
+		}
+		if !isNested {
+			legacyKeys = append(legacyKeys, legacyKey)
+		}
+	}
+	iter.Close()
+
+	for _, legacyKey := range legacyKeys {
+		if err := txn.Delete(legacyKey); err != nil {
+			return err
+		}
+		if err := txn.Set(s.setMemberKey(key, legacyKey[len(prefix):]), []byte{}); err != nil {
+			return err
+		}
+	}
+	if err := txn.Delete(s.setLegacyKey(key, "count")); err != nil {
+		return err
+	}
+	return s.setSetCount(txn, key, uint64(len(legacyKeys)))
+}
+
+// setRun 执行 run，run 遇到旧格式的集合时先在一个写事务中转换 keys 中的旧格式集合，再重新执行一次
+func (s *BadgerStore) setRun(keys [][]byte, run func() error) error {
+	err := run()
+	if !errors.Is(err, errSetLegacy) {
+		return err
+	}
+	err = s.update(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			_, err := s.setGetCount(txn, key)
+			if errors.Is(err, errSetLegacy) {
+				err = s.setMigrate(txn, key)
+			}
 			if err != nil {
 				return err
 			}
-			countBytes, _ := item.ValueCopy(nil)
-			count = helper.BytesToUint64(countBytes)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return run()
+}
+
+// setView 在只读事务中执行 fn，keys 是 fn 访问的集合，遇到旧格式的集合时先转换
+func (s *BadgerStore) setView(keys [][]byte, fn func(txn *badger.Txn) error) error {
+	return s.setRun(keys, func() error { return s.db.View(fn) })
+}
+
+// setUpdate 在读写事务中执行 fn，keys 是 fn 访问的集合，遇到旧格式的集合时先转换
+func (s *BadgerStore) setUpdate(keys [][]byte, fn func(txn *badger.Txn) error) error {
+	return s.setRun(keys, func() error { return s.update(fn) })
+}
+
+// setHasMember 判断 member 是否在集合中
+func (s *BadgerStore) setHasMember(txn *badger.Txn, key, member []byte) (bool, error) {
+	_, err := txn.Get(s.setMemberKey(key, member))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// setIterate 按哈希顺序遍历集合的成员，只读取键不读取值。fn 返回 false 时停止遍历
+func (s *BadgerStore) setIterate(txn *badger.Txn, key []byte, fn func(member []byte) (bool, error)) error {
+	prefix := s.setMemberPrefix(key)
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+	for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+		more, err := fn(scanOrderElement(prefix, iter.Item().KeyCopy(nil)))
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// setSample 按 randomSample 的规则随机选出集合的成员
+func (s *BadgerStore) setSample(txn *badger.Txn, key []byte, size uint64, count int64) ([][]byte, error) {
+	return randomSample(size, count, func(fn func(member []byte) bool) error {
+		return s.setIterate(txn, key, func(member []byte) (bool, error) {
+			return fn(member), nil
+		})
+	})
+}
 
+// SAdd 实现 Redis SADD 命令，返回新加入的成员数量
+func (s *BadgerStore) SAdd(key []byte, members ...[]byte) (int, error) {
+	added := 0
+	err := s.setUpdate([][]byte{key}, func(txn *badger.Txn) error {
+		added = 0
+		count, err := s.setPrepare(txn, key)
+		if err != nil {
+			return err
+		}
 		for _, member := range members {
-			memberStr := string(member)
-			memberKey := s.setKey(key, "member", memberStr)
-
-			// 检查成员是否存在
-			if _, err := txn.Get(memberKey); err == badger.ErrKeyNotFound {
-				// 新成员：写入成员键并增加计数器
-				if err := txn.Set(memberKey, []byte{}); err != nil {
-					return err
-				}
-				count++
-				added++
+			exists, err := s.setHasMember(txn, key, member)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			if err := txn.Set(s.setMemberKey(key, member), []byte{}); err != nil {
+				return err
 			}
+			added++
 		}
-
-		// 更新计数器
-		if added > 0 {
-			return txn.Set(countKey, helper.Uint64ToBytes(count))
+		if added == 0 {
+			return nil
 		}
-		return nil
+		return s.setSetCount(txn, key, count+uint64(added))
 	})
 	return added, err
 }
 
-// SRem 实现 Redis SREM 命令
+// SRem 实现 Redis SREM 命令，返回实际删除的成员数量
 func (s *BadgerStore) SRem(key []byte, members ...[]byte) (int, error) {
 	removed := 0
-	err := s.db.Update(func(txn *badger.Txn) error {
-		countKey := s.setKey(key, "count")
-		var count uint64
+	err := s.setUpdate([][]byte{key}, func(txn *badger.Txn) error {
+		removed = 0
+		count, err := s.setPrepare(txn, key)
+		if err != nil || count == 0 {
+			return err
+		}
+		for _, member := range members {
+			exists, err := s.setHasMember(txn, key, member)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				continue
+			}
+			if err := txn.Delete(s.setMemberKey(key, member)); err != nil {
+				return err
+			}
+			removed++
+		}
+		if removed == 0 {
+			return nil
+		}
+		return s.setSetCount(txn, key, count-min(count, uint64(removed)))
+	})
+	return removed, err
+}
 
-		// 获取当前计数器值
-		item, err := txn.Get(countKey)
-		if !errors.Is(err, badger.ErrKeyNotFound) {
+// SCard 实现 Redis SCARD 命令
+func (s *BadgerStore) SCard(key []byte) (uint64, error) {
+	var count uint64
+	err := s.setView([][]byte{key}, func(txn *badger.Txn) error {
+		var err error
+		count, err = s.setPrepare(txn, key)
+		return err
+	})
+	return count, err
+}
+
+// SIsMember 实现 Redis SISMEMBER 命令，集合或成员不存在时返回 false
+func (s *BadgerStore) SIsMember(key []byte, member []byte) (bool, error) {
+	exists := false
+	err := s.setView([][]byte{key}, func(txn *badger.Txn) error {
+		if _, err := s.setPrepare(txn, key); err != nil {
+			return err
+		}
+		var err error
+		exists, err = s.setHasMember(txn, key, member)
+		return err
+	})
+	return exists, err
+}
+
+// SMIsMember 实现 Redis SMISMEMBER 命令，结果与 members 一一对应
+func (s *BadgerStore) SMIsMember(key []byte, members ...[]byte) ([]bool, error) {
+	var result []bool
+	err := s.setView([][]byte{key}, func(txn *badger.Txn) error {
+		result = make([]bool, len(members))
+		if _, err := s.setPrepare(txn, key); err != nil {
+			return err
+		}
+		for i, member := range members {
+			exists, err := s.setHasMember(txn, key, member)
 			if err != nil {
 				return err
 			}
-			countBytes, _ := item.ValueCopy(nil)
-			count = helper.BytesToUint64(countBytes)
+			result[i] = exists
 		}
+		return nil
+	})
+	return result, err
+}
 
-		for _, member := range members {
-			memberStr := string(member)
-			memberKey := s.setKey(key, "member", memberStr)
+// SMembers 实现 Redis SMEMBERS 命令，成员按哈希顺序返回，集合不存在时返回空切片
+func (s *BadgerStore) SMembers(key []byte) ([][]byte, error) {
+	var members [][]byte
+	err := s.setView([][]byte{key}, func(txn *badger.Txn) error {
+		members = [][]byte{}
+		if _, err := s.setPrepare(txn, key); err != nil {
+			return err
+		}
+		return s.setIterate(txn, key, func(member []byte) (bool, error) {
+			members = append(members, member)
+			return true, nil
+		})
+	})
+	return members, err
+}
 
-			// 检查成员是否存在
-			if err := txn.Delete(memberKey); err == nil {
-				count--
-				removed++
+// SRandMember 实现 Redis SRANDMEMBER 命令。count 为正数时返回不重复的成员，超过成员数量时返回全部成员；
+// count 为负数时返回 -count 个可能重复的成员。集合不存在时返回空切片
+func (s *BadgerStore) SRandMember(key []byte, count int64) ([][]byte, error) {
+	var members [][]byte
+	err := s.setView([][]byte{key}, func(txn *badger.Txn) error {
+		members = [][]byte{}
+		size, err := s.setPrepare(txn, key)
+		if err != nil {
+			return err
+		}
+		picked, err := s.setSample(txn, key, size, count)
+		if picked != nil {
+			members = picked
+		}
+		return err
+	})
+	return members, err
+}
+
+// SPop 实现 Redis SPOP 命令，随机删除并返回最多 count 个成员，集合不存在时返回空切片。
+// count 不小于成员数量时删除整个集合
+func (s *BadgerStore) SPop(key []byte, count uint64) ([][]byte, error) {
+	var members [][]byte
+	err := s.setUpdate([][]byte{key}, func(txn *badger.Txn) error {
+		members = [][]byte{}
+		size, err := s.setPrepare(txn, key)
+		if err != nil || size == 0 || count == 0 {
+			return err
+		}
+		if count >= size {
+			err = s.setIterate(txn, key, func(member []byte) (bool, error) {
+				members = append(members, member)
+				return true, nil
+			})
+			if err != nil {
+				return err
+			}
+			if err := deletePrefix(txn, s.setMemberPrefix(key)); err != nil {
+				return err
+			}
+			return s.setSetCount(txn, key, 0)
+		}
+		picked, err := s.setSample(txn, key, size, int64(count))
+		if err != nil {
+			return err
+		}
+		for _, member := range picked {
+			if err := txn.Delete(s.setMemberKey(key, member)); err != nil {
+				return err
+			}
+		}
+		members = picked
+		return s.setSetCount(txn, key, size-uint64(len(picked)))
+	})
+	return members, err
+}
+
+// setOp 是集合运算的种类
+type setOp int
+
+const (
+	setOpUnion setOp = iota
+	setOpInter
+	setOpDiff
+)
+
+// setAlgebra 在事务中计算 keys 的并集、交集或差集，不存在的 key 视为空集合。
+// 交集从成员最少的集合开始遍历，按成员数量从少到多到其他集合中点查；差集遍历第一个集合并在其余集合中点查。
+// limit 大于 0 时取到 limit 个成员后停止
+func (s *BadgerStore) setAlgebra(txn *badger.Txn, op setOp, keys [][]byte, limit uint64) ([][]byte, error) {
+	counts := make([]uint64, len(keys))
+	order := make([]int, len(keys))
+	for i, key := range keys {
+		count, err := s.setPrepare(txn, key)
+		if err != nil {
+			return nil, err
+		}
+		counts[i] = count
+		order[i] = i
+	}
+	members := [][]byte{}
+	collect := func(member []byte) bool {
+		members = append(members, member)
+		return limit == 0 || uint64(len(members)) < limit
+	}
+	// probe 依次在 others 中点查 member，want 为 true 时要求都存在，为 false 时要求都不存在
+	probe := func(member []byte, others []int, want bool) (bool, error) {
+		for _, i := range others {
+			exists, err := s.setHasMember(txn, keys[i], member)
+			if err != nil {
+				return false, err
+			}
+			if exists != want {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	switch op {
+	case setOpUnion:
+		seen := make(map[string]struct{})
+		for _, key := range keys {
+			more := true
+			err := s.setIterate(txn, key, func(member []byte) (bool, error) {
+				if _, ok := seen[string(member)]; ok {
+					return true, nil
+				}
+				seen[string(member)] = struct{}{}
+				more = collect(member)
+				return more, nil
+			})
+			if err != nil || !more {
+				return members, err
+			}
+		}
+	case setOpInter:
+		sort.SliceStable(order, func(a, b int) bool { return counts[order[a]] < counts[order[b]] })
+		if counts[order[0]] == 0 {
+			return members, nil
+		}
+		err := s.setIterate(txn, keys[order[0]], func(member []byte) (bool, error) {
+			ok, err := probe(member, order[1:], true)
+			if err != nil || !ok {
+				return err == nil, err
 			}
+			return collect(member), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	case setOpDiff:
+		if counts[0] == 0 {
+			return members, nil
 		}
+		var others []int
+		for i := 1; i < len(keys); i++ {
+			if counts[i] > 0 {
+				others = append(others, i)
+			}
+		}
+		err := s.setIterate(txn, keys[0], func(member []byte) (bool, error) {
+			ok, err := probe(member, others, false)
+			if err != nil || !ok {
+				return err == nil, err
+			}
+			return collect(member), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return members, nil
+}
 
-		// 更新计数器（即使计数器为0也要保留）
-		return txn.Set(countKey, helper.Uint64ToBytes(count))
+// setCompute 在只读事务中计算集合运算的结果
+func (s *BadgerStore) setCompute(op setOp, keys [][]byte) ([][]byte, error) {
+	var members [][]byte
+	err := s.setView(keys, func(txn *badger.Txn) error {
+		var err error
+		members, err = s.setAlgebra(txn, op, keys, 0)
+		return err
 	})
-	return removed, err
+	return members, err
 }
 
-// SCard 实现 Redis SCARD 命令
-func (s *BadgerStore) SCard(key []byte) (uint64, error) {
+// setStore 在同一个事务中计算集合运算的结果并替换 destination，destination 原来可以是任意类型。
+// 结果为空时删除 destination，返回结果的成员数量。一个事务中写不下时改由 setStoreLarge 分批写入
+func (s *BadgerStore) setStore(destination []byte, op setOp, keys [][]byte) (uint64, error) {
 	var count uint64
-	err := s.db.View(func(txn *badger.Txn) error {
-		countKey := s.setKey(key, "count")
-		item, err := txn.Get(countKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil
+	err := s.setUpdate(append([][]byte{destination}, keys...), func(txn *badger.Txn) error {
+		count = 0
+		members, err := s.setAlgebra(txn, op, keys, 0)
+		if err != nil {
+			return err
+		}
+		// 旧格式的 destination 没有类型标记，要先转换才能被 deleteKey 删除
+		if _, err := s.setGetCount(txn, destination); err != nil {
+			return err
+		}
+		// destination 也可能是参与运算的集合，结果算完之后才能删除
+		if _, err := s.deleteKey(txn, destination); err != nil {
+			return err
+		}
+		for _, member := range members {
+			if err := txn.Set(s.setMemberKey(destination, member), []byte{}); err != nil {
+				return err
+			}
 		}
+		count = uint64(len(members))
+		return s.setSetCount(txn, destination, count)
+	})
+	if errors.Is(err, badger.ErrTxnTooBig) {
+		return s.setStoreLarge(destination, op, keys)
+	}
+	return count, err
+}
+
+// setStoreLarge 处理结果或 destination 原有的成员超出一个事务容量的集合运算。结果在一个只读快照中计算，
+// destination 同时是参与运算的集合时读到的也是运算前的成员；成员用 WriteBatch 分批写入，不在结果中的旧成员分批删除，
+// 最后在一个事务中写入计数器和类型标记。destination 原来是其他类型时，此前读取它得到的仍是原来的值；
+// 原来就是集合时，写入期间读到的成员是新旧成员的混合。分批写入与同时修改 destination 的命令之间没有隔离
+func (s *BadgerStore) setStoreLarge(destination []byte, op setOp, keys [][]byte) (uint64, error) {
+	snap := s.db.NewTransaction(false)
+	defer snap.Discard()
+	members, err := s.setAlgebra(snap, op, keys, 0)
+	if err != nil {
+		return 0, err
+	}
+	result := make(map[string]struct{}, len(members))
+	for _, member := range members {
+		result[string(member)] = struct{}{}
+	}
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	// destination 原有的成员不在结果中时要删除
+	err = s.setIterate(snap, destination, func(member []byte) (bool, error) {
+		if _, ok := result[string(member)]; ok {
+			return true, nil
+		}
+		return true, wb.Delete(s.setMemberKey(destination, member))
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, member := range members {
+		if err := wb.Set(s.setMemberKey(destination, member), []byte{}); err != nil {
+			return 0, err
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		return 0, err
+	}
+
+	count := uint64(len(members))
+	err = s.update(func(txn *badger.Txn) error {
+		keyType, err := s.keyType(txn, destination)
 		if err != nil {
 			return err
 		}
-		countBytes, _ := item.ValueCopy(nil)
-		count = helper.BytesToUint64(countBytes)
-		return nil
+		// 集合的成员已经改写完，只需更新计数器；其他类型的旧数据在这里删除
+		if keyType != "" && keyType != KeyTypeSet {
+			if _, err := s.deleteKey(txn, destination); err != nil {
+				return err
+			}
+		}
+		return s.setSetCount(txn, destination, count)
 	})
 	return count, err
 }
 
-// SIsMember 实现 Redis SISMEMBER 命令
-func (s *BadgerStore) SIsMember(key []byte, member []byte) (bool, error) {
-	exists := false
-	err := s.db.View(func(txn *badger.Txn) error {
-		memberKey := s.setKey(key, "member", string(member))
-		_, err := txn.Get(memberKey)
-		if err == nil {
-			exists = true
+// SInter 实现 Redis SINTER 命令
+func (s *BadgerStore) SInter(keys ...[]byte) ([][]byte, error) {
+	return s.setCompute(setOpInter, keys)
+}
+
+// SUnion 实现 Redis SUNION 命令
+func (s *BadgerStore) SUnion(keys ...[]byte) ([][]byte, error) {
+	return s.setCompute(setOpUnion, keys)
+}
+
+// SDiff 实现 Redis SDIFF 命令，返回第一个集合中不在其余集合中的成员
+func (s *BadgerStore) SDiff(keys ...[]byte) ([][]byte, error) {
+	return s.setCompute(setOpDiff, keys)
+}
+
+// SInterStore 实现 Redis SINTERSTORE 命令，返回结果的成员数量
+func (s *BadgerStore) SInterStore(destination []byte, keys ...[]byte) (uint64, error) {
+	return s.setStore(destination, setOpInter, keys)
+}
+
+// SUnionStore 实现 Redis SUNIONSTORE 命令，返回结果的成员数量
+func (s *BadgerStore) SUnionStore(destination []byte, keys ...[]byte) (uint64, error) {
+	return s.setStore(destination, setOpUnion, keys)
+}
+
+// SDiffStore 实现 Redis SDIFFSTORE 命令，返回结果的成员数量
+func (s *BadgerStore) SDiffStore(destination []byte, keys ...[]byte) (uint64, error) {
+	return s.setStore(destination, setOpDiff, keys)
+}
+
+// SInterCard 实现 Redis SINTERCARD 命令，返回交集的成员数量，limit 大于 0 时数到 limit 为止
+func (s *BadgerStore) SInterCard(keys [][]byte, limit uint64) (uint64, error) {
+	var count uint64
+	err := s.setView(keys, func(txn *badger.Txn) error {
+		members, err := s.setAlgebra(txn, setOpInter, keys, limit)
+		count = uint64(len(members))
+		return err
+	})
+	return count, err
+}
+
+// SScan 实现 Redis SSCAN 命令，从游标 cursor 继续遍历，至少检查 count 个成员，
+// 只返回匹配 match 的成员（match 为 nil 时不过滤）。游标是下一个成员的哈希值，遍历结束时返回的游标为 0
+func (s *BadgerStore) SScan(key []byte, cursor uint64, match []byte, count int) (uint64, [][]byte, error) {
+	if count < 1 {
+		count = 1
+	}
+	var next uint64
+	var members [][]byte
+	err := s.setView([][]byte{key}, func(txn *badger.Txn) error {
+		next, members = 0, nil
+		if _, err := s.setPrepare(txn, key); err != nil {
+			return err
 		}
+		var err error
+		next, err = scanRecords(txn, s.setMemberPrefix(key), cursor, count, false, func(_ *badger.Item, member []byte) error {
+			if match == nil || helper.StringMatch(match, member, false) {
+				members = append(members, member)
+			}
+			return nil
+		})
 		return err
 	})
-	return exists, err
+	if err != nil {
+		return 0, nil, err
+	}
+	return next, members, nil
+}
+
+// SMove 实现 Redis SMOVE 命令，在同一个事务中把 member 从 source 移到 destination 并更新两边的计数，
+// member 不在 source 中时返回 false
+func (s *BadgerStore) SMove(source, destination, member []byte) (bool, error) {
+	moved := false
+	err := s.setUpdate([][]byte{source, destination}, func(txn *badger.Txn) error {
+		moved = false
+		srcCount, err := s.setPrepare(txn, source)
+		if err != nil {
+			return err
+		}
+		dstCount, err := s.setPrepare(txn, destination)
+		if err != nil {
+			return err
+		}
+		if moved, err = s.setHasMember(txn, source, member); err != nil || !moved {
+			return err
+		}
+		if bytes.Equal(source, destination) {
+			return nil
+		}
+
+		if err := txn.Delete(s.setMemberKey(source, member)); err != nil {
+			return err
+		}
+		if err := s.setSetCount(txn, source, srcCount-1); err != nil {
+			return err
+		}
+		exists, err := s.setHasMember(txn, destination, member)
+		if err != nil || exists {
+			return err
+		}
+		if err := txn.Set(s.setMemberKey(destination, member), []byte{}); err != nil {
+			return err
+		}
+		return s.setSetCount(txn, destination, dstCount+1)
+	})
+	return moved, err
 }
@@ -1,8 +1,15 @@
 package store
 
 import (
+	"PumbaaDB/helper"
+	"bytes"
 	"fmt"
+	"sort"
+	"strings"
 	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/zeebo/assert"
 )
 
 func TestSetAuto(t *testing.T) {
@@ -28,3 +35,341 @@ func TestSetAuto(t *testing.T) {
 	fmt.Println(removed) // 1
 
 }
+
+// sortedMembers 按字节序排列成员，集合本身按成员的哈希值遍历
+func sortedMembers(members [][]byte) [][]byte {
+	sort.Slice(members, func(i, j int) bool { return bytes.Compare(members[i], members[j]) < 0 })
+	return members
+}
+
+func TestSetLegacyMigration(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("old")
+
+	// 按旧格式写入成员和计数器，旧格式没有类型标记
+	writeLegacy := func(key []byte, members ...string) {
+		err := store.db.Update(func(txn *badger.Txn) error {
+			for _, member := range members {
+				_ = txn.Set(store.setLegacyKey(key, "member", member), []byte{})
+			}
+			return txn.Set(store.setLegacyKey(key, "count"), helper.Uint64ToBytes(uint64(len(members))))
+		})
+		assert.NoError(t, err)
+	}
+	writeLegacy(key, "a", "b", "c", "x:y")
+	// 名字以 old:member: 开头的旧格式集合的键也以 SET:old:member: 开头，不是 old 的成员
+	writeLegacy([]byte("old:member:x"), "y")
+	writeLegacy([]byte("other"), "b", "d")
+
+	// 只读命令也会触发转换
+	count, err := store.SCard(key)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), count)
+	members, _ := store.SMembers(key)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("x:y")}, sortedMembers(members))
+	members, _ = store.SMembers([]byte("old:member:x"))
+	assert.Equal(t, [][]byte{[]byte("y")}, members)
+
+	// 旧格式的键全部被删除
+	assert.NoError(t, store.db.View(func(txn *badger.Txn) error {
+		prefix := store.setLegacyKey(key)
+		iter := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iter.Close()
+		iter.Seek(prefix)
+		assert.False(t, iter.ValidForPrefix(prefix))
+		return nil
+	}))
+
+	// 参与运算的旧格式集合也会被转换
+	members, err = store.SInter(key, []byte("other"))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("b")}, members)
+	added, _ := store.SAdd([]byte("other"), []byte("e"))
+	assert.Equal(t, 1, added)
+	count, _ = store.SCard([]byte("other"))
+	assert.Equal(t, uint64(3), count)
+
+	// 转换后的集合维护类型标记
+	_, err = store.HSet(key, [][]byte{[]byte("f"), []byte("v")})
+	assert.Equal(t, ErrWrongType, err)
+}
+
+func TestSetRead(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("tags")
+
+	members, err := store.SMembers(key)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(members))
+	exists, err := store.SIsMember(key, []byte("a"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	added, _ := store.SAdd(key, []byte("c"), []byte("a"), []byte("b"), []byte("a"))
+	assert.Equal(t, 3, added)
+	members, _ = store.SMembers(key)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("c")}, sortedMembers(members))
+	result, _ := store.SMIsMember(key, []byte("a"), []byte("x"), []byte("c"))
+	assert.Equal(t, []bool{true, false, true}, result)
+
+	// 名字以 tags: 开头的集合与 tags 的记录互不干扰
+	_, _ = store.SAdd([]byte("tags:member"), []byte("x"))
+	members, _ = store.SMembers(key)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("c")}, sortedMembers(members))
+	_, _ = store.SPop([]byte("tags:member"), 10)
+
+	// 删除不存在的成员不影响计数
+	removed, _ := store.SRem(key, []byte("x"), []byte("a"), []byte("a"))
+	assert.Equal(t, 1, removed)
+	count, _ := store.SCard(key)
+	assert.Equal(t, uint64(2), count)
+	removed, _ = store.SRem(key, []byte("b"), []byte("c"))
+	assert.Equal(t, 2, removed)
+	assert.NoError(t, store.db.View(func(txn *badger.Txn) error {
+		keyType, err := store.keyType(txn, key)
+		assert.Equal(t, "", keyType)
+		return err
+	}))
+
+	_, _ = store.RPush([]byte("list"), []byte("a"))
+	_, err = store.SAdd([]byte("list"), []byte("a"))
+	assert.Equal(t, ErrWrongType, err)
+	_, err = store.SMembers([]byte("list"))
+	assert.Equal(t, ErrWrongType, err)
+}
+
+func TestSetRandom(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("pool")
+	for i := 0; i < 20; i++ {
+		_, _ = store.SAdd(key, []byte(fmt.Sprintf("m%02d", i)))
+	}
+
+	members, _ := store.SRandMember(key, 5)
+	assert.Equal(t, 5, len(members))
+	seen := map[string]bool{}
+	for _, m := range members {
+		assert.False(t, seen[string(m)])
+		seen[string(m)] = true
+	}
+	members, _ = store.SRandMember(key, 15)
+	assert.Equal(t, 15, len(members))
+	members, _ = store.SRandMember(key, 100)
+	assert.Equal(t, 20, len(members))
+	members, _ = store.SRandMember(key, -50)
+	assert.Equal(t, 50, len(members))
+	members, _ = store.SRandMember([]byte("missing"), -3)
+	assert.Equal(t, 0, len(members))
+	// 负数 count 的绝对值有上限，不会按客户端给出的长度分配内存
+	_, err := store.SRandMember(key, -9223372036854775807)
+	assert.Equal(t, ErrRandomCountRange, err)
+	// 每个成员都有机会被选中
+	picked := make(map[string]bool)
+	for i := 0; i < 1000 && len(picked) < 20; i++ {
+		members, _ = store.SRandMember(key, 1)
+		picked[string(members[0])] = true
+	}
+	assert.Equal(t, 20, len(picked))
+
+	popped, _ := store.SPop(key, 3)
+	assert.Equal(t, 3, len(popped))
+	count, _ := store.SCard(key)
+	assert.Equal(t, uint64(17), count)
+	for _, m := range popped {
+		exists, _ := store.SIsMember(key, m)
+		assert.False(t, exists)
+	}
+	popped, _ = store.SPop(key, 100)
+	assert.Equal(t, 17, len(popped))
+	count, _ = store.SCard(key)
+	assert.Equal(t, uint64(0), count)
+	popped, err = store.SPop(key, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(popped))
+}
+
+func TestSetAlgebra(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	a, b, c := []byte("a"), []byte("b"), []byte("c")
+	_, _ = store.SAdd(a, []byte("1"), []byte("2"), []byte("3"), []byte("4"))
+	_, _ = store.SAdd(b, []byte("2"), []byte("3"), []byte("5"))
+	_, _ = store.SAdd(c, []byte("3"), []byte("4"))
+	str := func(members [][]byte) string {
+		sorted := make([]string, len(members))
+		for i, m := range members {
+			sorted[i] = string(m)
+		}
+		sort.Strings(sorted)
+		return strings.Join(sorted, "")
+	}
+
+	members, err := store.SInter(a, b, c)
+	assert.NoError(t, err)
+	assert.Equal(t, "3", str(members))
+	members, _ = store.SInter(a, b, []byte("missing"))
+	assert.Equal(t, "", str(members))
+	members, _ = store.SUnion(a, b, c)
+	assert.Equal(t, "12345", str(members))
+	members, _ = store.SDiff(a, b, []byte("missing"))
+	assert.Equal(t, "14", str(members))
+	members, _ = store.SDiff([]byte("missing"), a)
+	assert.Equal(t, "", str(members))
+
+	n, _ := store.SInterCard([][]byte{a, b}, 0)
+	assert.Equal(t, uint64(2), n)
+	n, _ = store.SInterCard([][]byte{a, b}, 1)
+	assert.Equal(t, uint64(1), n)
+
+	// destination 可以是参与运算的集合，也可以是其他类型
+	n, _ = store.SUnionStore(c, c, b)
+	assert.Equal(t, uint64(4), n)
+	members, _ = store.SMembers(c)
+	assert.Equal(t, "2345", str(members))
+	_, _ = store.RPush([]byte("dst"), []byte("x"))
+	n, err = store.SInterStore([]byte("dst"), a, c)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), n)
+	count, _ := store.SCard([]byte("dst"))
+	assert.Equal(t, uint64(3), count)
+
+	// 结果为空时删除 destination
+	n, _ = store.SDiffStore([]byte("dst"), b, c)
+	assert.Equal(t, uint64(0), n)
+	assert.NoError(t, store.db.View(func(txn *badger.Txn) error {
+		keyType, err := store.keyType(txn, []byte("dst"))
+		assert.Equal(t, "", keyType)
+		return err
+	}))
+
+	_, err = store.SInter(a, []byte("dst"), []byte("missing"))
+	assert.NoError(t, err)
+	_, _ = store.RPush([]byte("list"), []byte("x"))
+	_, err = store.SUnion(a, []byte("list"))
+	assert.Equal(t, ErrWrongType, err)
+}
+
+// 结果或 destination 原有的成员超出一个事务的容量时分批写入
+func TestSetStoreLarge(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	const size = 200_000
+	big, dst := []byte("big"), []byte("dst")
+	members := make([][]byte, 0, 10_000)
+	for i := 0; i < size; i++ {
+		members = append(members, []byte(fmt.Sprintf("m%d", i)))
+		if len(members) == cap(members) {
+			_, err := store.SAdd(big, members...)
+			assert.NoError(t, err)
+			members = members[:0]
+		}
+	}
+	_, _ = store.SAdd(dst, []byte("stale"), []byte("m0"))
+	_, _ = store.SAdd([]byte("small"), []byte("m0"))
+
+	n, err := store.SUnionStore(dst, big)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(size), n)
+	count, _ := store.SCard(dst)
+	assert.Equal(t, uint64(size), count)
+	exists, _ := store.SIsMember(dst, []byte("stale"))
+	assert.False(t, exists)
+	exists, _ = store.SIsMember(dst, []byte(fmt.Sprintf("m%d", size-1)))
+	assert.True(t, exists)
+
+	// destination 同时是参与运算的集合
+	n, err = store.SDiffStore(dst, dst, []byte("small"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(size-1), n)
+	exists, _ = store.SIsMember(dst, []byte("m0"))
+	assert.False(t, exists)
+
+	// destination 原来是列表
+	_, _ = store.RPush([]byte("list"), []byte("x"))
+	n, err = store.SInterStore([]byte("list"), big, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(size-1), n)
+	count, err = store.SCard([]byte("list"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(size-1), count)
+
+	// 结果为空时删除原有的全部成员
+	n, err = store.SDiffStore(dst, big, big)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), n)
+	all, _ := store.SMembers(dst)
+	assert.Equal(t, 0, len(all))
+	assert.NoError(t, store.db.View(func(txn *badger.Txn) error {
+		keyType, err := store.keyType(txn, dst)
+		assert.Equal(t, "", keyType)
+		return err
+	}))
+}
+
+func TestSScanAndMove(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewBadgerStore(dir)
+	defer func() { store.Close() }()
+	key := []byte("big")
+	for i := 0; i < 50; i++ {
+		_, _ = store.SAdd(key, []byte(fmt.Sprintf("m:%02d", i)))
+	}
+
+	// 游标在重启后仍然有效
+	seen := make(map[string]bool)
+	cursor, calls := uint64(0), 0
+	for {
+		next, members, err := store.SScan(key, cursor, nil, 8)
+		assert.NoError(t, err)
+		assert.True(t, len(members) <= 8)
+		for _, m := range members {
+			assert.False(t, seen[string(m)])
+			seen[string(m)] = true
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+		if calls++; calls == 3 {
+			store.Close()
+			store, _ = NewBadgerStore(dir)
+		}
+	}
+	assert.Equal(t, 50, len(seen))
+	_, members, _ := store.SScan(key, 0, []byte("m:1?"), 100)
+	assert.Equal(t, 10, len(members))
+
+	// 移动后两边的计数都要更新
+	dst := []byte("dst")
+	moved, err := store.SMove(key, dst, []byte("m:01"))
+	assert.NoError(t, err)
+	assert.True(t, moved)
+	moved, _ = store.SMove(key, dst, []byte("m:01"))
+	assert.False(t, moved)
+	_, _ = store.SAdd(dst, []byte("m:02"))
+	moved, _ = store.SMove(key, dst, []byte("m:02"))
+	assert.True(t, moved)
+	count, _ := store.SCard(key)
+	assert.Equal(t, uint64(48), count)
+	count, _ = store.SCard(dst)
+	assert.Equal(t, uint64(2), count)
+	moved, _ = store.SMove(dst, dst, []byte("m:02"))
+	assert.True(t, moved)
+
+	// 移走最后一个成员后 source 不再存在
+	_, _ = store.SMove(dst, key, []byte("m:01"))
+	_, _ = store.SMove(dst, key, []byte("m:02"))
+	assert.NoError(t, store.db.View(func(txn *badger.Txn) error {
+		keyType, err := store.keyType(txn, dst)
+		assert.Equal(t, "", keyType)
+		return err
+	}))
+
+	_, _ = store.RPush([]byte("list"), []byte("x"))
+	_, err = store.SMove(key, []byte("list"), []byte("m:03"))
+	assert.Equal(t, ErrWrongType, err)
+	exists, _ := store.SIsMember(key, []byte("m:03"))
+	assert.True(t, exists)
+}
@@ -1,49 +1,828 @@
 package store
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
 )
 
+// 字符串值在 badger 中的 UserMeta 标志位
+const (
+	// stringMetaExpire 表示值的前 8 字节是大端序的毫秒级过期时间戳
+	stringMetaExpire byte = 1 << iota
+	// stringMetaFragmented 表示值被切分为定长分片存储，STRING:key 中只保存 8 字节的总长度，
+	// 第 i 个分片保存在 STRFRAG:key:<大端序 i> 下。缺失的分片和分片末尾缺失的部分都视为 0
+	stringMetaFragmented
+)
+
+const (
+	// stringFragmentSize 是分片的大小，APPEND、SETRANGE 和 SETBIT 只改写涉及到的分片
+	stringFragmentSize = 4096
+	// stringMaxConvertSize 是内联值转换为分片存储的上限，更大的内联值转换时要在一个事务内
+	// 写入过多分片，这种情况下继续整体改写
+	stringMaxConvertSize = 1024 * 1024
+	// maxStringSize 是字符串的最大长度，与 Redis 默认的 proto-max-bulk-len 一致
+	maxStringSize = 512 * 1024 * 1024
+)
+
+// stringEntry 是从 badger 中解码出的字符串元信息
+type stringEntry struct {
+	value      []byte // 内联存储的值，分片存储时为 nil
+	expireAt   int64  // 毫秒时间戳，0 表示不过期
+	fragmented bool
+	length     int64
+}
+
+func (e *stringEntry) expired() bool {
+	return e.expireAt != 0 && e.expireAt <= nowMilli()
+}
+
+// badgerExpiresAt 把毫秒时间戳向上取整为 badger 的秒级 TTL，由 badger 负责回收过期数据
+func badgerExpiresAt(expireAt int64) uint64 {
+	if expireAt <= 0 {
+		return 0
+	}
+	return uint64((expireAt + 999) / 1000)
+}
+
+// SetOption 是 SET 命令的可选参数
+type SetOption struct {
+	NX       bool  // 只在 key 不存在时设置
+	XX       bool  // 只在 key 已存在时设置
+	Get      bool  // 返回旧值，旧值不是字符串时返回 ErrWrongType
+	ExpireAt int64 // 毫秒级过期时间戳，0 表示不过期
+	KeepTTL  bool  // 保留 key 原有的过期时间
+}
+
 // stringKey 方法用于生成存储在 Badger 数据库中的键
 func (s *BadgerStore) stringKey(key []byte) []byte {
 	return []byte(fmt.Sprintf("%s:%s", KeyTypeString, string(key)))
 }
 
+// stringFragmentKey 生成第 index 个分片的键，分片序号为定长的大端序，按序号有序
+func (s *BadgerStore) stringFragmentKey(key []byte, index int64) []byte {
+	bKey := keyBadgetGet(prefixKeyStringFragment, []byte(string(key)+":"))
+	return binary.BigEndian.AppendUint64(bKey, uint64(index))
+}
+
+// stringReadRaw 读取字符串元信息，已过期的也会返回，用于清理残留的分片
+func (s *BadgerStore) stringReadRaw(txn *badger.Txn, key []byte) (*stringEntry, error) {
+	item, err := txn.Get(s.stringKey(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	entry := &stringEntry{}
+	meta := item.UserMeta()
+	if meta&stringMetaExpire != 0 {
+		if len(val) < 8 {
+			return nil, fmt.Errorf("stringRead: corrupted value of key %q", key)
+		}
+		entry.expireAt = int64(binary.BigEndian.Uint64(val))
+		val = val[8:]
+	}
+	if meta&stringMetaFragmented != 0 {
+		if len(val) != 8 {
+			return nil, fmt.Errorf("stringRead: corrupted value of key %q", key)
+		}
+		entry.fragmented = true
+		entry.length = int64(binary.BigEndian.Uint64(val))
+	} else {
+		// badger 对空值返回 nil，空字符串需要与不存在区分开
+		if val == nil {
+			val = []byte{}
+		}
+		entry.value = val
+		entry.length = int64(len(val))
+	}
+	return entry, nil
+}
+
+// stringRead 在事务中读取字符串元信息，key 不存在或已过期时返回 nil
+func (s *BadgerStore) stringRead(txn *badger.Txn, key []byte) (*stringEntry, error) {
+	entry, err := s.stringReadRaw(txn, key)
+	if err != nil || entry == nil || entry.expired() {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// stringLookup 读取字符串并检查类型，key 不存在时返回 nil，
+// key 是其他类型时返回 ErrWrongType
+func (s *BadgerStore) stringLookup(txn *badger.Txn, key []byte) (*stringEntry, error) {
+	if _, err := s.checkKeyType(txn, key, KeyTypeString); err != nil {
+		return nil, err
+	}
+	return s.stringRead(txn, key)
+}
+
+// stringLoad 返回字符串的完整值
+func (s *BadgerStore) stringLoad(txn *badger.Txn, key []byte, entry *stringEntry) ([]byte, error) {
+	if !entry.fragmented {
+		return entry.value, nil
+	}
+	return s.stringLoadRange(txn, key, entry, 0, entry.length)
+}
+
+// stringLoadRange 返回字符串 [start, end) 区间的内容，调用方保证区间在值的范围内。
+// 分片存储时只读取区间涉及的分片
+func (s *BadgerStore) stringLoadRange(txn *badger.Txn, key []byte, entry *stringEntry, start, end int64) ([]byte, error) {
+	if !entry.fragmented {
+		return entry.value[start:end], nil
+	}
+	buf := make([]byte, end-start)
+	if end <= start {
+		return buf, nil
+	}
+	err := s.stringIterFragments(txn, key, start/stringFragmentSize, (end-1)/stringFragmentSize, true,
+		func(index int64, data []byte) error {
+			fragStart := index * stringFragmentSize
+			for i, b := range data {
+				if pos := fragStart + int64(i); pos >= start && pos < end {
+					buf[pos-start] = b
+				}
+			}
+			return nil
+		})
+	return buf, err
+}
+
+// stringIterFragments 按序号顺序遍历 [first, last] 范围内实际存在的分片
+func (s *BadgerStore) stringIterFragments(txn *badger.Txn, key []byte, first, last int64, withValues bool,
+	fn func(index int64, data []byte) error) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = withValues
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+
+	startKey := s.stringFragmentKey(key, first)
+	endKey := s.stringFragmentKey(key, last)
+	keyLen := len(startKey)
+	for iter.Seek(startKey); iter.Valid(); iter.Next() {
+		item := iter.Item()
+		k := item.Key()
+		if bytes.Compare(k, endKey) > 0 {
+			break
+		}
+		// 前缀相同但长度不同的键属于别的 key（如 "a" 与 "a:b"），跳过
+		if len(k) != keyLen {
+			continue
+		}
+		index := int64(binary.BigEndian.Uint64(k[keyLen-8:]))
+		var data []byte
+		if withValues {
+			var err error
+			if data, err = item.ValueCopy(nil); err != nil {
+				return err
+			}
+		}
+		if err := fn(index, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stringScanRange 按顺序遍历 [start, end) 区间，依次回调连续的片段，data 为 nil 表示长度为 length 的片段全部为 0。
+// 分片存储时不存在的分片不需要读取，稀疏的大字符串可以直接跳过
+func (s *BadgerStore) stringScanRange(txn *badger.Txn, key []byte, entry *stringEntry, start, end int64,
+	fn func(offset, length int64, data []byte) error) error {
+	if start >= end {
+		return nil
+	}
+	if !entry.fragmented {
+		return fn(start, end-start, entry.value[start:end])
+	}
+	pos := start
+	err := s.stringIterFragments(txn, key, start/stringFragmentSize, (end-1)/stringFragmentSize, true,
+		func(index int64, data []byte) error {
+			fragStart := index * stringFragmentSize
+			from := max(start, fragStart)
+			to := min(end, fragStart+int64(len(data)))
+			if from >= to {
+				return nil
+			}
+			if from > pos {
+				if err := fn(pos, from-pos, nil); err != nil {
+					return err
+				}
+			}
+			pos = to
+			return fn(from, to-from, data[from-fragStart:to-fragStart])
+		})
+	if err != nil || pos >= end {
+		return err
+	}
+	return fn(pos, end-pos, nil)
+}
+
+// stringLastFragment 返回长度为 length 的字符串最后一个分片的序号
+func stringLastFragment(length int64) int64 {
+	if length == 0 {
+		return 0
+	}
+	return (length - 1) / stringFragmentSize
+}
+
+// stringPutMeta 写入类型标记和 STRING:key 记录，两者与分片使用相同的 badger TTL
+func (s *BadgerStore) stringPutMeta(txn *badger.Txn, key []byte, entry *stringEntry) error {
+	var meta byte
+	var val []byte
+	if entry.expireAt > 0 {
+		meta |= stringMetaExpire
+		val = binary.BigEndian.AppendUint64(val, uint64(entry.expireAt))
+	}
+	if entry.fragmented {
+		meta |= stringMetaFragmented
+		val = binary.BigEndian.AppendUint64(val, uint64(entry.length))
+	} else if meta == 0 {
+		val = entry.value
+	} else {
+		val = append(val, entry.value...)
+	}
+
+	expiresAt := badgerExpiresAt(entry.expireAt)
+	typeEntry := badger.NewEntry(TypeKeyGet(string(key)), []byte(KeyTypeString))
+	typeEntry.ExpiresAt = expiresAt
+	if err := txn.SetEntry(typeEntry); err != nil {
+		return err
+	}
+	valueEntry := badger.NewEntry(s.stringKey(key), val).WithMeta(meta)
+	valueEntry.ExpiresAt = expiresAt
+	return txn.SetEntry(valueEntry)
+}
+
+// stringPutFragment 写入一个分片
+func (s *BadgerStore) stringPutFragment(txn *badger.Txn, key []byte, index int64, data []byte, expireAt int64) error {
+	e := badger.NewEntry(s.stringFragmentKey(key, index), data)
+	e.ExpiresAt = badgerExpiresAt(expireAt)
+	return txn.SetEntry(e)
+}
+
+// stringDeleteFragments 删除 key 残留的全部分片，包括已过期但 badger 尚未回收的
+func (s *BadgerStore) stringDeleteFragments(txn *badger.Txn, key []byte) error {
+	raw, err := s.stringReadRaw(txn, key)
+	if err != nil || raw == nil || !raw.fragmented {
+		return err
+	}
+	var keys [][]byte
+	err = s.stringIterFragments(txn, key, 0, stringLastFragment(raw.length), false, func(index int64, _ []byte) error {
+		keys = append(keys, s.stringFragmentKey(key, index))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := txn.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stringWrite 在事务中以内联方式写入整个字符串值和类型标记，并清理旧值的分片。
+// key 原有的其他类型数据需由调用方先删除
+func (s *BadgerStore) stringWrite(txn *badger.Txn, key, value []byte, expireAt int64) error {
+	if err := s.stringDeleteFragments(txn, key); err != nil {
+		return err
+	}
+	return s.stringPutMeta(txn, key, &stringEntry{value: value, expireAt: expireAt, length: int64(len(value))})
+}
+
+// stringDelete 在事务中删除字符串值、分片和类型标记
+func (s *BadgerStore) stringDelete(txn *badger.Txn, key []byte) error {
+	if err := s.stringDeleteFragments(txn, key); err != nil {
+		return err
+	}
+	if err := txn.Delete(TypeKeyGet(string(key))); err != nil {
+		return err
+	}
+	return txn.Delete(s.stringKey(key))
+}
+
+// stringSetExpire 修改字符串的过期时间。分片存储时每个分片的 badger TTL 都要随之改写
+func (s *BadgerStore) stringSetExpire(txn *badger.Txn, key []byte, entry *stringEntry, expireAt int64) error {
+	if entry.fragmented {
+		type fragment struct {
+			index int64
+			data  []byte
+		}
+		var fragments []fragment
+		err := s.stringIterFragments(txn, key, 0, stringLastFragment(entry.length), true, func(index int64, data []byte) error {
+			fragments = append(fragments, fragment{index: index, data: data})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, f := range fragments {
+			if err := s.stringPutFragment(txn, key, f.index, f.data, expireAt); err != nil {
+				return err
+			}
+		}
+	}
+	updated := *entry
+	updated.expireAt = expireAt
+	return s.stringPutMeta(txn, key, &updated)
+}
+
+// stringWriteRange 把 data 写入字符串的 offset 处，超出原长度的部分以 0 填充，返回新的长度。
+// entry 为 nil 表示 key 不存在。新值不超过一个分片时内联存储，否则转换为分片存储并只改写涉及的分片
+func (s *BadgerStore) stringWriteRange(txn *badger.Txn, key []byte, entry *stringEntry, offset int64, data []byte) (int64, error) {
+	if entry == nil {
+		if err := s.stringDeleteFragments(txn, key); err != nil {
+			return 0, err
+		}
+		entry = &stringEntry{}
+	}
+	newLen := max(entry.length, offset+int64(len(data)))
+	if newLen > maxStringSize {
+		return 0, ErrStringTooLong
+	}
+
+	if !entry.fragmented {
+		if newLen <= stringFragmentSize || entry.length > stringMaxConvertSize {
+			value := make([]byte, newLen)
+			copy(value, entry.value)
+			copy(value[offset:], data)
+			return newLen, s.stringPutMeta(txn, key, &stringEntry{value: value, expireAt: entry.expireAt, length: newLen})
+		}
+		// 转换为分片存储，先把原来的内联值切分为分片
+		for index := int64(0); index*stringFragmentSize < entry.length; index++ {
+			end := min((index+1)*stringFragmentSize, entry.length)
+			fragment := entry.value[index*stringFragmentSize : end]
+			if err := s.stringPutFragment(txn, key, index, fragment, entry.expireAt); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := s.stringPatchFragments(txn, key, offset, data, entry.expireAt); err != nil {
+		return 0, err
+	}
+	return newLen, s.stringPutMeta(txn, key, &stringEntry{fragmented: true, expireAt: entry.expireAt, length: newLen})
+}
+
+// stringPatchFragments 把 data 写入 offset 处涉及的分片，每个分片读出后局部修改再写回
+func (s *BadgerStore) stringPatchFragments(txn *badger.Txn, key []byte, offset int64, data []byte, expireAt int64) error {
+	for len(data) > 0 {
+		index := offset / stringFragmentSize
+		inner := offset % stringFragmentSize
+		n := min(int64(len(data)), stringFragmentSize-inner)
+
+		fragment, err := s.stringReadFragment(txn, key, index)
+		if err != nil {
+			return err
+		}
+		if int64(len(fragment)) < inner+n {
+			fragment = append(fragment, make([]byte, inner+n-int64(len(fragment)))...)
+		}
+		copy(fragment[inner:], data[:n])
+		if err := s.stringPutFragment(txn, key, index, fragment, expireAt); err != nil {
+			return err
+		}
+		offset += n
+		data = data[n:]
+	}
+	return nil
+}
+
+// stringReadFragment 读取单个分片，分片不存在时返回 nil
+func (s *BadgerStore) stringReadFragment(txn *badger.Txn, key []byte, index int64) ([]byte, error) {
+	item, err := txn.Get(s.stringFragmentKey(key, index))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
 // Set 实现 Redis SET 命令
 func (s *BadgerStore) Set(key []byte, value []byte) error {
-	return s.db.Update(func(txn *badger.Txn) error {
-		strKey := s.stringKey(key)
-		return txn.Set(strKey, value)
-	})
+	_, _, err := s.SetWithOption(key, value, SetOption{})
+	return err
 }
 
 // SetWithTTL 字符串操作
 func (s *BadgerStore) SetWithTTL(key, value []byte, ttl time.Duration) error {
-	return s.db.Update(func(txn *badger.Txn) error {
-		e := badger.NewEntry(key, value).WithTTL(ttl)
-		return txn.SetEntry(e)
+	_, _, err := s.SetWithOption(key, value, SetOption{ExpireAt: time.Now().Add(ttl).UnixMilli()})
+	return err
+}
+
+// SetWithOption 实现带 NX/XX/GET/过期时间参数的 SET 命令，整个过程在一个事务内完成。
+// 返回 key 原来的字符串值（仅 opt.Get 时读取）和是否实际写入
+func (s *BadgerStore) SetWithOption(key, value []byte, opt SetOption) (old []byte, written bool, err error) {
+	err = s.update(func(txn *badger.Txn) error {
+		old, written = nil, false
+		keyType, err := s.keyType(txn, key)
+		if err != nil {
+			return err
+		}
+		var current *stringEntry
+		if keyType == KeyTypeString {
+			if current, err = s.stringRead(txn, key); err != nil {
+				return err
+			}
+		} else if keyType != "" && opt.Get {
+			return ErrWrongType
+		}
+		if current != nil && opt.Get {
+			if old, err = s.stringLoad(txn, key, current); err != nil {
+				return err
+			}
+		}
+
+		exists := keyType != ""
+		if (opt.NX && exists) || (opt.XX && !exists) {
+			return nil
+		}
+
+		expireAt := opt.ExpireAt
+		if opt.KeepTTL && current != nil {
+			expireAt = current.expireAt
+		}
+		// SET 会覆盖任意类型的旧值
+		if exists && keyType != KeyTypeString {
+			if _, err := s.deleteKey(txn, key); err != nil {
+				return err
+			}
+		}
+		if err := s.stringWrite(txn, key, value, expireAt); err != nil {
+			return err
+		}
+		written = true
+		return nil
 	})
+	if err != nil {
+		return nil, false, err
+	}
+	return old, written, nil
+}
+
+// SetNX 实现 Redis SETNX 命令，返回是否写入
+func (s *BadgerStore) SetNX(key, value []byte) (bool, error) {
+	_, written, err := s.SetWithOption(key, value, SetOption{NX: true})
+	return written, err
+}
+
+// GetSet 实现 Redis GETSET 命令，写入新值并清除过期时间，返回旧值
+func (s *BadgerStore) GetSet(key, value []byte) ([]byte, error) {
+	old, _, err := s.SetWithOption(key, value, SetOption{Get: true})
+	return old, err
 }
 
 // Get 实现 Redis GET 命令
 func (s *BadgerStore) Get(key []byte) ([]byte, error) {
 	var val []byte
 	err := s.db.View(func(txn *badger.Txn) error {
-		strKey := s.stringKey(key)
-		item, err := txn.Get(strKey)
+		entry, err := s.stringLookup(txn, key)
+		if err != nil || entry == nil {
+			return err // key 不存在时返回 nil
+		}
+		val, err = s.stringLoad(txn, key, entry)
+		return err
+	})
+	return val, err
+}
+
+// GetDel 实现 Redis GETDEL 命令，读取并删除字符串
+func (s *BadgerStore) GetDel(key []byte) ([]byte, error) {
+	var val []byte
+	err := s.update(func(txn *badger.Txn) error {
+		val = nil
+		entry, err := s.stringLookup(txn, key)
+		if err != nil || entry == nil {
+			return err
+		}
+		if val, err = s.stringLoad(txn, key, entry); err != nil {
+			return err
+		}
+		return s.stringDelete(txn, key)
+	})
+	return val, err
+}
+
+// GetEx 实现 Redis GETEX 命令。expireAt 大于 0 时设置新的过期时间，
+// persist 为 true 时清除过期时间，两者都未指定时只读取
+func (s *BadgerStore) GetEx(key []byte, expireAt int64, persist bool) ([]byte, error) {
+	var val []byte
+	err := s.update(func(txn *badger.Txn) error {
+		val = nil
+		entry, err := s.stringLookup(txn, key)
+		if err != nil || entry == nil {
+			return err
+		}
+		if val, err = s.stringLoad(txn, key, entry); err != nil {
+			return err
+		}
+		switch {
+		case expireAt > 0 && expireAt <= nowMilli():
+			// 过期时间已经过去，与 Redis 一致直接删除
+			return s.stringDelete(txn, key)
+		case expireAt > 0:
+			return s.stringSetExpire(txn, key, entry, expireAt)
+		case persist && entry.expireAt != 0:
+			return s.stringSetExpire(txn, key, entry, 0)
+		}
+		return nil
+	})
+	return val, err
+}
+
+// parseInt64 按 Redis 的规则把字符串解析为整数：不允许前后空白、正号和多余的前导零
+func parseInt64(b []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || strconv.FormatInt(n, 10) != string(b) {
+		return 0, ErrNotInteger
+	}
+	return n, nil
+}
+
+// longDoublePrec 是 x87 long double 的尾数位数，Redis 的 INCRBYFLOAT 以 long double 计算
+const longDoublePrec = 64
+
+// parseLongDouble 以 long double 精度解析浮点数，NaN 和带空白的字符串视为非法
+func parseLongDouble(b []byte) (*big.Float, error) {
+	str := string(b)
+	if str == "" || strings.TrimSpace(str) != str {
+		return nil, ErrNotFloat
+	}
+	f, _, err := big.ParseFloat(str, 10, longDoublePrec, big.ToNearestEven)
+	if err != nil {
+		return nil, ErrNotFloat
+	}
+	return f, nil
+}
+
+// formatLongDouble 与 Redis 的 ld2string(LD_STR_HUMAN) 一致：%.17Lf 后去掉末尾多余的 0 和小数点
+func formatLongDouble(f *big.Float) string {
+	str := f.Text('f', 17)
+	if strings.Contains(str, ".") {
+		str = strings.TrimRight(str, "0")
+		str = strings.TrimSuffix(str, ".")
+	}
+	if str == "-0" {
+		str = "0"
+	}
+	return str
+}
+
+// addInt64 返回 current+delta，结果溢出时返回 ErrOverflow
+func addInt64(current, delta int64) (int64, error) {
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+	return current + delta, nil
+}
+
+// addLongDouble 以 long double 精度计算 current+delta 并格式化，结果为无穷大时返回 ErrNaNOrInfinity
+func addLongDouble(current, delta *big.Float) ([]byte, error) {
+	if current.IsInf() || delta.IsInf() {
+		return nil, ErrNaNOrInfinity
+	}
+	sum := new(big.Float).SetPrec(longDoublePrec).Add(current, delta)
+	// big.Float 的指数范围远大于 long double，超出 long double 范围的结果按溢出处理
+	if exp := sum.MantExp(nil); exp > 16384 {
+		return nil, ErrNaNOrInfinity
+	}
+	return []byte(formatLongDouble(sum)), nil
+}
+
+// IncrBy 实现 Redis INCR/DECR/INCRBY/DECRBY 命令，key 不存在时视为 0，保留原有的过期时间
+func (s *BadgerStore) IncrBy(key []byte, delta int64) (int64, error) {
+	var result int64
+	err := s.update(func(txn *badger.Txn) error {
+		entry, err := s.stringLookup(txn, key)
 		if err != nil {
-			if errors.Is(err, badger.ErrKeyNotFound) {
-				return nil // 返回 nil 表示键不存在
+			return err
+		}
+		var current, expireAt int64
+		if entry != nil {
+			if current, err = parseInt64(entry.value); err != nil {
+				return err
 			}
+			expireAt = entry.expireAt
+		}
+		if result, err = addInt64(current, delta); err != nil {
+			return err
+		}
+		return s.stringWrite(txn, key, []byte(strconv.FormatInt(result, 10)), expireAt)
+	})
+	return result, err
+}
+
+// IncrByFloat 实现 Redis INCRBYFLOAT 命令。incr 以字符串传入，和原值一样按 long double 精度解析，
+// 结果的格式与 Redis 一致，返回写入的新值
+func (s *BadgerStore) IncrByFloat(key []byte, incr []byte) ([]byte, error) {
+	delta, err := parseLongDouble(incr)
+	if err != nil {
+		return nil, err
+	}
+	var result []byte
+	err = s.update(func(txn *badger.Txn) error {
+		entry, err := s.stringLookup(txn, key)
+		if err != nil {
+			return err
+		}
+		current := new(big.Float).SetPrec(longDoublePrec)
+		var expireAt int64
+		if entry != nil {
+			if current, err = parseLongDouble(entry.value); err != nil {
+				return err
+			}
+			expireAt = entry.expireAt
+		}
+		if result, err = addLongDouble(current, delta); err != nil {
+			return err
+		}
+		return s.stringWrite(txn, key, result, expireAt)
+	})
+	return result, err
+}
+
+// Append 实现 Redis APPEND 命令，返回追加后的长度。
+// 结果超过一个分片时转换为分片存储，之后的追加只改写末尾的分片
+func (s *BadgerStore) Append(key, value []byte) (int64, error) {
+	var length int64
+	err := s.update(func(txn *badger.Txn) error {
+		length = 0
+		entry, err := s.stringLookup(txn, key)
+		if err != nil {
+			return err
+		}
+		var offset int64
+		if entry != nil {
+			offset = entry.length
+		}
+		length, err = s.stringWriteRange(txn, key, entry, offset, value)
+		return err
+	})
+	return length, err
+}
+
+// StrLen 实现 Redis STRLEN 命令，key 不存在时返回 0
+func (s *BadgerStore) StrLen(key []byte) (int64, error) {
+	var length int64
+	err := s.db.View(func(txn *badger.Txn) error {
+		entry, err := s.stringLookup(txn, key)
+		if err != nil || entry == nil {
 			return err
 		}
-		v, _ := item.ValueCopy(nil)
-		val = v
+		length = entry.length
 		return nil
 	})
+	return length, err
+}
+
+// GetRange 实现 Redis GETRANGE 命令，start 和 end 都包含在内，负数表示从末尾倒数。
+// 分片存储时只读取区间涉及的分片
+func (s *BadgerStore) GetRange(key []byte, start, end int64) ([]byte, error) {
+	val := []byte{}
+	err := s.db.View(func(txn *badger.Txn) error {
+		entry, err := s.stringLookup(txn, key)
+		if err != nil || entry == nil {
+			return err
+		}
+		length := entry.length
+		// 与 Redis 一致：两端都为负数且 start > end 时直接返回空串
+		if start < 0 && end < 0 && start > end {
+			return nil
+		}
+		if start < 0 {
+			start = max(length+start, 0)
+		}
+		if end < 0 {
+			end = max(length+end, 0)
+		}
+		end = min(end, length-1)
+		if start > end || length == 0 {
+			return nil
+		}
+		val, err = s.stringLoadRange(txn, key, entry, start, end+1)
+		return err
+	})
 	return val, err
 }
+
+// SetRange 实现 Redis SETRANGE 命令，从 offset 处覆盖写入 value，不足的部分以 0 填充，返回新的长度。
+// value 为空时不修改字符串，也不会创建不存在的 key
+func (s *BadgerStore) SetRange(key []byte, offset int64, value []byte) (int64, error) {
+	if offset < 0 {
+		return 0, ErrOffsetOutOfRange
+	}
+	var length int64
+	err := s.update(func(txn *badger.Txn) error {
+		length = 0
+		entry, err := s.stringLookup(txn, key)
+		if err != nil {
+			return err
+		}
+		if len(value) == 0 {
+			if entry != nil {
+				length = entry.length
+			}
+			return nil
+		}
+		length, err = s.stringWriteRange(txn, key, entry, offset, value)
+		return err
+	})
+	return length, err
+}
+
+// MGet 实现 Redis MGET 命令，在同一个读快照中读取全部 key。
+// key 不存在或不是字符串时对应位置返回 nil
+func (s *BadgerStore) MGet(keys [][]byte) ([][]byte, error) {
+	values := make([][]byte, len(keys))
+	err := s.db.View(func(txn *badger.Txn) error {
+		for i, key := range keys {
+			entry, err := s.stringLookup(txn, key)
+			if errors.Is(err, ErrWrongType) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if entry == nil {
+				continue
+			}
+			if values[i], err = s.stringLoad(txn, key, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// MSet 实现 Redis MSET 命令，pairs 依次为 key、value，在一个事务内写入。
+// 与 SET 一致，会覆盖任意类型的旧值并清除过期时间
+func (s *BadgerStore) MSet(pairs [][]byte) error {
+	return s.update(func(txn *badger.Txn) error {
+		return s.msetPairs(txn, pairs)
+	})
+}
+
+// MSetNX 实现 Redis MSETNX 命令，只有全部 key 都不存在时才写入，返回是否写入
+func (s *BadgerStore) MSetNX(pairs [][]byte) (bool, error) {
+	var written bool
+	err := s.update(func(txn *badger.Txn) error {
+		written = false
+		for i := 0; i < len(pairs); i += 2 {
+			keyType, err := s.keyType(txn, pairs[i])
+			if err != nil {
+				return err
+			}
+			if keyType != "" {
+				return nil
+			}
+		}
+		if err := s.msetPairs(txn, pairs); err != nil {
+			return err
+		}
+		written = true
+		return nil
+	})
+	return written, err
+}
+
+func (s *BadgerStore) msetPairs(txn *badger.Txn, pairs [][]byte) error {
+	if len(pairs)%2 != 0 {
+		return fmt.Errorf("msetPairs: odd number of arguments")
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		key, value := pairs[i], pairs[i+1]
+		keyType, err := s.keyType(txn, key)
+		if err != nil {
+			return err
+		}
+		if keyType != "" && keyType != KeyTypeString {
+			if _, err := s.deleteKey(txn, key); err != nil {
+				return err
+			}
+		}
+		if err := s.stringWrite(txn, key, value, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
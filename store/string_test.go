@@ -1,8 +1,16 @@
 package store
 
 import (
+	"bytes"
 	"fmt"
+	"math"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/zeebo/assert"
 )
 
 func TestStringAuto(t *testing.T) {
@@ -27,3 +35,336 @@ func TestStringAuto(t *testing.T) {
 	}
 
 }
+
+func TestSetWithOption(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("k")
+
+	// NX 只在不存在时写入
+	_, written, err := store.SetWithOption(key, []byte("v1"), SetOption{NX: true})
+	assert.NoError(t, err)
+	assert.True(t, written)
+	_, written, _ = store.SetWithOption(key, []byte("v2"), SetOption{NX: true})
+	assert.False(t, written)
+
+	// XX 只在已存在时写入，GET 返回旧值
+	old, written, _ := store.SetWithOption(key, []byte("v2"), SetOption{XX: true, Get: true})
+	assert.True(t, written)
+	assert.Equal(t, "v1", string(old))
+	_, written, _ = store.SetWithOption([]byte("missing"), []byte("v"), SetOption{XX: true})
+	assert.False(t, written)
+
+	// 过期时间与 KEEPTTL
+	_, _, err = store.SetWithOption(key, []byte("v3"), SetOption{ExpireAt: nowMilli() + 100})
+	assert.NoError(t, err)
+	_, _, _ = store.SetWithOption(key, []byte("v4"), SetOption{KeepTTL: true})
+	val, _ := store.Get(key)
+	assert.Equal(t, "v4", string(val))
+	time.Sleep(150 * time.Millisecond)
+	val, _ = store.Get(key)
+	assert.Nil(t, val)
+
+	// 过期后 NX 可以重新写入
+	ok, err := store.SetNX(key, []byte("v5"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestGetVariants(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("k")
+
+	old, err := store.GetSet(key, []byte("a"))
+	assert.NoError(t, err)
+	assert.Nil(t, old)
+	old, _ = store.GetSet(key, []byte("b"))
+	assert.Equal(t, "a", string(old))
+
+	// GETEX 设置过期时间，PERSIST 清除
+	val, err := store.GetEx(key, nowMilli()+50, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(val))
+	_, _ = store.GetEx(key, 0, true)
+	time.Sleep(80 * time.Millisecond)
+	val, _ = store.Get(key)
+	assert.Equal(t, "b", string(val))
+
+	val, _ = store.GetDel(key)
+	assert.Equal(t, "b", string(val))
+	val, _ = store.Get(key)
+	assert.Nil(t, val)
+
+	// 其他类型的 key 返回 WRONGTYPE，SET 则直接覆盖
+	_, _ = store.SAdd([]byte("set"), []byte("m"))
+	assert.NoError(t, store.db.Update(func(txn *badger.Txn) error {
+		return store.setKeyType(txn, []byte("set"), KeyTypeSet)
+	}))
+	_, err = store.Get([]byte("set"))
+	assert.Equal(t, ErrWrongType, err)
+	_, _, err = store.SetWithOption([]byte("set"), []byte("v"), SetOption{Get: true})
+	assert.Equal(t, ErrWrongType, err)
+	assert.NoError(t, store.Set([]byte("set"), []byte("v")))
+	card, _ := store.SCard([]byte("set"))
+	assert.Equal(t, uint64(0), card)
+}
+
+func TestIncrBy(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("counter")
+
+	n, err := store.IncrBy(key, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	n, _ = store.IncrBy(key, -11)
+	assert.Equal(t, int64(-10), n)
+
+	assert.NoError(t, store.Set(key, []byte(strconv.FormatInt(math.MaxInt64, 10))))
+	_, err = store.IncrBy(key, 1)
+	assert.Equal(t, ErrOverflow, err)
+
+	for _, bad := range []string{"abc", " 1", "+1", "01", "1.5", ""} {
+		assert.NoError(t, store.Set(key, []byte(bad)))
+		_, err = store.IncrBy(key, 1)
+		assert.Equal(t, ErrNotInteger, err)
+	}
+
+	// INCR 保留原有的过期时间
+	_, _, _ = store.SetWithOption(key, []byte("5"), SetOption{ExpireAt: nowMilli() + 60000})
+	_, _ = store.IncrBy(key, 1)
+	assert.NoError(t, store.db.View(func(txn *badger.Txn) error {
+		entry, err := store.stringRead(txn, key)
+		assert.Equal(t, "6", string(entry.value))
+		assert.True(t, entry.expireAt > 0)
+		return err
+	}))
+}
+
+func TestIncrByConcurrent(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("counter")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_, err := store.IncrBy(key, 1)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	val, _ := store.Get(key)
+	assert.Equal(t, "400", string(val))
+}
+
+func TestIncrByFloat(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("f")
+
+	assert.NoError(t, store.Set(key, []byte("10.50")))
+	val, err := store.IncrByFloat(key, []byte("0.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "10.6", string(val))
+	val, _ = store.IncrByFloat(key, []byte("-5"))
+	assert.Equal(t, "5.6", string(val))
+
+	assert.NoError(t, store.Set(key, []byte("5.0e3")))
+	val, _ = store.IncrByFloat(key, []byte("2.0e2"))
+	assert.Equal(t, "5200", string(val))
+
+	val, _ = store.IncrByFloat([]byte("new"), []byte("3"))
+	assert.Equal(t, "3", string(val))
+
+	_, err = store.IncrByFloat(key, []byte("abc"))
+	assert.Equal(t, ErrNotFloat, err)
+	_, err = store.IncrByFloat(key, []byte("inf"))
+	assert.Equal(t, ErrNaNOrInfinity, err)
+}
+
+func TestAppendAndRange(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("mykey")
+
+	n, err := store.Append(key, []byte("Hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	n, _ = store.Append(key, []byte(" World"))
+	assert.Equal(t, int64(11), n)
+	n, _ = store.StrLen(key)
+	assert.Equal(t, int64(11), n)
+	n, _ = store.StrLen([]byte("missing"))
+	assert.Equal(t, int64(0), n)
+
+	// 空字符串与不存在的 key 不同
+	_ = store.Set([]byte("blank"), []byte{})
+	val, err := store.Get([]byte("blank"))
+	assert.NoError(t, err)
+	assert.NotNil(t, val)
+	assert.Equal(t, 0, len(val))
+
+	for _, tc := range []struct {
+		start, end int64
+		want       string
+	}{
+		{0, 4, "Hello"}, {-5, -1, "World"}, {0, -1, "Hello World"}, {6, 100, "World"},
+		{-100, 2, "Hel"}, {5, 3, ""}, {-1, -5, ""}, {20, 30, ""},
+	} {
+		val, err := store.GetRange(key, tc.start, tc.end)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, string(val))
+	}
+
+	n, _ = store.SetRange(key, 6, []byte("Redis"))
+	assert.Equal(t, int64(11), n)
+	val, _ = store.Get(key)
+	assert.Equal(t, "Hello Redis", string(val))
+
+	// 超出原长度的部分以 0 填充
+	n, _ = store.SetRange([]byte("pad"), 3, []byte("ab"))
+	assert.Equal(t, int64(5), n)
+	val, _ = store.Get([]byte("pad"))
+	assert.Equal(t, "\x00\x00\x00ab", string(val))
+
+	// 空值不会创建 key
+	n, _ = store.SetRange([]byte("empty"), 10, nil)
+	assert.Equal(t, int64(0), n)
+	val, _ = store.Get([]byte("empty"))
+	assert.Nil(t, val)
+
+	_, err = store.SetRange(key, -1, []byte("x"))
+	assert.Equal(t, ErrOffsetOutOfRange, err)
+	_, err = store.SetRange(key, maxStringSize, []byte("x"))
+	assert.Equal(t, ErrStringTooLong, err)
+}
+
+func TestStringFragments(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("big")
+
+	// 多次追加超过一个分片后转换为分片存储，结果与整体拼接一致
+	var want []byte
+	chunk := bytes.Repeat([]byte("0123456789"), 500)
+	for i := 0; i < 5; i++ {
+		_, err := store.Append(key, chunk)
+		assert.NoError(t, err)
+		want = append(want, chunk...)
+	}
+	assert.NoError(t, store.db.View(func(txn *badger.Txn) error {
+		entry, err := store.stringRead(txn, key)
+		assert.True(t, entry.fragmented)
+		return err
+	}))
+	val, _ := store.Get(key)
+	assert.True(t, bytes.Equal(want, val))
+
+	// 跨越分片边界的读写
+	n, _ := store.SetRange(key, stringFragmentSize-2, []byte("ABCD"))
+	assert.Equal(t, int64(len(want)), n)
+	copy(want[stringFragmentSize-2:], "ABCD")
+	val, _ = store.GetRange(key, stringFragmentSize-5, stringFragmentSize+5)
+	assert.Equal(t, string(want[stringFragmentSize-5:stringFragmentSize+6]), string(val))
+	val, _ = store.Get(key)
+	assert.True(t, bytes.Equal(want, val))
+
+	// 远超当前长度的 SETRANGE 只写入涉及的分片，中间读出为 0
+	offset := int64(10 * stringFragmentSize)
+	n, _ = store.SetRange(key, offset, []byte("tail"))
+	assert.Equal(t, offset+4, n)
+	val, _ = store.GetRange(key, offset-3, -1)
+	assert.Equal(t, "\x00\x00\x00tail", string(val))
+
+	// 重新 SET 会清理旧的分片，改为内联存储
+	assert.NoError(t, store.Set(key, []byte("small")))
+	assert.NoError(t, store.db.View(func(txn *badger.Txn) error {
+		found := 0
+		err := store.stringIterFragments(txn, key, 0, 100, false, func(int64, []byte) error {
+			found++
+			return nil
+		})
+		assert.Equal(t, 0, found)
+		return err
+	}))
+	val, _ = store.Get(key)
+	assert.Equal(t, "small", string(val))
+}
+
+func TestStringFragmentsExpire(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("big")
+
+	_, _ = store.SetRange(key, 3*stringFragmentSize, []byte("x"))
+	// GETEX 修改过期时间后值保持不变
+	_, err := store.GetEx(key, nowMilli()+60000, false)
+	assert.NoError(t, err)
+	val, _ := store.GetRange(key, -1, -1)
+	assert.Equal(t, "x", string(val))
+	assert.NoError(t, store.db.View(func(txn *badger.Txn) error {
+		entry, err := store.stringRead(txn, key)
+		assert.True(t, entry.fragmented && entry.expireAt > 0)
+		return err
+	}))
+
+	// 过期后视为不存在，APPEND 重新创建时会清理残留的分片
+	_, _ = store.GetEx(key, nowMilli()+50, false)
+	time.Sleep(100 * time.Millisecond)
+	n, _ := store.StrLen(key)
+	assert.Equal(t, int64(0), n)
+	n, _ = store.Append(key, []byte("abc"))
+	assert.Equal(t, int64(3), n)
+	assert.NoError(t, store.db.View(func(txn *badger.Txn) error {
+		found := 0
+		err := store.stringIterFragments(txn, key, 0, 100, false, func(int64, []byte) error {
+			found++
+			return nil
+		})
+		assert.Equal(t, 0, found)
+		return err
+	}))
+}
+
+func TestMultiKey(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+
+	assert.NoError(t, store.MSet([][]byte{[]byte("a"), []byte("1"), []byte("b"), []byte("2"), []byte("a"), []byte("3")}))
+	assert.NoError(t, store.db.Update(func(txn *badger.Txn) error {
+		return store.setKeyType(txn, []byte("list"), KeyTypeList)
+	}))
+
+	values, err := store.MGet([][]byte{[]byte("a"), []byte("missing"), []byte("b"), []byte("list")})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(values))
+	assert.Equal(t, "3", string(values[0]))
+	assert.Nil(t, values[1])
+	assert.Equal(t, "2", string(values[2]))
+	assert.Nil(t, values[3])
+
+	// 任意一个 key 已存在时 MSETNX 不写入任何 key
+	written, err := store.MSetNX([][]byte{[]byte("c"), []byte("x"), []byte("a"), []byte("y")})
+	assert.NoError(t, err)
+	assert.False(t, written)
+	val, _ := store.Get([]byte("c"))
+	assert.Nil(t, val)
+
+	written, _ = store.MSetNX([][]byte{[]byte("c"), []byte("x"), []byte("d"), []byte("y")})
+	assert.True(t, written)
+	values, _ = store.MGet([][]byte{[]byte("c"), []byte("d")})
+	assert.Equal(t, "x", string(values[0]))
+	assert.Equal(t, "y", string(values[1]))
+
+	// MSET 覆盖其他类型的值
+	assert.NoError(t, store.MSet([][]byte{[]byte("list"), []byte("s")}))
+	val, err = store.Get([]byte("list"))
+	assert.NoError(t, err)
+	assert.Equal(t, "s", string(val))
+}
@@ -0,0 +1,326 @@
+package store
+
+import (
+	"PumbaaDB/helper"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// 有序集合的每个成员对应两条记录：ZSET:<key>:member:<member> 保存成员的分数，
+// ZSET:<key>:score:<8 字节保序编码的分数><member> 是按分数排序的索引，值为空；成员数量保存在 ZSET:<key>:count 下。
+// ZSET:<key> 是 keyPrefix 生成的带长度的前缀，名字互为前缀的有序集合的记录不会混在一起。
+// 按成员查分数是一次点查，按分数范围读取是索引上的有序前缀扫描，分数相同的成员按字节序排列，与 Redis 一致。
+// 最后一个成员被删除时连同计数器和类型标记一起删除
+
+// ZMember 是有序集合的一个成员及其分数
+type ZMember struct {
+	Member []byte
+	Score  float64
+}
+
+// ZAddOption 是 ZADD 命令的可选参数
+type ZAddOption struct {
+	NX bool // 只添加新成员，不更新已有成员
+	XX bool // 只更新已有成员，不添加新成员
+	GT bool // 已有成员只在新分数更大时更新，不影响新成员的添加
+	LT bool // 已有成员只在新分数更小时更新，不影响新成员的添加
+	CH bool // 返回值除新增的成员外还包括分数被修改的成员
+}
+
+// encodeScore 把分数编码为按字节序比较与按数值比较结果一致的 8 字节：
+// 正数翻转符号位，负数翻转全部位。-0 按 0 处理，±inf 分别排在两端
+func encodeScore(score float64) []byte {
+	if score == 0 {
+		score = 0
+	}
+	bits := math.Float64bits(score)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return binary.BigEndian.AppendUint64(nil, bits)
+}
+
+// decodeScore 是 encodeScore 的逆过程
+func decodeScore(b []byte) float64 {
+	bits := binary.BigEndian.Uint64(b)
+	if bits&(1<<63) != 0 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits)
+}
+
+// zsetMemberPrefix 返回成员记录共同的键前缀
+func (s *BadgerStore) zsetMemberPrefix(key []byte) []byte {
+	return keyPrefix(prefixKeyZSet, key, ":member:")
+}
+
+func (s *BadgerStore) zsetMemberKey(key, member []byte) []byte {
+	return append(s.zsetMemberPrefix(key), member...)
+}
+
+// zsetScorePrefix 返回分数索引共同的键前缀，索引先按分数再按成员排序
+func (s *BadgerStore) zsetScorePrefix(key []byte) []byte {
+	return keyPrefix(prefixKeyZSet, key, ":score:")
+}
+
+func (s *BadgerStore) zsetScoreKey(key []byte, score float64, member []byte) []byte {
+	k := append(s.zsetScorePrefix(key), encodeScore(score)...)
+	return append(k, member...)
+}
+
+// zsetCountKey 返回成员数量的计数器键
+func (s *BadgerStore) zsetCountKey(key []byte) []byte {
+	return keyPrefix(prefixKeyZSet, key, ":count")
+}
+
+// zsetGetCount 读取有序集合的成员数量，不存在时返回 0
+func (s *BadgerStore) zsetGetCount(txn *badger.Txn, key []byte) (uint64, error) {
+	item, err := txn.Get(s.zsetCountKey(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, fmt.Errorf("zsetGetCount: failed to get count value: %v", err)
+	}
+	return helper.BytesToUint64(val), nil
+}
+
+// zsetSetCount 写入有序集合的成员数量，数量为 0 时删除计数器和类型标记
+func (s *BadgerStore) zsetSetCount(txn *badger.Txn, key []byte, count uint64) error {
+	if count == 0 {
+		if err := txn.Delete(s.zsetCountKey(key)); err != nil {
+			return err
+		}
+		return txn.Delete(TypeKeyGet(string(key)))
+	}
+	if err := s.setKeyType(txn, key, KeyTypeZSet); err != nil {
+		return err
+	}
+	return txn.Set(s.zsetCountKey(key), helper.Uint64ToBytes(count))
+}
+
+// zsetPrepare 检查 key 的类型并返回有序集合的成员数量
+func (s *BadgerStore) zsetPrepare(txn *badger.Txn, key []byte) (uint64, error) {
+	if _, err := s.checkKeyType(txn, key, KeyTypeZSet); err != nil {
+		return 0, err
+	}
+	return s.zsetGetCount(txn, key)
+}
+
+// zsetGetScore 读取成员的分数，成员不存在时 exists 为 false
+func (s *BadgerStore) zsetGetScore(txn *badger.Txn, key, member []byte) (score float64, exists bool, err error) {
+	item, err := txn.Get(s.zsetMemberKey(key, member))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(val) != 8 {
+		return 0, false, fmt.Errorf("zsetGetScore: corrupted score of member %q in key %q", member, key)
+	}
+	return decodeScore(val), true, nil
+}
+
+// zsetPut 写入成员的分数并维护分数索引，old 为成员原来的分数，新成员传 nil
+func (s *BadgerStore) zsetPut(txn *badger.Txn, key, member []byte, score float64, old *float64) error {
+	if old != nil {
+		if err := txn.Delete(s.zsetScoreKey(key, *old, member)); err != nil {
+			return err
+		}
+	}
+	if err := txn.Set(s.zsetMemberKey(key, member), encodeScore(score)); err != nil {
+		return err
+	}
+	return txn.Set(s.zsetScoreKey(key, score, member), []byte{})
+}
+
+// zsetDelete 删除成员记录和对应的分数索引
+func (s *BadgerStore) zsetDelete(txn *badger.Txn, key, member []byte, score float64) error {
+	if err := txn.Delete(s.zsetMemberKey(key, member)); err != nil {
+		return err
+	}
+	return txn.Delete(s.zsetScoreKey(key, score, member))
+}
+
+// zsetAdd 按 ZADD 的规则添加或更新一个成员，incr 为 true 时 score 是增量。
+// 返回成员最终的分数，以及成员是否被新增、分数是否被修改；条件不满足而什么也没做时 ok 为 false
+func (s *BadgerStore) zsetAdd(txn *badger.Txn, key []byte, count *uint64, opt ZAddOption, member []byte, score float64, incr bool) (newScore float64, added, updated, ok bool, err error) {
+	current, exists, err := s.zsetGetScore(txn, key, member)
+	if err != nil {
+		return 0, false, false, false, err
+	}
+	if !exists {
+		if opt.XX {
+			return 0, false, false, false, nil
+		}
+		if err := s.zsetPut(txn, key, member, score, nil); err != nil {
+			return 0, false, false, false, err
+		}
+		*count++
+		return score, true, false, true, nil
+	}
+
+	if opt.NX {
+		return current, false, false, false, nil
+	}
+	if incr {
+		score += current
+		if math.IsNaN(score) {
+			return 0, false, false, false, ErrScoreNaN
+		}
+	}
+	if (opt.LT && score >= current) || (opt.GT && score <= current) {
+		return current, false, false, false, nil
+	}
+	if score == current {
+		return current, false, false, true, nil
+	}
+	if err := s.zsetPut(txn, key, member, score, &current); err != nil {
+		return 0, false, false, false, err
+	}
+	return score, false, true, true, nil
+}
+
+// ZAdd 实现 Redis ZADD 命令（不含 INCR），返回新增的成员数量，opt.CH 为 true 时还包括分数被修改的成员
+func (s *BadgerStore) ZAdd(key []byte, opt ZAddOption, members []ZMember) (int64, error) {
+	var changed int64
+	err := s.update(func(txn *badger.Txn) error {
+		changed = 0
+		count, err := s.zsetPrepare(txn, key)
+		if err != nil {
+			return err
+		}
+		before := count
+		for _, m := range members {
+			_, added, updated, _, err := s.zsetAdd(txn, key, &count, opt, m.Member, m.Score, false)
+			if err != nil {
+				return err
+			}
+			if added || (opt.CH && updated) {
+				changed++
+			}
+		}
+		if count == before {
+			return nil
+		}
+		return s.zsetSetCount(txn, key, count)
+	})
+	return changed, err
+}
+
+// ZAddIncr 实现 ZADD 的 INCR 模式，把 member 的分数加上 incr，成员不存在时以 incr 为分数新增。
+// 因 NX、XX、GT、LT 的条件而没有修改时 ok 为 false
+func (s *BadgerStore) ZAddIncr(key []byte, opt ZAddOption, member []byte, incr float64) (score float64, ok bool, err error) {
+	err = s.update(func(txn *badger.Txn) error {
+		score, ok = 0, false
+		count, err := s.zsetPrepare(txn, key)
+		if err != nil {
+			return err
+		}
+		var added bool
+		score, added, _, ok, err = s.zsetAdd(txn, key, &count, opt, member, incr, true)
+		if err != nil || !added {
+			return err
+		}
+		return s.zsetSetCount(txn, key, count)
+	})
+	return score, ok, err
+}
+
+// ZIncrBy 实现 Redis ZINCRBY 命令，返回成员新的分数
+func (s *BadgerStore) ZIncrBy(key []byte, incr float64, member []byte) (float64, error) {
+	score, _, err := s.ZAddIncr(key, ZAddOption{}, member, incr)
+	return score, err
+}
+
+// ZScore 实现 Redis ZSCORE 命令，成员不存在时 exists 为 false
+func (s *BadgerStore) ZScore(key, member []byte) (score float64, exists bool, err error) {
+	err = s.db.View(func(txn *badger.Txn) error {
+		if _, err := s.checkKeyType(txn, key, KeyTypeZSet); err != nil {
+			return err
+		}
+		score, exists, err = s.zsetGetScore(txn, key, member)
+		return err
+	})
+	return score, exists, err
+}
+
+// ZMScore 实现 Redis ZMSCORE 命令，结果与 members 一一对应，不存在的成员为 nil
+func (s *BadgerStore) ZMScore(key []byte, members ...[]byte) ([]*float64, error) {
+	var scores []*float64
+	err := s.db.View(func(txn *badger.Txn) error {
+		scores = make([]*float64, len(members))
+		if _, err := s.checkKeyType(txn, key, KeyTypeZSet); err != nil {
+			return err
+		}
+		for i, member := range members {
+			score, exists, err := s.zsetGetScore(txn, key, member)
+			if err != nil {
+				return err
+			}
+			if exists {
+				scores[i] = &score
+			}
+		}
+		return nil
+	})
+	return scores, err
+}
+
+// ZCard 实现 Redis ZCARD 命令
+func (s *BadgerStore) ZCard(key []byte) (uint64, error) {
+	var count uint64
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		count, err = s.zsetPrepare(txn, key)
+		return err
+	})
+	return count, err
+}
+
+// ZRem 实现 Redis ZREM 命令，返回实际删除的成员数量
+func (s *BadgerStore) ZRem(key []byte, members ...[]byte) (int64, error) {
+	var removed int64
+	err := s.update(func(txn *badger.Txn) error {
+		removed = 0
+		count, err := s.zsetPrepare(txn, key)
+		if err != nil || count == 0 {
+			return err
+		}
+		for _, member := range members {
+			score, exists, err := s.zsetGetScore(txn, key, member)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				continue
+			}
+			if err := s.zsetDelete(txn, key, member, score); err != nil {
+				return err
+			}
+			removed++
+		}
+		if removed == 0 {
+			return nil
+		}
+		return s.zsetSetCount(txn, key, count-min(count, uint64(removed)))
+	})
+	return removed, err
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/zeebo/assert"
+)
+
+func TestEncodeScore(t *testing.T) {
+	scores := []float64{math.Inf(-1), -math.MaxFloat64, -1e10, -1.5, -math.SmallestNonzeroFloat64, 0,
+		math.SmallestNonzeroFloat64, 0.5, 1, 2, 1e300, math.MaxFloat64, math.Inf(1)}
+	encoded := make([][]byte, len(scores))
+	for i, score := range scores {
+		encoded[i] = encodeScore(score)
+		assert.Equal(t, score, decodeScore(encoded[i]))
+	}
+	assert.True(t, sort.SliceIsSorted(encoded, func(i, j int) bool { return bytes.Compare(encoded[i], encoded[j]) < 0 }))
+	// -0 与 0 编码相同
+	assert.Equal(t, encodeScore(0), encodeScore(math.Copysign(0, -1)))
+}
+
+// zsetIndex 按分数索引的顺序返回成员
+func zsetIndex(t *testing.T, store *BadgerStore, key []byte) []string {
+	var members []string
+	assert.NoError(t, store.db.View(func(txn *badger.Txn) error {
+		prefix := store.zsetScorePrefix(key)
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			members = append(members, string(iter.Item().Key()[len(prefix)+8:]))
+		}
+		return nil
+	}))
+	return members
+}
+
+func TestZAdd(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("board")
+	m := func(member string, score float64) ZMember {
+		return ZMember{Member: []byte(member), Score: score}
+	}
+
+	n, err := store.ZAdd(key, ZAddOption{}, []ZMember{m("b", 2), m("a", 1), m("c", 3), m("ninf", math.Inf(-1))})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+	assert.Equal(t, []string{"ninf", "a", "b", "c"}, zsetIndex(t, store, key))
+	// 名字以 board: 开头的有序集合不会出现在 board 的索引里
+	_, _ = store.ZAdd([]byte("board:score"), ZAddOption{}, []ZMember{m("x", 0)})
+	assert.Equal(t, []string{"ninf", "a", "b", "c"}, zsetIndex(t, store, key))
+
+	// 更新分数后索引随之移动，同分数按成员排序
+	n, _ = store.ZAdd(key, ZAddOption{}, []ZMember{m("a", 3), m("d", 3)})
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, []string{"ninf", "b", "a", "c", "d"}, zsetIndex(t, store, key))
+	n, _ = store.ZAdd(key, ZAddOption{CH: true}, []ZMember{m("a", 3), m("b", 5), m("e", 0)})
+	assert.Equal(t, int64(2), n)
+
+	// NX 不更新已有成员，XX 不新增成员
+	n, _ = store.ZAdd(key, ZAddOption{NX: true, CH: true}, []ZMember{m("a", 100), m("f", 1)})
+	assert.Equal(t, int64(1), n)
+	n, _ = store.ZAdd(key, ZAddOption{XX: true, CH: true}, []ZMember{m("a", 4), m("g", 1)})
+	assert.Equal(t, int64(1), n)
+	score, exists, _ := store.ZScore(key, []byte("a"))
+	assert.True(t, exists)
+	assert.Equal(t, 4.0, score)
+	_, exists, _ = store.ZScore(key, []byte("g"))
+	assert.False(t, exists)
+
+	// GT、LT 只限制已有成员的更新
+	n, _ = store.ZAdd(key, ZAddOption{GT: true, CH: true}, []ZMember{m("a", 2), m("b", 6), m("h", -1)})
+	assert.Equal(t, int64(2), n)
+	n, _ = store.ZAdd(key, ZAddOption{LT: true, CH: true}, []ZMember{m("a", 2), m("b", 7)})
+	assert.Equal(t, int64(1), n)
+	scores, _ := store.ZMScore(key, []byte("a"), []byte("b"), []byte("missing"))
+	assert.Equal(t, 2.0, *scores[0])
+	assert.Equal(t, 6.0, *scores[1])
+	assert.Nil(t, scores[2])
+
+	// XX 作用于不存在的 key 时不会创建它
+	n, _ = store.ZAdd([]byte("none"), ZAddOption{XX: true}, []ZMember{m("a", 1)})
+	assert.Equal(t, int64(0), n)
+	count, _ := store.ZCard([]byte("none"))
+	assert.Equal(t, uint64(0), count)
+
+	_, _ = store.RPush([]byte("list"), []byte("x"))
+	_, err = store.ZAdd([]byte("list"), ZAddOption{}, []ZMember{m("a", 1)})
+	assert.Equal(t, ErrWrongType, err)
+}
+
+func TestZIncrAndRem(t *testing.T) {
+	store, _ := NewBadgerStore(t.TempDir())
+	defer store.Close()
+	key := []byte("z")
+
+	score, err := store.ZIncrBy(key, 2.5, []byte("a"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, score)
+	score, _ = store.ZIncrBy(key, -1, []byte("a"))
+	assert.Equal(t, 1.5, score)
+
+	_, ok, _ := store.ZAddIncr(key, ZAddOption{NX: true}, []byte("a"), 1)
+	assert.False(t, ok)
+	_, ok, _ = store.ZAddIncr(key, ZAddOption{GT: true}, []byte("a"), -1)
+	assert.False(t, ok)
+	score, ok, _ = store.ZAddIncr(key, ZAddOption{XX: true}, []byte("a"), 1)
+	assert.True(t, ok)
+	assert.Equal(t, 2.5, score)
+
+	score, _ = store.ZIncrBy(key, math.Inf(1), []byte("b"))
+	assert.True(t, math.IsInf(score, 1))
+	_, err = store.ZIncrBy(key, math.Inf(-1), []byte("b"))
+	assert.Equal(t, ErrScoreNaN, err)
+	count, _ := store.ZCard(key)
+	assert.Equal(t, uint64(2), count)
+
+	removed, _ := store.ZRem(key, []byte("a"), []byte("missing"), []byte("a"))
+	assert.Equal(t, int64(1), removed)
+	assert.Equal(t, []string{"b"}, zsetIndex(t, store, key))
+	removed, _ = store.ZRem(key, []byte("b"))
+	assert.Equal(t, int64(1), removed)
+	assert.NoError(t, store.db.View(func(txn *badger.Txn) error {
+		keyType, err := store.keyType(txn, key)
+		assert.Equal(t, "", keyType)
+		return err
+	}))
+	assert.Equal(t, 0, len(zsetIndex(t, store, key)))
+}